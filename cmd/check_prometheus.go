@@ -5,6 +5,7 @@ import (
 	"github.com/AlejandroPerez92/nanoctl/pkg/config"
 	"github.com/AlejandroPerez92/nanoctl/pkg/temperature"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -23,42 +24,53 @@ This command is useful for troubleshooting Prometheus integration.`,
 		// Load configuration
 		cfg, err := config.LoadFanConfig(configPath)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
-			os.Exit(1)
+			fail(cmd, exitFailure, fmt.Errorf("failed to load configuration: %w", err))
 		}
 
 		// Check if Prometheus is configured
 		if cfg.Temperature.Source.Prometheus == nil {
-			fmt.Println("Prometheus is not configured in the config file.")
-			fmt.Printf("Edit %s to add Prometheus configuration.\n", configPath)
-			os.Exit(1)
+			fail(cmd, exitFailure, fmt.Errorf("prometheus is not configured, add temperature.source.prometheus to %s", configPath))
 		}
 
 		promConfig := cfg.Temperature.Source.Prometheus
+		res := prometheusCheckResult{
+			Host:    promConfig.Host,
+			Query:   strings.TrimSuffix(getQueryOrDefault(promConfig.Query), " (default)"),
+			Timeout: strings.TrimSuffix(getTimeoutOrDefault(promConfig.Timeout), " (default)"),
+			MaxAge:  promConfig.MaxAge,
+			Auth:    "none",
+		}
 
-		fmt.Println("Prometheus Configuration:")
-		fmt.Printf("  Host:    %s\n", promConfig.Host)
-		fmt.Printf("  Query:   %s\n", getQueryOrDefault(promConfig.Query))
-		fmt.Printf("  Timeout: %s\n", getTimeoutOrDefault(promConfig.Timeout))
-		if promConfig.Auth != nil && promConfig.Auth.Username != "" {
-			fmt.Printf("  Auth:    Basic (username: %s)\n", promConfig.Auth.Username)
+		textf("Prometheus Configuration:\n")
+		textf("  Host:    %s\n", promConfig.Host)
+		textf("  Query:   %s\n", getQueryOrDefault(promConfig.Query))
+		textf("  Timeout: %s\n", getTimeoutOrDefault(promConfig.Timeout))
+		textf("  Max age: %s\n", getMaxAgeOrDefault(promConfig.MaxAge))
+		if promConfig.Auth != nil && promConfig.Auth.Token != "" {
+			res.Auth = "bearer"
+			textf("  Auth:    Bearer token\n")
+		} else if promConfig.Auth != nil && promConfig.Auth.Username != "" {
+			res.Auth = "basic"
+			textf("  Auth:    Basic (username: %s)\n", promConfig.Auth.Username)
 		} else {
-			fmt.Printf("  Auth:    None\n")
+			textf("  Auth:    None\n")
 		}
-		fmt.Println()
+		textf("\n")
 
-		fmt.Println("Testing connection...")
+		textf("Testing connection...\n")
 
 		// Map to temperature package config
 		tempPromConfig := temperature.PrometheusConfig{
 			Host:    promConfig.Host,
 			Query:   promConfig.Query,
 			Timeout: promConfig.Timeout,
+			MaxAge:  promConfig.MaxAge,
 		}
 		if promConfig.Auth != nil {
 			tempPromConfig.Auth = temperature.AuthConfig{
 				Username: promConfig.Auth.Username,
 				Password: promConfig.Auth.Password,
+				Token:    promConfig.Auth.Token,
 			}
 		}
 
@@ -66,29 +78,67 @@ This command is useful for troubleshooting Prometheus integration.`,
 		start := time.Now()
 		promSource, err := temperature.NewPrometheusSource(tempPromConfig)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "✗ Failed to create Prometheus client: %v\n", err)
-			os.Exit(1)
+			checkFailed(cmd, res, "Failed to create Prometheus client", err)
 		}
 		defer promSource.Close()
 
-		fmt.Println("✓ Prometheus client created successfully")
+		textf("✓ Prometheus client created successfully\n")
 
 		// Execute query
-		fmt.Println("\nExecuting query...")
-		temp, err := promSource.GetTemperature()
+		textf("\nExecuting query...\n")
+		reading, err := promSource.Query()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "✗ Query failed: %v\n", err)
-			os.Exit(1)
+			checkFailed(cmd, res, "Query failed", err)
 		}
 
 		elapsed := time.Since(start)
-
-		fmt.Printf("✓ Query successful (took %v)\n", elapsed)
-		fmt.Printf("\nTemperature: %.2f°C\n", temp)
-		fmt.Println("\n✓ Prometheus connection is working correctly!")
+		res.Temperature = reading.Value
+		res.SampleAgeSeconds = time.Since(reading.Timestamp).Seconds()
+		res.Series, res.Stale = reading.Series, reading.Stale
+		res.QuerySeconds = elapsed.Seconds()
+
+		printResult(cmd, res, func() {
+			fmt.Printf("✓ Query successful (took %v)\n", elapsed)
+			fmt.Printf("\nTemperature: %.2f°C\n", reading.Value)
+			fmt.Printf("Sample age:  %v\n", time.Since(reading.Timestamp).Round(time.Second))
+			fmt.Printf("Series:      %d", reading.Series)
+			if reading.Stale > 0 {
+				fmt.Printf(" (%d stale)", reading.Stale)
+			}
+			fmt.Println()
+			if reading.Series > 1 {
+				fmt.Println("\n! The query returned more than one series; the highest value is used.")
+				fmt.Println("  Aggregate the query (e.g. max(...)) to control which value is used.")
+			}
+			fmt.Println("\n✓ Prometheus connection is working correctly!")
+		})
 	},
 }
 
+// prometheusCheckResult is the structured output of check-prometheus. The
+// reading is only set when the query succeeded.
+type prometheusCheckResult struct {
+	Host             string  `json:"host" yaml:"host"`
+	Query            string  `json:"query" yaml:"query"`
+	Timeout          string  `json:"timeout" yaml:"timeout"`
+	MaxAge           string  `json:"max_age,omitempty" yaml:"max_age,omitempty"` // Empty when disabled
+	Auth             string  `json:"auth" yaml:"auth"`                           // none, basic or bearer
+	Temperature      float64 `json:"temperature_celsius,omitempty" yaml:"temperature_celsius,omitempty"`
+	SampleAgeSeconds float64 `json:"sample_age_seconds,omitempty" yaml:"sample_age_seconds,omitempty"`
+	Series           int     `json:"series,omitempty" yaml:"series,omitempty"` // Number of series returned, the highest value is used
+	Stale            int     `json:"stale,omitempty" yaml:"stale,omitempty"`   // Series dropped for being older than max_age
+	QuerySeconds     float64 `json:"query_seconds,omitempty" yaml:"query_seconds,omitempty"`
+}
+
+// checkFailed reports a failed connection check, keeping the ✗ text output.
+func checkFailed(cmd *cobra.Command, res prometheusCheckResult, what string, err error) {
+	if !structured() {
+		fmt.Fprintf(os.Stderr, "✗ %s: %v\n", what, err)
+		os.Exit(exitFailure)
+	}
+	failWith(cmd, exitFailure, fmt.Errorf("%s: %w", what, err), res, nil)
+}
+
 func getQueryOrDefault(query string) string {
 	if query == "" {
 		return `max(node_hwmon_temp_celsius{sensor="temp0"})` + " (default)"
@@ -103,6 +153,13 @@ func getTimeoutOrDefault(timeout string) string {
 	return timeout
 }
 
+func getMaxAgeOrDefault(maxAge string) string {
+	if maxAge == "" {
+		return "disabled (default)"
+	}
+	return maxAge
+}
+
 func init() {
 	rootCmd.AddCommand(checkPrometheusCmd)
 	checkPrometheusCmd.Flags().StringVar(&configPath, "config", config.DefaultConfigPath, "Path to configuration file")
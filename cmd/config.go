@@ -0,0 +1,488 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	showEffective bool
+	migrateDryRun bool
+	setReload     bool
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Validate and inspect the fan configuration",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration file for errors",
+	Long: `Parses the configuration file and its drop-ins strictly and reports
+every problem with its file and line number: YAML syntax errors, unknown
+keys (e.g. typos such as check_intervall), values of the wrong type, unset
+environment variables, unreadable secret files and invalid values. Values
+that are valid but probably not intended are reported as warnings, as is a
+file holding secrets that other users can read.
+
+Exits with status 1 if any error is found.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, issues := config.Check(configPath)
+		exposed, _ := config.SecretsExposed(configPath)
+		for _, file := range exposed {
+			issues = append(issues, config.Issue{
+				Severity: config.SeverityWarning,
+				File:     file,
+				Message:  "file contains secrets and is readable by other users, restrict it with chmod 600 or use password_file",
+			})
+		}
+
+		res := validateResult{File: configPath, Issues: []config.Issue{}}
+		for _, issue := range issues {
+			if issue.Severity == config.SeverityError {
+				res.Errors++
+			} else {
+				res.Warnings++
+			}
+			res.Issues = append(res.Issues, issue)
+			textf("%s\n", issue)
+		}
+		res.Valid = res.Errors == 0
+
+		if !res.Valid && structured() {
+			failWith(cmd, exitFailure, fmt.Errorf("%d error(s) found in the configuration", res.Errors), res, nil)
+		}
+		printResult(cmd, res, func() {
+			if len(issues) == 0 {
+				fmt.Printf("%s: OK\n", configPath)
+				return
+			}
+			fmt.Printf("\n%d error(s), %d warning(s)\n", res.Errors, res.Warnings)
+		})
+		if !res.Valid {
+			os.Exit(exitFailure)
+		}
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the configuration file and its drop-ins",
+	Long: `Prints the configuration file and its drop-ins. With --effective, prints
+the configuration the daemon would use on this host, with drop-ins, host
+sections and defaults applied and every value annotated with where it came
+from (the file and line, or "default"). Secrets are redacted.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !showEffective {
+			files, err := config.ConfigFiles(configPath)
+			if err != nil {
+				fail(cmd, exitFailure, err)
+			}
+			var res showResult
+			for _, file := range files {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					fail(cmd, exitFailure, fmt.Errorf("failed to read configuration: %w", err))
+				}
+				res.Files = append(res.Files, configFile{Path: file, Content: string(data)})
+			}
+			printResult(cmd, res, func() {
+				for i, file := range res.Files {
+					if len(files) > 1 {
+						if i > 0 {
+							fmt.Println()
+						}
+						fmt.Printf("# %s\n", file.Path)
+					}
+					fmt.Print(file.Content)
+				}
+			})
+			return
+		}
+
+		cfg, issues := config.Check(configPath)
+		if cfg == nil {
+			failWith(cmd, exitFailure, errors.New(issues[0].String()), nil, issues)
+		}
+		valid := true
+		for _, issue := range issues {
+			if issue.Severity == config.SeverityError {
+				valid = false
+				if !structured() {
+					fmt.Fprintf(os.Stderr, "Warning: configuration is invalid, run 'nanoctl config validate' for details\n")
+				}
+				break
+			}
+		}
+
+		out, err := config.Effective(cfg, configPath)
+		if err != nil {
+			fail(cmd, exitFailure, err)
+		}
+		printResult(cmd, effectiveResult(out, valid), func() {
+			os.Stdout.Write(out)
+		})
+	},
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the configuration file to the current layout",
+	Long: `Upgrades the configuration file and its drop-ins to the layout of this
+release and sets their version key. Files from older releases are also
+migrated in memory when loaded, so this only makes the upgrade permanent.
+
+Each changed file is backed up first to <file>.v<version>.bak; an
+existing backup is never replaced. Comments are kept, but the YAML is
+reformatted.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		files, err := config.ConfigFiles(configPath)
+		if err != nil {
+			fail(cmd, exitFailure, err)
+		}
+
+		res := migrateResult{DryRun: migrateDryRun, Files: []migratedFile{}}
+		unversioned := 0 // Drop-ins without a version key have the layout of the main file
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				fail(cmd, exitFailure, fmt.Errorf("failed to read configuration: %w", err))
+			}
+
+			migrated, from, err := config.Migrate(data, unversioned, file != configPath)
+			if err != nil {
+				fail(cmd, exitFailure, fmt.Errorf("%s: %w", file, err))
+			}
+			if file == configPath {
+				unversioned = from
+			}
+			result := migratedFile{Path: file, From: from, To: config.CurrentVersion}
+			if from == config.CurrentVersion {
+				textf("%s: already at version %d\n", file, from)
+				res.Files = append(res.Files, result)
+				continue
+			}
+
+			if migrateDryRun {
+				textf("# %s: version %d -> %d\n", file, from, config.CurrentVersion)
+				textf("%s", migrated)
+				result.Content = string(migrated)
+				res.Files = append(res.Files, result)
+				continue
+			}
+
+			// An earlier backup may be the only copy of the original file
+			backup := fmt.Sprintf("%s.v%d.bak", file, from)
+			if err := writeBackup(backup, data); err != nil {
+				fail(cmd, exitFailure, err)
+			}
+			if err := config.WriteFile(file, migrated); err != nil {
+				fail(cmd, exitFailure, err)
+			}
+			textf("%s: migrated from version %d to %d (backup: %s)\n", file, from, config.CurrentVersion, backup)
+			result.Backup = backup
+			res.Files = append(res.Files, result)
+		}
+		printResult(cmd, res, func() {})
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print a configuration value",
+	Long: `Prints the value of a key as the daemon uses it on this host, with
+drop-ins, host sections and defaults applied. Keys are written as paths
+such as temperature.target or metrics.outputs[0].url; a section is printed
+as YAML. Secrets are redacted.`,
+	Example: `  nanoctl config get temperature.target
+  nanoctl config get pid`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, issues := config.Check(configPath)
+		if cfg == nil {
+			failWith(cmd, exitFailure, errors.New(issues[0].String()), nil, issues)
+		}
+
+		value, err := config.Get(cfg, args[0])
+		if err != nil {
+			fail(cmd, exitFailure, err)
+		}
+		printResult(cmd, getResult{Key: args[0], Value: yamlValue([]byte(value))}, func() {
+			fmt.Println(value)
+		})
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a configuration value",
+	Long: `Sets a key in the configuration file. The value is parsed as YAML, so
+lists are written as [a, b]. Missing sections are created, and host
+settings are set as hosts.<host>.<key>.
+
+The whole configuration is validated with the change before the file is
+written; if it is invalid, the file is left unchanged. The file is replaced
+atomically and its comments are kept. The fan daemon reloads the file when
+it changes; with --reload it is also told to reload through systemd.`,
+	Example: `  sudo nanoctl config set pid.kp 4.5
+  sudo nanoctl config set metrics.resource.detectors "[host, os, container]"
+  sudo nanoctl config set hosts.cm5-nas.temperature.target 50 --reload`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, value := args[0], args[1]
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			fail(cmd, exitFailure, fmt.Errorf("failed to read configuration: %w", err))
+		}
+
+		updated, err := config.Set(data, key, value)
+		if err != nil {
+			fail(cmd, exitFailure, err)
+		}
+
+		_, issues := config.CheckContent(configPath, updated)
+		var errs []config.Issue
+		for _, issue := range issues {
+			if issue.Severity == config.SeverityError {
+				errs = append(errs, issue)
+				if !structured() {
+					fmt.Fprintln(os.Stderr, issue)
+				}
+			}
+		}
+		if len(errs) > 0 {
+			failWith(cmd, exitFailure, fmt.Errorf("the configuration would be invalid, %s was not changed", configPath), nil, errs)
+		}
+
+		if err := config.WriteFile(configPath, updated); err != nil {
+			fail(cmd, exitFailure, err)
+		}
+		shown := config.RedactValue(key, value)
+		res := setResult{File: configPath, Key: key, Value: yamlValue([]byte(shown))}
+		textf("%s: set %s to %s\n", configPath, key, shown)
+		if origin, err := config.OverriddenBy(configPath, key); err == nil && origin != "" {
+			res.OverriddenBy = origin
+			if !structured() {
+				fmt.Fprintf(os.Stderr, "Warning: %s is overridden on this host by %s\n", key, origin)
+			}
+		}
+
+		if setReload {
+			res.Reloaded = reloadDaemon(cmd)
+		}
+		printResult(cmd, res, func() {})
+	},
+}
+
+// reloadDaemon asks a running fan daemon to reload its configuration and
+// reports whether it did.
+func reloadDaemon(cmd *cobra.Command) bool {
+	if err := exec.Command("systemctl", "is-active", "--quiet", "nanoctl-fan").Run(); err != nil {
+		textf("nanoctl-fan is not running, not reloading\n")
+		return false
+	}
+	if err := reloadUnit("nanoctl-fan"); err != nil {
+		fail(cmd, exitFailure, fmt.Errorf("failed to reload nanoctl-fan: %w", err))
+	}
+	textf("Reloaded nanoctl-fan\n")
+	return true
+}
+
+// reloadUnit reloads unit through systemd. Units installed before
+// ExecReload was added to the service file can't be reloaded that way, so
+// their main process is sent SIGHUP instead.
+func reloadUnit(unit string) error {
+	out, err := exec.Command("systemctl", "show", "--property=CanReload,MainPID", unit).Output()
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", unit, err)
+	}
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[key] = strings.TrimSpace(value)
+		}
+	}
+
+	if props["CanReload"] == "yes" {
+		if out, err := exec.Command("systemctl", "reload", unit).CombinedOutput(); err != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	pid, err := strconv.Atoi(props["MainPID"])
+	if err != nil || pid <= 0 {
+		return fmt.Errorf("%s can't be reloaded and has no main process to send SIGHUP to", unit)
+	}
+	if err := syscall.Kill(pid, syscall.SIGHUP); err != nil {
+		return fmt.Errorf("failed to send SIGHUP to %s (pid %d): %w", unit, pid, err)
+	}
+	return nil
+}
+
+// writeBackup writes data to the new file path. An existing backup is never
+// replaced; a partly written one is removed.
+func writeBackup(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("backup %s already exists, move it away and run migrate again", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print a JSON Schema for the configuration file",
+	Long: `Prints a JSON Schema (draft 2020-12) describing fan.yaml, with the
+descriptions, allowed values, ranges and defaults nanoctl uses. Editors such
+as VS Code with the YAML extension use it for completion and validation:
+
+  nanoctl config schema > /etc/nanoctl/fan.schema.json
+
+then add this line at the top of fan.yaml:
+
+  # yaml-language-server: $schema=fan.schema.json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		schema, err := config.Schema()
+		if err != nil {
+			fail(cmd, exitFailure, err)
+		}
+		printResult(cmd, yamlValue(schema), func() {
+			os.Stdout.Write(schema)
+		})
+	},
+}
+
+// validateResult is the structured output of config validate.
+type validateResult struct {
+	File     string         `json:"file" yaml:"file"`
+	Valid    bool           `json:"valid" yaml:"valid"`
+	Errors   int            `json:"errors" yaml:"errors"`
+	Warnings int            `json:"warnings" yaml:"warnings"`
+	Issues   []config.Issue `json:"issues" yaml:"issues"`
+}
+
+// showResult is the structured output of config show.
+type showResult struct {
+	Files []configFile `json:"files" yaml:"files"`
+}
+
+type configFile struct {
+	Path    string `json:"path" yaml:"path"`
+	Content string `json:"content" yaml:"content"`
+}
+
+// showEffectiveResult is the structured output of config show --effective.
+type showEffectiveResult struct {
+	Valid   bool              `json:"valid" yaml:"valid"`
+	Config  any               `json:"config" yaml:"config"`
+	Origins map[string]string `json:"origins" yaml:"origins"` // Key path to "file:line" or "default"
+}
+
+// effectiveResult converts the annotated output of config.Effective.
+func effectiveResult(annotated []byte, valid bool) showEffectiveResult {
+	res := showEffectiveResult{Valid: valid, Origins: make(map[string]string)}
+	var root yaml.Node
+	if err := yaml.Unmarshal(annotated, &root); err != nil {
+		return res
+	}
+	collectOrigins(&root, "", res.Origins)
+	var cfg any
+	if err := root.Decode(&cfg); err == nil {
+		res.Config = cfg
+	}
+	return res
+}
+
+// collectOrigins moves the origin comments below node into origins.
+func collectOrigins(node *yaml.Node, path string, origins map[string]string) {
+	if node.LineComment != "" {
+		origins[path] = strings.TrimSpace(strings.TrimPrefix(node.LineComment, "#"))
+		node.LineComment = ""
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			collectOrigins(child, path, origins)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			collectOrigins(node.Content[i+1], key, origins)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			collectOrigins(item, fmt.Sprintf("%s[%d]", path, i), origins)
+		}
+	}
+}
+
+// getResult is the structured output of config get.
+type getResult struct {
+	Key   string `json:"key" yaml:"key"`
+	Value any    `json:"value" yaml:"value"`
+}
+
+// setResult is the structured output of config set.
+type setResult struct {
+	File         string `json:"file" yaml:"file"`
+	Key          string `json:"key" yaml:"key"`
+	Value        any    `json:"value" yaml:"value"`
+	OverriddenBy string `json:"overridden_by,omitempty" yaml:"overridden_by,omitempty"` // Drop-in or host section setting the key on this host
+	Reloaded     bool   `json:"reloaded" yaml:"reloaded"`
+}
+
+// migrateResult is the structured output of config migrate.
+type migrateResult struct {
+	DryRun bool           `json:"dry_run" yaml:"dry_run"`
+	Files  []migratedFile `json:"files" yaml:"files"`
+}
+
+type migratedFile struct {
+	Path    string `json:"path" yaml:"path"`
+	From    int    `json:"from" yaml:"from"`
+	To      int    `json:"to" yaml:"to"`
+	Backup  string `json:"backup,omitempty" yaml:"backup,omitempty"`
+	Content string `json:"content,omitempty" yaml:"content,omitempty"` // Migrated file, with --dry-run
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd, configShowCmd, configGetCmd, configSetCmd, configMigrateCmd, configSchemaCmd)
+
+	configCmd.PersistentFlags().StringVar(&configPath, "config", config.DefaultConfigPath, "Path to configuration file")
+	configMigrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Print the migrated files instead of writing them")
+	configSetCmd.Flags().BoolVar(&setReload, "reload", false, "Reload the fan daemon if it is running")
+	configShowCmd.Flags().BoolVar(&showEffective, "effective", false, "Print the configuration with defaults applied and the origin of each value")
+}
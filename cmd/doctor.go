@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/config"
+	"github.com/AlejandroPerez92/nanoctl/pkg/doctor"
+	"github.com/spf13/cobra"
+)
+
+var doctorRoot string
+
+// doctorResult is the structured output of doctor.
+type doctorResult struct {
+	Checks   []doctor.Check `json:"checks" yaml:"checks"`
+	Passed   int            `json:"passed" yaml:"passed"`
+	Warnings int            `json:"warnings" yaml:"warnings"`
+	Failed   int            `json:"failed" yaml:"failed"`
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the hardware, configuration and services for problems",
+	Long: `Checks everything the fan daemon depends on and prints how to fix what
+is wrong: the configuration, the systemd unit, the GPIO chip and line of
+software PWM, the sysfs chip and channel of hardware PWM, the PWM overlay
+in config.txt, the temperature sensor and hwmon files, the permissions of
+the files the daemon writes, and the Prometheus source and metrics
+endpoints.
+
+Run it with sudo to check the permissions the daemon has. Exits with
+status 1 if any check fails.`,
+	Example: `  sudo nanoctl doctor
+  nanoctl doctor --root ./testdata/cm5`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := configPath
+		if !cmd.Flags().Changed("config") {
+			path = filepath.Join(doctorRoot, config.DefaultConfigPath)
+		}
+
+		res := doctorResult{Checks: doctor.New(doctorRoot, path).Run(cmd.Context())}
+		for _, check := range res.Checks {
+			switch check.Status {
+			case doctor.StatusPass:
+				res.Passed++
+			case doctor.StatusWarn:
+				res.Warnings++
+			case doctor.StatusFail:
+				res.Failed++
+			}
+		}
+
+		if res.Failed > 0 && structured() {
+			failWith(cmd, exitFailure, fmt.Errorf("%d check(s) failed", res.Failed), res, nil)
+		}
+		printResult(cmd, res, func() {
+			for _, check := range res.Checks {
+				printCheck(check)
+			}
+			fmt.Printf("\n%d passed, %d warning(s), %d failed\n", res.Passed, res.Warnings, res.Failed)
+		})
+		if res.Failed > 0 {
+			os.Exit(exitFailure)
+		}
+	},
+}
+
+func printCheck(check doctor.Check) {
+	symbol := map[doctor.Status]string{
+		doctor.StatusPass: "✓",
+		doctor.StatusWarn: "!",
+		doctor.StatusFail: "✗",
+		doctor.StatusSkip: "-",
+	}[check.Status]
+	fmt.Printf("%s %-18s %s\n", symbol, check.Group+"."+check.Name, check.Message)
+	if check.Hint != "" {
+		fmt.Printf("  %-18s → %s\n", "", check.Hint)
+	}
+}
+
+func init() {
+	doctorCmd.Flags().StringVar(&configPath, "config", config.DefaultConfigPath, "Path to configuration file (under --root by default)")
+	doctorCmd.Flags().StringVar(&doctorRoot, "root", "/", "Check a copy of the system's /sys, /dev, /boot and /etc under this directory, e.g. a fake sysfs for tests")
+	rootCmd.AddCommand(doctorCmd)
+}
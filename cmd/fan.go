@@ -5,11 +5,19 @@ import (
 	"encoding/base64"
 	"fmt"
 	"github.com/AlejandroPerez92/nanoctl/pkg/config"
+	"github.com/AlejandroPerez92/nanoctl/pkg/control"
 	"github.com/AlejandroPerez92/nanoctl/pkg/fan"
+	"github.com/AlejandroPerez92/nanoctl/pkg/gpio"
+	"github.com/AlejandroPerez92/nanoctl/pkg/history"
+	"github.com/AlejandroPerez92/nanoctl/pkg/logging"
 	"github.com/AlejandroPerez92/nanoctl/pkg/metrics"
+	"github.com/AlejandroPerez92/nanoctl/pkg/mqtt"
 	"github.com/AlejandroPerez92/nanoctl/pkg/temperature"
+	"log/slog"
+	"math"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -44,12 +52,63 @@ func runFan(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("error loading configuration: %w", err)
 	}
 
+	handlers := []slog.Handler{slog.Default().Handler()}
+
+	// Daemon logs also go to the OTLP logs exporter once it is set up
+	if cfg.Metrics.Logs.Enabled {
+		level, err := logging.ParseLevel(logLevel)
+		if err != nil {
+			return err
+		}
+		handlers = append(handlers, metrics.NewLogHandler(level))
+	}
+
+	// Keep local history of temperature, duty and events
+	var historyStore *history.Store
+	if *cfg.History.Enabled {
+		historyStore, err = openHistory(cfg)
+		if err != nil {
+			slog.Error("Failed to open history, continuing without it", "error", err)
+		} else {
+			handlers = append(handlers, historyStore.Handler())
+		}
+	}
+
+	logger := slog.New(logging.Tee(handlers...))
+
+	exposed, _ := config.SecretsExposed(configPath)
+	for _, file := range exposed {
+		logger.Warn("Configuration file contains secrets and is readable by other users, restrict it with chmod 600 or use password_file",
+			"path", file)
+	}
+
+	// Connect to the MQTT broker if state publishing or the MQTT source is used
+	var mqttClient *mqtt.Client
+	if cfg.MQTT.Enabled || cfg.Temperature.Source.Primary == "mqtt" {
+		mqttClient, err = newMQTTClient(cfg)
+		switch {
+		case err != nil:
+			logger.Error("Failed to create MQTT client", "broker", cfg.MQTT.Broker, "error", err)
+		case mqttClient.Connected():
+			logger.Info("Connected to MQTT broker", "broker", cfg.MQTT.Broker)
+		default:
+			logger.Warn("MQTT broker unreachable, retrying in the background", "broker", cfg.MQTT.Broker)
+		}
+		if mqttClient != nil {
+			defer mqttClient.Close()
+		}
+	}
+
+	// Instruments created before the meter provider is set are forwarded to it once metrics are initialized
+	inst := metrics.NewInstruments(logger)
+
 	// Create temperature source with fallback
-	tempSource, err := createTemperatureSource(cfg)
+	tempSource, sourceName, err := createTemperatureSource(cfg, mqttClient, inst, logger)
 	if err != nil {
 		return fmt.Errorf("error creating temperature source: %w", err)
 	}
-	defer tempSource.Close()
+	// A configuration reload may replace the source
+	defer func() { tempSource.Close() }()
 
 	// Parse check interval duration
 	checkInterval, err := cfg.GetCheckIntervalDuration()
@@ -57,25 +116,35 @@ func runFan(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("error parsing check interval: %w", err)
 	}
 
+	// Create the filter stage between the source and the PID controller
+	filter, err := newTemperatureFilter(cfg)
+	if err != nil {
+		return fmt.Errorf("error creating temperature filter: %w", err)
+	}
+
 	// Convert to fan.MonitorConfig
 	monitorConfig := fan.MonitorConfig{
 		ChipName: cfg.GPIO.ChipName,
-		Pin:      cfg.GPIO.Pin,
+		Pin:      *cfg.GPIO.Pin,
 		PWM: fan.PWMConfig{
 			Mode:         cfg.PWM.Mode,
-			FrequencyKHz: cfg.PWM.FrequencyKHz,
+			FrequencyKHz: *cfg.PWM.FrequencyKHz,
 			Hardware: fan.HardwarePWMConfig{
 				Chip:     cfg.PWM.Hardware.Chip,
-				Channel:  cfg.PWM.Hardware.Channel,
+				Channel:  *cfg.PWM.Hardware.Channel,
 				Inverted: cfg.PWM.Hardware.Inverted,
 			},
 		},
-		TargetTemp:    cfg.Temperature.Target,
-		Kp:            cfg.PID.Kp,
-		Ki:            cfg.PID.Ki,
-		Kd:            cfg.PID.Kd,
+		TargetTemp:    *cfg.Temperature.Target,
+		Kp:            *cfg.PID.Kp,
+		Ki:            *cfg.PID.Ki,
+		Kd:            *cfg.PID.Kd,
 		CheckInterval: checkInterval,
 		TempSource:    tempSource,
+		SourceName:    sourceName,
+		Filter:        filter,
+		Metrics:       inst,
+		Logger:        logger,
 	}
 
 	// Handle graceful shutdown
@@ -85,53 +154,240 @@ func runFan(cmd *cobra.Command, args []string) error {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+
 	go func() {
 		<-sigChan
-		fmt.Println("\nReceived interrupt, shutting down...")
+		logger.Info("Received interrupt, shutting down")
 		cancel()
 	}()
 
-	// Initialize OTel Metrics if enabled
-	if cfg.Metrics.Enabled {
-		interval, err := time.ParseDuration(cfg.Metrics.Interval)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Invalid metrics interval '%s', defaulting to 10s: %v\n", cfg.Metrics.Interval, err)
-			interval = 10 * time.Second
+	// Initialize OTel Metrics if OTLP push, the Prometheus endpoint or another output is enabled
+	if cfg.Metrics.Enabled || cfg.Metrics.Prometheus.Enabled || len(cfg.Metrics.Outputs) > 0 {
+		metricsConfig := metrics.Config{
+			Resource: metrics.ResourceConfig{
+				Attributes: cfg.Metrics.Resource.Attributes,
+				Detectors:  cfg.Metrics.Resource.Detectors,
+			},
+			Logger: logger,
 		}
 
-		headers := make(map[string]string)
-		if cfg.Metrics.Auth != nil && cfg.Metrics.Auth.Username != "" {
-			auth := fmt.Sprintf("%s:%s", cfg.Metrics.Auth.Username, cfg.Metrics.Auth.Password)
-			encodedAuth := base64.StdEncoding.EncodeToString([]byte(auth))
-			headers["Authorization"] = "Basic " + encodedAuth
+		if cfg.Metrics.Enabled {
+			interval, err := time.ParseDuration(cfg.Metrics.Interval)
+			if err != nil {
+				logger.Warn("Invalid metrics interval, defaulting to 10s", "interval", cfg.Metrics.Interval, "error", err)
+				interval = 10 * time.Second
+			}
+
+			metricsConfig.OTLP = &metrics.OTLPConfig{
+				Endpoint: cfg.Metrics.Endpoint,
+				Insecure: cfg.Metrics.Insecure,
+				Interval: interval,
+				Headers:  metricsHeaders(cfg),
+				Logs:     cfg.Metrics.Logs.Enabled,
+			}
+
+			if cfg.Metrics.TLS != nil {
+				metricsConfig.OTLP.TLS = &metrics.TLSConfig{
+					CAFile:             cfg.Metrics.TLS.CAFile,
+					CertFile:           cfg.Metrics.TLS.CertFile,
+					KeyFile:            cfg.Metrics.TLS.KeyFile,
+					ServerName:         cfg.Metrics.TLS.ServerName,
+					InsecureSkipVerify: cfg.Metrics.TLS.InsecureSkipVerify,
+				}
+			}
 		}
 
-		shutdown, err := metrics.InitOTLP(ctx, cfg.Metrics.Endpoint, cfg.Metrics.Insecure, interval, headers)
+		if cfg.Metrics.Prometheus.Enabled {
+			metricsConfig.Prometheus = &metrics.PrometheusConfig{
+				Listen: cfg.Metrics.Prometheus.Listen,
+				Path:   cfg.Metrics.Prometheus.Path,
+			}
+		}
+
+		for _, output := range cfg.Metrics.Outputs {
+			metricsConfig.Outputs = append(metricsConfig.Outputs, metricsOutputConfig(output))
+		}
+
+		shutdown, err := metrics.Init(ctx, metricsConfig)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to initialize metrics: %v\n", err)
+			logger.Error("Failed to initialize metrics", "error", err)
 		} else {
-			fmt.Println("Metrics pushing enabled to", cfg.Metrics.Endpoint)
+			if metricsConfig.OTLP != nil {
+				logger.Info("Metrics pushing enabled", "endpoint", cfg.Metrics.Endpoint, "logs", metricsConfig.OTLP.Logs)
+			}
+			if metricsConfig.Prometheus != nil {
+				logger.Info("Prometheus metrics endpoint listening", "listen", cfg.Metrics.Prometheus.Listen, "path", cfg.Metrics.Prometheus.Path)
+			}
+			for _, output := range metricsConfig.Outputs {
+				logger.Info("Metrics output enabled", "type", output.Type, "url", output.URL, "interval", output.Interval)
+			}
 			defer func() {
 				if err := shutdown(context.Background()); err != nil {
-					fmt.Fprintf(os.Stderr, "Failed to shutdown metrics: %v\n", err)
+					logger.Error("Failed to shutdown metrics", "error", err)
 				}
 			}()
 		}
 	}
 
-	if err := fan.RunMonitor(ctx, monitorConfig); err != nil {
+	monitor := fan.NewMonitor(monitorConfig)
+
+	if historyStore != nil {
+		historyDone := make(chan struct{})
+		go func() {
+			defer close(historyDone)
+			recordHistory(ctx, historyStore, monitor, checkInterval, logger)
+		}()
+		defer func() {
+			// Stop the recorder before closing, also when the monitor failed to start
+			cancel()
+			<-historyDone
+			if err := historyStore.Close(); err != nil {
+				logger.Error("Failed to close history", "error", err)
+			}
+		}()
+		logger.Info("Recording history", "path", cfg.History.Path, "retention", cfg.History.Retention)
+	}
+
+	// Power commands from MQTT and the control socket share the slot state
+	power := control.NewPower(gpio.NewController(logger))
+
+	// Publish state and accept commands over MQTT if enabled
+	if cfg.MQTT.Enabled && mqttClient != nil {
+		publishInterval, err := time.ParseDuration(cfg.MQTT.PublishInterval)
+		if err != nil {
+			return fmt.Errorf("error parsing mqtt publish interval: %w", err)
+		}
+
+		bridge := mqtt.NewBridge(mqttClient, monitor, power, mqtt.BridgeConfig{
+			PublishInterval: publishInterval,
+			Slots:           cfg.MQTT.Slots,
+			Discovery: mqtt.DiscoveryConfig{
+				Enabled: cfg.MQTT.Discovery.Enabled,
+				Prefix:  cfg.MQTT.Discovery.Prefix,
+				NodeID:  discoveryNodeID(cfg.MQTT.ClientID),
+				Version: Version,
+			},
+			Logger: logger,
+		})
+		go func() {
+			if err := bridge.Run(ctx); err != nil {
+				logger.Error("MQTT bridge error", "error", err)
+			}
+		}()
+		logger.Info("MQTT state publishing enabled", "topic_prefix", cfg.MQTT.TopicPrefix)
+	}
+
+	// Serve the control socket used by 'nanoctl top' if enabled
+	if *cfg.Control.Enabled {
+		server := control.NewServer(monitor, power, control.Config{
+			Socket:         cfg.Control.Socket,
+			Slots:          cfg.Control.Slots,
+			SampleInterval: checkInterval,
+			Version:        Version,
+			Logger:         logger,
+		})
+		if err := server.Listen(); err != nil {
+			return err
+		}
+		go func() {
+			if err := server.Run(ctx); err != nil {
+				logger.Error("Control socket error", "error", err)
+			}
+		}()
+		logger.Info("Control socket enabled", "socket", cfg.Control.Socket, "slots", cfg.Control.Slots)
+	}
+
+	// Reload the configuration on SIGHUP or when the file changes
+	reloader := &configReloader{
+		path:       configPath,
+		startup:    cfg,
+		current:    cfg,
+		monitor:    monitor,
+		mqttClient: mqttClient,
+		inst:       inst,
+		logger:     logger,
+		filter:     filter,
+		source:     tempSource,
+	}
+	reloadDone := make(chan struct{})
+	go func() {
+		defer close(reloadDone)
+		reloader.run(ctx, hupChan)
+	}()
+	defer func() {
+		cancel()
+		<-reloadDone
+		tempSource = reloader.source
+	}()
+
+	if err := monitor.Run(ctx); err != nil {
 		return fmt.Errorf("fan monitor error: %w", err)
 	}
 
 	return nil
 }
 
-func createTemperatureSource(cfg *config.FanConfig) (temperature.Source, error) {
+func createTemperatureSource(cfg *config.FanConfig, mqttClient *mqtt.Client, inst *metrics.Instruments, logger *slog.Logger) (temperature.Source, string, error) {
 	primary := cfg.Temperature.Source.Primary
 	fallback := cfg.Temperature.Source.Fallback
 
-	// Try primary source first
-	if primary == "prometheus" {
+	// Use file source directly, there is nothing to fail over to
+	if primary == "file" {
+		fileSource := temperature.NewFileSource(cfg.Temperature.Source.File.Path)
+		logger.Info("Using file as temperature source", "path", cfg.Temperature.Source.File.Path)
+		return fileSource, primary, nil
+	}
+
+	fallbackSource, err := createFallbackSource(cfg, fallback)
+	if err != nil {
+		return nil, "", err
+	}
+
+	primarySource, err := createPrimarySource(cfg, mqttClient, logger)
+	if err != nil {
+		logger.Error("Failed to create temperature source", "source", primary, "error", err)
+		logger.Info("Using fallback temperature source", "source", fallback)
+		return fallbackSource, fallback, nil
+	}
+
+	// Switch between primary and fallback at runtime, retrying the primary periodically
+	source := temperature.NewFailoverSource(temperature.FailoverConfig{
+		Primary:      primarySource,
+		PrimaryName:  primary,
+		Fallback:     fallbackSource,
+		FallbackName: fallback,
+		OnReadError: func(source string, err error) {
+			inst.RecordReadError(context.Background(), source)
+			logger.Error("Temperature source failed", metrics.Event(metrics.EventReadError), "source", source, "error", err)
+		},
+		OnSwitch: func(from, to string, reason error) {
+			inst.RecordFailover(context.Background(), from, to)
+			logger.Warn("Switched temperature source", metrics.Event(metrics.EventSourceFailover), "from", from, "to", to)
+		},
+	})
+
+	// Wait for a first (usually retained) reading
+	if mqttSource, ok := primarySource.(*temperature.MQTTSource); ok {
+		if err := mqttSource.WaitForReading(5 * time.Second); err != nil {
+			logger.Warn("MQTT source test failed", "error", err)
+		}
+	}
+
+	// Test the sources; the monitor keeps retrying if both fail
+	if _, err := source.GetTemperature(); err != nil {
+		logger.Warn("Temperature source test failed", "error", err)
+	}
+
+	logger.Info("Using temperature source", "source", source.Active(), "fallback", fallback)
+	return source, primary, nil
+}
+
+// createPrimarySource creates the configured remote or plugin temperature source
+func createPrimarySource(cfg *config.FanConfig, mqttClient *mqtt.Client, logger *slog.Logger) (temperature.Source, error) {
+	switch cfg.Temperature.Source.Primary {
+	case "prometheus":
 		if cfg.Temperature.Source.Prometheus == nil {
 			return nil, fmt.Errorf("prometheus configuration is required when primary source is prometheus")
 		}
@@ -141,41 +397,241 @@ func createTemperatureSource(cfg *config.FanConfig) (temperature.Source, error)
 			Host:    cfg.Temperature.Source.Prometheus.Host,
 			Query:   cfg.Temperature.Source.Prometheus.Query,
 			Timeout: cfg.Temperature.Source.Prometheus.Timeout,
+			MaxAge:  cfg.Temperature.Source.Prometheus.MaxAge,
+			Logger:  logger,
 		}
 
 		if cfg.Temperature.Source.Prometheus.Auth != nil {
 			promConfig.Auth = temperature.AuthConfig{
 				Username: cfg.Temperature.Source.Prometheus.Auth.Username,
 				Password: cfg.Temperature.Source.Prometheus.Auth.Password,
+				Token:    cfg.Temperature.Source.Prometheus.Auth.Token,
 			}
 		}
 
-		promSource, err := temperature.NewPrometheusSource(promConfig)
+		source, err := temperature.NewPrometheusSource(promConfig)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create Prometheus source: %v\n", err)
-			return createFallbackSource(cfg, fallback)
+			return nil, err
 		}
+		return source, nil
 
-		// Test connection
-		_, err = promSource.GetTemperature()
+	case "http":
+		if cfg.Temperature.Source.HTTP == nil {
+			return nil, fmt.Errorf("http configuration is required when primary source is http")
+		}
+		source, err := temperature.NewHTTPSource(httpSourceConfig(cfg.Temperature.Source.HTTP))
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Prometheus connection test failed: %v\n", err)
-			fmt.Fprintf(os.Stderr, "Falling back to %s source\n", fallback)
-			return createFallbackSource(cfg, fallback)
+			return nil, err
 		}
+		return source, nil
 
-		fmt.Println("Using Prometheus as temperature source")
-		return promSource, nil
+	case "mqtt":
+		if cfg.Temperature.Source.MQTT == nil {
+			return nil, fmt.Errorf("mqtt source configuration is required when primary source is mqtt")
+		}
+		if mqttClient == nil {
+			return nil, fmt.Errorf("MQTT broker unavailable")
+		}
+		source, err := temperature.NewMQTTSource(mqttClient, temperature.MQTTConfig{
+			Topic:    cfg.Temperature.Source.MQTT.Topic,
+			Selector: cfg.Temperature.Source.MQTT.Selector,
+			Unit:     cfg.Temperature.Source.MQTT.Unit,
+			MaxAge:   cfg.Temperature.Source.MQTT.MaxAge,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return source, nil
+
+	case "command":
+		if cfg.Temperature.Source.Command == nil {
+			return nil, fmt.Errorf("command configuration is required when primary source is command")
+		}
+		source, err := temperature.NewCommandSource(temperature.CommandConfig{
+			Path:    cfg.Temperature.Source.Command.Path,
+			Args:    cfg.Temperature.Source.Command.Args,
+			Timeout: cfg.Temperature.Source.Command.Timeout,
+			Unit:    cfg.Temperature.Source.Command.Unit,
+			Stream:  cfg.Temperature.Source.Command.Stream,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return source, nil
 	}
 
-	// Use file source
-	if primary == "file" {
-		fileSource := temperature.NewFileSource(cfg.Temperature.Source.File.Path)
-		fmt.Println("Using file as temperature source")
-		return fileSource, nil
+	return nil, fmt.Errorf("unknown primary source type: %s", cfg.Temperature.Source.Primary)
+}
+
+func openHistory(cfg *config.FanConfig) (*history.Store, error) {
+	resolution, err := time.ParseDuration(cfg.History.Resolution)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing history resolution: %w", err)
+	}
+	retention, err := time.ParseDuration(cfg.History.Retention)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing history retention: %w", err)
+	}
+	flushInterval, err := time.ParseDuration(cfg.History.FlushInterval)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing history flush interval: %w", err)
+	}
+	return history.Open(history.Config{
+		Path:          cfg.History.Path,
+		Resolution:    resolution,
+		Retention:     retention,
+		FlushInterval: flushInterval,
+	})
+}
+
+// recordHistory samples the monitor state into the history store and
+// flushes it periodically until ctx is cancelled.
+func recordHistory(ctx context.Context, store *history.Store, monitor *fan.Monitor, interval time.Duration, logger *slog.Logger) {
+	sampleTicker := time.NewTicker(interval)
+	defer sampleTicker.Stop()
+	flushTicker := time.NewTicker(store.FlushInterval())
+	defer flushTicker.Stop()
+
+	var last time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sampleTicker.C:
+			state := monitor.State()
+			if state.UpdatedAt.IsZero() || state.UpdatedAt.Equal(last) {
+				continue
+			}
+			last = state.UpdatedAt
+			// Fan speed is not measured yet
+			store.Add(state.UpdatedAt, state.Temperature, state.DutyCycle, math.NaN())
+		case <-flushTicker.C:
+			if err := store.Flush(); err != nil {
+				logger.Error("Failed to write history", "error", err)
+			}
+		}
+	}
+}
+
+// metricsHeaders builds the OTLP export headers from metrics.headers and metrics.auth.
+// Authentication headers are applied last and override configured headers.
+func metricsHeaders(cfg *config.FanConfig) map[string]string {
+	headers := make(map[string]string, len(cfg.Metrics.Headers)+1)
+	for name, value := range cfg.Metrics.Headers {
+		headers[name] = value
 	}
 
-	return nil, fmt.Errorf("unknown primary source type: %s", primary)
+	auth := cfg.Metrics.Auth
+	switch {
+	case auth == nil:
+	case auth.Token != "":
+		headers["Authorization"] = "Bearer " + auth.Token
+	case auth.APIKey != "":
+		headers[auth.APIKeyHeader] = auth.APIKey
+	case auth.Username != "":
+		credentials := fmt.Sprintf("%s:%s", auth.Username, auth.Password)
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+	return headers
+}
+
+// newTemperatureFilter creates the filter stage, or nil when no filtering is configured.
+func newTemperatureFilter(cfg *config.FanConfig) (*temperature.Filter, error) {
+	if cfg.Temperature.Filter == (config.FilterConfig{}) {
+		return nil, nil
+	}
+	return temperature.NewFilter(temperature.FilterConfig{
+		MaxRate:      cfg.Temperature.Filter.MaxRate,
+		MedianWindow: cfg.Temperature.Filter.MedianWindow,
+		EMAAlpha:     cfg.Temperature.Filter.EMAAlpha,
+	})
+}
+
+// metricsOutputConfig maps a metrics output to the metrics package config struct
+func metricsOutputConfig(c config.MetricsOutputConfig) metrics.OutputConfig {
+	// Validated when the configuration is loaded
+	interval, _ := time.ParseDuration(c.Interval)
+
+	output := metrics.OutputConfig{
+		Type:     c.Type,
+		URL:      c.URL,
+		Interval: interval,
+		Prefix:   c.Prefix,
+		Tags:     c.Tags,
+		Org:      c.Org,
+		Bucket:   c.Bucket,
+		Database: c.Database,
+		Headers:  c.Headers,
+	}
+	if c.Auth != nil {
+		output.Token = c.Auth.Token
+		output.Username = c.Auth.Username
+		output.Password = c.Auth.Password
+	}
+	if c.TLS != nil {
+		output.TLS = &metrics.TLSConfig{
+			CAFile:             c.TLS.CAFile,
+			CertFile:           c.TLS.CertFile,
+			KeyFile:            c.TLS.KeyFile,
+			ServerName:         c.TLS.ServerName,
+			InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+		}
+	}
+	return output
+}
+
+// httpSourceConfig maps the HTTP source configuration to the temperature package config struct
+func httpSourceConfig(c *config.HTTPSourceConfig) temperature.HTTPConfig {
+	httpConfig := temperature.HTTPConfig{
+		URL:      c.URL,
+		Method:   c.Method,
+		Headers:  c.Headers,
+		Body:     c.Body,
+		Selector: c.Selector,
+		Unit:     c.Unit,
+		Timeout:  c.Timeout,
+	}
+	if c.Auth != nil {
+		httpConfig.Auth = temperature.AuthConfig{
+			Username: c.Auth.Username,
+			Password: c.Auth.Password,
+			Token:    c.Auth.Token,
+		}
+	}
+	if c.TLS != nil {
+		httpConfig.TLS = temperature.TLSConfig{
+			CAFile:             c.TLS.CAFile,
+			CertFile:           c.TLS.CertFile,
+			KeyFile:            c.TLS.KeyFile,
+			ServerName:         c.TLS.ServerName,
+			InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+		}
+	}
+	return httpConfig
+}
+
+func newMQTTClient(cfg *config.FanConfig) (*mqtt.Client, error) {
+	mqttConfig := mqtt.Config{
+		Broker:      cfg.MQTT.Broker,
+		ClientID:    cfg.MQTT.ClientID,
+		TopicPrefix: cfg.MQTT.TopicPrefix,
+	}
+	if cfg.MQTT.Auth != nil {
+		mqttConfig.Username = cfg.MQTT.Auth.Username
+		mqttConfig.Password = cfg.MQTT.Auth.Password
+	}
+	return mqtt.NewClient(mqttConfig)
+}
+
+// discoveryNodeID derives a Home Assistant safe node id from the MQTT client id
+func discoveryNodeID(clientID string) string {
+	id := strings.TrimPrefix(clientID, "nanoctl-")
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, id)
 }
 
 func createFallbackSource(cfg *config.FanConfig, fallback string) (temperature.Source, error) {
@@ -183,9 +639,7 @@ func createFallbackSource(cfg *config.FanConfig, fallback string) (temperature.S
 		return nil, fmt.Errorf("unsupported fallback source: %s", fallback)
 	}
 
-	fileSource := temperature.NewFileSource(cfg.Temperature.Source.File.Path)
-	fmt.Println("Using file as fallback temperature source")
-	return fileSource, nil
+	return temperature.NewFileSource(cfg.Temperature.Source.File.Path), nil
 }
 
 func init() {
@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/config"
+	"github.com/AlejandroPerez92/nanoctl/pkg/history"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyFile   string
+	historySince  string
+	historyUntil  string
+	historyFormat string
+	historyStep   time.Duration
+	historyEvents bool
+	historyWidth  int
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recorded temperature, fan duty and events",
+	Long: `Shows the history recorded by the fan daemon as a table, sparkline
+charts or CSV.
+
+Times accept a duration back from now (e.g. 30m, 24h, 7d), RFC 3339
+timestamps or "2006-01-02 15:04" in local time. The daemon writes its
+history in batches (history.flush_interval), so the last few minutes
+may not be included yet.`,
+	Example: `  nanoctl history
+  nanoctl history --since 7d --format chart
+  nanoctl history --since "2024-05-01 08:00" --until "2024-05-01 20:00" --step 5m
+  nanoctl history --since 7d --format csv > history.csv`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now()
+		from, err := parseTimeArg(historySince, now)
+		if err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+		to := now
+		if historyUntil != "" {
+			to, err = parseTimeArg(historyUntil, now)
+			if err != nil {
+				return fmt.Errorf("invalid --until: %w", err)
+			}
+		}
+		if !from.Before(to) {
+			return fmt.Errorf("--since must be before --until")
+		}
+
+		h, err := history.Read(historyPath())
+		if err != nil {
+			return err
+		}
+		h = h.Range(from, to)
+
+		step := historyStep
+		if step == 0 && historyFormat == "table" {
+			step = autoStep(to.Sub(from), h.Resolution)
+		}
+		samples := h.Downsample(step)
+
+		if structured() {
+			printResult(cmd, newHistoryResult(h, from, to, step, samples), nil)
+			return nil
+		}
+
+		out := cmd.OutOrStdout()
+		switch historyFormat {
+		case "table":
+			printHistoryTable(out, samples)
+		case "chart":
+			printHistoryChart(out, h, from, to)
+		case "csv":
+			return writeHistoryCSV(out, samples)
+		default:
+			return fmt.Errorf("invalid --format '%s' (expected table, chart or csv)", historyFormat)
+		}
+
+		if historyEvents && len(h.Events) > 0 {
+			fmt.Fprintln(out)
+			printHistoryEvents(out, h.Events)
+		}
+		return nil
+	},
+}
+
+// historyResult is the structured output of history. Values that were not
+// measured are null, except rpm, which is left out.
+type historyResult struct {
+	From        time.Time       `json:"from" yaml:"from"`
+	To          time.Time       `json:"to" yaml:"to"`
+	StepSeconds float64         `json:"step_seconds" yaml:"step_seconds"`
+	Samples     []historySample `json:"samples" yaml:"samples"`
+	Events      []historyEvent  `json:"events" yaml:"events"`
+}
+
+type historySample struct {
+	Time           time.Time `json:"time" yaml:"time"`
+	Temperature    *float64  `json:"temperature_celsius" yaml:"temperature_celsius"`
+	MaxTemperature *float64  `json:"max_temperature_celsius" yaml:"max_temperature_celsius"`
+	DutyCycle      *float64  `json:"duty_cycle_percent" yaml:"duty_cycle_percent"`
+	RPM            *float64  `json:"rpm,omitempty" yaml:"rpm,omitempty"`
+}
+
+type historyEvent struct {
+	Time    time.Time `json:"time" yaml:"time"`
+	Level   string    `json:"level" yaml:"level"`
+	Name    string    `json:"name" yaml:"name"`
+	Message string    `json:"message" yaml:"message"`
+}
+
+func newHistoryResult(h *history.History, from, to time.Time, step time.Duration, samples []history.Sample) historyResult {
+	res := historyResult{
+		From:        from.UTC(),
+		To:          to.UTC(),
+		StepSeconds: max(step, h.Resolution).Seconds(),
+		Samples:     make([]historySample, 0, len(samples)),
+		Events:      make([]historyEvent, 0, len(h.Events)),
+	}
+	for _, s := range samples {
+		res.Samples = append(res.Samples, historySample{
+			Time:           s.Time.UTC(),
+			Temperature:    measured(s.Temperature),
+			MaxTemperature: measured(s.MaxTemperature),
+			DutyCycle:      measured(s.DutyCycle),
+			RPM:            measured(s.RPM),
+		})
+	}
+	if !historyEvents {
+		return res
+	}
+	for _, e := range h.Events {
+		res.Events = append(res.Events, historyEvent{e.Time.UTC(), e.Level.String(), e.Name, e.Message})
+	}
+	return res
+}
+
+// measured returns nil for NaN, which JSON can't represent.
+func measured(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
+
+// historyPath returns --file, or the path from the configuration file.
+func historyPath() string {
+	if historyFile != "" {
+		return historyFile
+	}
+	cfg, err := config.LoadFanConfig(configPath)
+	if err != nil {
+		return history.DefaultPath
+	}
+	return cfg.History.Path
+}
+
+// parseTimeArg parses a duration back from now (with a "d" suffix for days),
+// an RFC 3339 timestamp or a local "2006-01-02 15:04" time.
+func parseTimeArg(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.ParseFloat(days, 64); err == nil {
+			return now.Add(-time.Duration(n * 24 * float64(time.Hour))), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("'%s' is not a duration or time", s)
+}
+
+// autoStep picks a readable step so a table has at most about 48 rows.
+func autoStep(span, resolution time.Duration) time.Duration {
+	steps := []time.Duration{
+		time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+		time.Hour, 2 * time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+	}
+	if span/resolution <= 48 {
+		return resolution
+	}
+	for _, step := range steps {
+		if step >= resolution && span/step <= 48 {
+			return step
+		}
+	}
+	return steps[len(steps)-1]
+}
+
+func formatValue(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "-"
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+// measuresRPM reports whether any sample has a fan speed; without a
+// tachometer none has.
+func measuresRPM(samples []history.Sample) bool {
+	return slices.ContainsFunc(samples, func(s history.Sample) bool { return !math.IsNaN(s.RPM) })
+}
+
+func printHistoryTable(w io.Writer, samples []history.Sample) {
+	if len(samples) == 0 {
+		fmt.Fprintln(w, "No history recorded in this range.")
+		return
+	}
+	rpm := measuresRPM(samples)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	if rpm {
+		fmt.Fprintln(tw, "TIME\tTEMP °C\tMAX °C\tDUTY %\tRPM\t")
+	} else {
+		fmt.Fprintln(tw, "TIME\tTEMP °C\tMAX °C\tDUTY %\t")
+	}
+	for _, s := range samples {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t",
+			s.Time.Local().Format("2006-01-02 15:04:05"),
+			formatValue(s.Temperature),
+			formatValue(s.MaxTemperature),
+			formatValue(s.DutyCycle),
+		)
+		if rpm {
+			fmt.Fprintf(tw, "%s\t", formatValue(s.RPM))
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+func printHistoryChart(w io.Writer, h *history.History, from, to time.Time) {
+	if len(h.Samples) == 0 {
+		fmt.Fprintln(w, "No history recorded in this range.")
+		return
+	}
+
+	// Use the actual data span so a short history isn't squashed to one side
+	if first := h.Samples[0].Time; first.After(from) {
+		from = first
+	}
+	width := max(historyWidth, 10)
+	step := max(to.Sub(from)/time.Duration(width), h.Resolution)
+	samples := h.Downsample(step)
+
+	temps := make([]float64, len(samples))
+	maxTemps := make([]float64, len(samples))
+	duties := make([]float64, len(samples))
+	for i, s := range samples {
+		temps[i], maxTemps[i], duties[i] = s.Temperature, s.MaxTemperature, s.DutyCycle
+	}
+
+	fmt.Fprintf(w, "%s → %s (%s per column)\n\n",
+		from.Local().Format("2006-01-02 15:04"), to.Local().Format("2006-01-02 15:04"), step.Round(time.Second))
+	printSparkline(w, "Temp °C", temps)
+	printSparkline(w, "Max °C", maxTemps)
+	printSparkline(w, "Duty %", duties)
+}
+
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+func printSparkline(w io.Writer, label string, values []float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if hi > lo {
+			i = int((v - lo) / (hi - lo) * float64(len(sparkBars)-1))
+		}
+		b.WriteRune(sparkBars[i])
+	}
+	fmt.Fprintf(w, "%-8s %6s %s %s\n", label, formatValue(lo), b.String(), formatValue(hi))
+}
+
+func writeHistoryCSV(w io.Writer, samples []history.Sample) error {
+	rpm := measuresRPM(samples)
+	cw := csv.NewWriter(w)
+	columns := []string{"time", "temperature_celsius", "max_temperature_celsius", "duty_cycle_percent"}
+	if rpm {
+		columns = append(columns, "rpm")
+	}
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, s := range samples {
+		record := []string{
+			s.Time.UTC().Format(time.RFC3339),
+			strconv.FormatFloat(s.Temperature, 'f', 2, 64),
+			strconv.FormatFloat(s.MaxTemperature, 'f', 2, 64),
+			strconv.FormatFloat(s.DutyCycle, 'f', 2, 64),
+		}
+		switch {
+		case rpm && !math.IsNaN(s.RPM):
+			record = append(record, strconv.FormatFloat(s.RPM, 'f', 0, 64))
+		case rpm:
+			record = append(record, "")
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func printHistoryEvents(w io.Writer, events []history.Event) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tLEVEL\tEVENT\tMESSAGE")
+	for _, e := range events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.Level, e.Name, e.Message)
+	}
+	tw.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+
+	historyCmd.Flags().StringVar(&configPath, "config", config.DefaultConfigPath, "Path to configuration file")
+	historyCmd.Flags().StringVar(&historyFile, "file", "", "History file (default: history.path from the configuration)")
+	historyCmd.Flags().StringVar(&historySince, "since", "24h", "Start of the range: duration back from now or a time")
+	historyCmd.Flags().StringVar(&historyUntil, "until", "", "End of the range (default: now)")
+	historyCmd.Flags().StringVar(&historyFormat, "format", "table", "Text output format: table, chart or csv")
+	historyCmd.Flags().DurationVar(&historyStep, "step", 0, "Aggregate samples into steps of this duration (default: automatic for tables)")
+	historyCmd.Flags().BoolVar(&historyEvents, "events", true, "Also list events (not with --format csv)")
+	historyCmd.Flags().IntVar(&historyWidth, "width", 60, "Number of columns of the charts")
+}
@@ -2,51 +2,298 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 	"github.com/warthog618/go-gpiocdev"
 )
 
+// infoConsumer is the consumer label of the lines requested to read their
+// values.
+const infoConsumer = "nanoctl-info"
+
+var infoLines, infoValues bool
+
+// infoResult is the structured output of info.
+type infoResult struct {
+	Chips []chipInfo `json:"chips" yaml:"chips"`
+}
+
+// chipInfo describes one GPIO chip.
+type chipInfo struct {
+	Path      string     `json:"path" yaml:"path"`
+	Name      string     `json:"name,omitempty" yaml:"name,omitempty"`
+	Label     string     `json:"label,omitempty" yaml:"label,omitempty"`
+	Lines     int        `json:"lines" yaml:"lines"`
+	LineInfo  []lineInfo `json:"line_info,omitempty" yaml:"line_info,omitempty"`   // With --lines
+	LineError string     `json:"line_error,omitempty" yaml:"line_error,omitempty"` // Set if a line couldn't be read
+	Error     string     `json:"error,omitempty" yaml:"error,omitempty"`           // Set if the chip couldn't be opened
+}
+
+// lineInfo describes one line of a GPIO chip.
+type lineInfo struct {
+	Offset    int    `json:"offset" yaml:"offset"`
+	Name      string `json:"name,omitempty" yaml:"name,omitempty"`
+	Used      bool   `json:"used" yaml:"used"`
+	Consumer  string `json:"consumer,omitempty" yaml:"consumer,omitempty"`
+	Direction string `json:"direction" yaml:"direction"` // input, output or unknown
+	ActiveLow bool   `json:"active_low" yaml:"active_low"`
+	Bias      string `json:"bias" yaml:"bias"`   // unknown, disabled, pull-up or pull-down
+	Drive     string `json:"drive" yaml:"drive"` // push-pull, open-drain or open-source
+	Edge      string `json:"edge" yaml:"edge"`   // none, rising, falling or both
+	Debounce  string `json:"debounce,omitempty" yaml:"debounce,omitempty"`
+	Value     *int   `json:"value,omitempty" yaml:"value,omitempty"` // With --values, only for lines not in use
+}
+
 var infoCmd = &cobra.Command{
-	Use:   "info",
+	Use:   "info [chip]",
 	Short: "List available GPIO chips and their info",
-	Long:  `Scans the system for available GPIO character devices and prints their details.`,
+	Long: `Scans the system for available GPIO character devices and prints their details.
+
+With --lines, every line is listed with its name, direction, active-low
+flag, bias, drive, edge detection and consumer.
+
+With --values, the current value of the lines that are not in use is also
+read. Reading a value requests the line briefly without changing its
+direction; this is seen by anything watching the line, and on some chips
+(such as the Raspberry Pi 5 RP1) a request can reset the pin
+configuration.`,
+	Example: `  nanoctl info
+  nanoctl info --lines gpiochip0
+  nanoctl info --values gpiochip0
+  nanoctl info watch gpiochip0 17 GPIO18`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Scanning for GPIO chips...")
+		var paths []string
+		if len(args) == 1 {
+			paths = []string{chipName(args[0])}
+		} else {
+			textf("Scanning for GPIO chips...\n")
 
-		// gpiocdev.Chips() returns a list of paths
-		chips := gpiocdev.Chips()
+			// gpiocdev.Chips() returns a list of paths
+			paths = gpiocdev.Chips()
+			sort.Strings(paths)
+		}
 
-		if len(chips) == 0 {
-			fmt.Println("No GPIO chips found.")
-			return
+		chips := make([]chipInfo, 0, len(paths))
+		for _, path := range paths {
+			chips = append(chips, readChipInfo(path, infoLines || infoValues, infoValues))
 		}
+		if len(args) == 1 && chips[0].Error != "" {
+			fail(cmd, exitFailure, fmt.Errorf("%s: %s", chips[0].Path, chips[0].Error))
+		}
+
+		printResult(cmd, infoResult{chips}, func() {
+			if len(chips) == 0 {
+				fmt.Println("No GPIO chips found.")
+				return
+			}
+			for _, chip := range chips {
+				printChipInfo(chip, infoValues)
+			}
+		})
+	},
+}
 
-		sort.Strings(chips)
+// chipName returns the device name of a chip given as gpiochip0, 0 or
+// /dev/gpiochip0.
+func chipName(arg string) string {
+	if _, err := strconv.Atoi(arg); err == nil {
+		return "gpiochip" + arg
+	}
+	return strings.TrimPrefix(arg, "/dev/")
+}
 
-		for _, path := range chips {
-			printChipInfo(path)
+// readChipInfo returns the details of the chip at path, with the info of
+// its lines if lines is set and the values of unused lines if values is.
+func readChipInfo(path string, lines, values bool) chipInfo {
+	c, err := gpiocdev.NewChip(path, gpiocdev.WithConsumer(infoConsumer))
+	if err != nil {
+		return chipInfo{Path: path, Error: err.Error()}
+	}
+	defer c.Close()
+
+	chip := chipInfo{Path: path, Name: c.Name, Label: c.Label, Lines: c.Lines()}
+	if lines {
+		chip.LineInfo, err = readLineInfo(c, values)
+		if err != nil {
+			chip.LineError = err.Error()
 		}
-	},
+	}
+	return chip
+}
+
+// readLineInfo returns the info of every line of c and, with values, the
+// values of those not in use.
+func readLineInfo(c *gpiocdev.Chip, values bool) ([]lineInfo, error) {
+	lines := make([]lineInfo, 0, c.Lines())
+	for offset := 0; offset < c.Lines(); offset++ {
+		info, err := c.LineInfo(offset)
+		if err != nil {
+			return lines, fmt.Errorf("line %d: %w", offset, err)
+		}
+		line := newLineInfo(info)
+		if values && !info.Used {
+			line.Value = readLineValue(c, offset)
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
 }
 
-func printChipInfo(path string) {
-	c, err := gpiocdev.NewChip(path)
+// readLineValue requests the line as is and returns its value, or nil if it
+// can't be read. The request is visible to line watchers and may reset the
+// pin on some chips, see infoCmd.
+func readLineValue(c *gpiocdev.Chip, offset int) *int {
+	l, err := c.RequestLine(offset, gpiocdev.AsIs)
+	if err != nil {
+		return nil
+	}
+	defer l.Close()
+
+	value, err := l.Value()
 	if err != nil {
-		fmt.Printf("  %s: [Error opening] %v\n", path, err)
+		return nil
+	}
+	return &value
+}
+
+func newLineInfo(info gpiocdev.LineInfo) lineInfo {
+	line := lineInfo{
+		Offset:    info.Offset,
+		Name:      info.Name,
+		Used:      info.Used,
+		Consumer:  info.Consumer,
+		Direction: "unknown",
+		ActiveLow: info.Config.ActiveLow,
+		Bias:      "unknown",
+		Drive:     "push-pull",
+		Edge:      "none",
+	}
+	switch info.Config.Direction {
+	case gpiocdev.LineDirectionInput:
+		line.Direction = "input"
+	case gpiocdev.LineDirectionOutput:
+		line.Direction = "output"
+	}
+	switch info.Config.Bias {
+	case gpiocdev.LineBiasDisabled:
+		line.Bias = "disabled"
+	case gpiocdev.LineBiasPullUp:
+		line.Bias = "pull-up"
+	case gpiocdev.LineBiasPullDown:
+		line.Bias = "pull-down"
+	}
+	switch info.Config.Drive {
+	case gpiocdev.LineDriveOpenDrain:
+		line.Drive = "open-drain"
+	case gpiocdev.LineDriveOpenSource:
+		line.Drive = "open-source"
+	}
+	switch info.Config.EdgeDetection {
+	case gpiocdev.LineEdgeRising:
+		line.Edge = "rising"
+	case gpiocdev.LineEdgeFalling:
+		line.Edge = "falling"
+	case gpiocdev.LineEdgeBoth:
+		line.Edge = "both"
+	}
+	if info.Config.Debounced {
+		line.Debounce = info.Config.DebouncePeriod.String()
+	}
+	return line
+}
+
+// printChipInfo prints chip, with a value column if values were read.
+func printChipInfo(chip chipInfo, values bool) {
+	if chip.Error != "" {
+		fmt.Printf("  %s: [Error opening] %s\n", chip.Path, chip.Error)
 		return
 	}
-	defer c.Close()
+	fmt.Printf("  %s: %s (%s) - %d lines\n", filepath.Base(chip.Path), chip.Name, chip.Label, chip.Lines)
+	if len(chip.LineInfo) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		header := "    LINE\tNAME\tDIRECTION\tACTIVE\tBIAS\tDRIVE\tEDGE\tCONSUMER"
+		if values {
+			header += "\tVALUE"
+		}
+		fmt.Fprintln(w, header)
+		for _, line := range chip.LineInfo {
+			row := fmt.Sprintf("    %d\t%s\t%s\t%s\t%s\t%s\t%s\t%s", line.Offset, orDash(line.Name),
+				line.Direction, activeLevel(line), line.Bias, lineDrive(line), line.Edge, lineConsumer(line))
+			if values {
+				row += "\t" + lineValue(line)
+			}
+			fmt.Fprintln(w, row)
+		}
+		w.Flush()
+	}
+	if chip.LineError != "" {
+		fmt.Printf("    [Error reading lines] %s\n", chip.LineError)
+	}
+}
+
+// lineSummary describes the state of line on one line, for watch.
+func lineSummary(line lineInfo) string {
+	parts := []string{line.Direction, "active-" + activeLevel(line), "bias=" + line.Bias}
+	if line.Direction == "output" {
+		parts = append(parts, "drive="+line.Drive)
+	}
+	if line.Edge != "none" {
+		parts = append(parts, "edge="+line.Edge)
+	}
+	if line.Debounce != "" {
+		parts = append(parts, "debounce="+line.Debounce)
+	}
+	return fmt.Sprintf("%d %s: %s consumer=%s", line.Offset, orDash(line.Name), strings.Join(parts, " "), lineConsumer(line))
+}
+
+func activeLevel(line lineInfo) string {
+	if line.ActiveLow {
+		return "low"
+	}
+	return "high"
+}
+
+// lineDrive returns the drive of outputs; it doesn't apply to inputs.
+func lineDrive(line lineInfo) string {
+	if line.Direction != "output" {
+		return "-"
+	}
+	return line.Drive
+}
+
+func lineConsumer(line lineInfo) string {
+	switch {
+	case line.Consumer != "":
+		return line.Consumer
+	case line.Used:
+		return "[kernel]"
+	}
+	return "-"
+}
 
-	name := c.Name
-	label := c.Label
-	lines := c.Lines
+func lineValue(line lineInfo) string {
+	if line.Value == nil {
+		return "-"
+	}
+	return strconv.Itoa(*line.Value)
+}
 
-	fmt.Printf("  %s: %s (%s) - %d lines\n", filepath.Base(path), name, label, lines)
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
 }
 
 func init() {
+	infoCmd.Flags().BoolVar(&infoLines, "lines", false, "List the lines of each chip")
+	infoCmd.Flags().BoolVar(&infoValues, "values", false, "Also read the values of unused lines by requesting them (implies --lines)")
 	rootCmd.AddCommand(infoCmd)
 }
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/warthog618/go-gpiocdev"
+)
+
+// lineEvent is the structured output of each event of info watch.
+type lineEvent struct {
+	Time  time.Time `json:"time" yaml:"time"`
+	Chip  string    `json:"chip" yaml:"chip"`
+	Event string    `json:"event" yaml:"event"` // requested, released or reconfigured
+	Line  lineInfo  `json:"line" yaml:"line"`
+}
+
+var infoWatchCmd = &cobra.Command{
+	Use:   "watch [chip] [line...]",
+	Short: "Stream changes to GPIO line info",
+	Long: `Prints an event whenever a GPIO line is requested, released or
+reconfigured, until interrupted.
+
+Lines are given by offset or name. Without lines, every line of the chip
+is watched; without a chip, every line of every chip. With --output json
+or yaml, each event is printed as its own document. Requires Linux 5.7 or
+later.`,
+	Example: `  nanoctl info watch
+  nanoctl info watch gpiochip0 17 GPIO18
+  nanoctl info watch gpiochip0 -o json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var names []string
+		if len(args) > 0 {
+			names = []string{chipName(args[0])}
+		} else {
+			names = gpiocdev.Chips()
+			sort.Strings(names)
+		}
+
+		events := make(chan lineEvent, 64)
+		watched := 0
+		for _, name := range names {
+			c, err := gpiocdev.NewChip(name)
+			if err != nil {
+				if len(args) > 0 {
+					fail(cmd, exitFailure, fmt.Errorf("%s: %w", name, err))
+				}
+				fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", name, err)
+				continue
+			}
+			defer c.Close()
+
+			var offsets []int
+			if len(args) > 1 {
+				offsets, err = lineOffsets(c, args[1:])
+				if err != nil {
+					fail(cmd, exitUsage, fmt.Errorf("%s: %w", name, err))
+				}
+			} else {
+				for offset := 0; offset < c.Lines(); offset++ {
+					offsets = append(offsets, offset)
+				}
+			}
+
+			chip := name
+			handler := func(e gpiocdev.LineInfoChangeEvent) {
+				events <- newLineEvent(chip, e)
+			}
+			for _, offset := range offsets {
+				if _, err := c.WatchLineInfo(offset, handler); err != nil {
+					fail(cmd, exitFailure, fmt.Errorf("%s: failed to watch line %d: %w", name, offset, err))
+				}
+			}
+			watched += len(offsets)
+		}
+		if watched == 0 {
+			fail(cmd, exitFailure, fmt.Errorf("no GPIO lines to watch"))
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		textf("Watching %d lines. Press Ctrl+C to stop.\n", watched)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-events:
+				if structured() {
+					printEvent(cmd, e)
+					continue
+				}
+				fmt.Printf("%s %s %-12s %s\n", e.Time.Format("2006-01-02 15:04:05.000"),
+					filepath.Base(e.Chip), e.Event, lineSummary(e.Line))
+			}
+		}
+	},
+}
+
+// lineOffsets resolves lines given by offset or name on c.
+func lineOffsets(c *gpiocdev.Chip, lines []string) ([]int, error) {
+	offsets := make([]int, 0, len(lines))
+	for _, line := range lines {
+		offset, err := strconv.Atoi(line)
+		if err != nil {
+			if offset, err = c.FindLine(line); err != nil {
+				return nil, fmt.Errorf("no line named '%s'", line)
+			}
+		} else if offset < 0 || offset >= c.Lines() {
+			return nil, fmt.Errorf("line %d out of range (the chip has %d lines)", offset, c.Lines())
+		}
+		offsets = append(offsets, offset)
+	}
+	return offsets, nil
+}
+
+func newLineEvent(chip string, e gpiocdev.LineInfoChangeEvent) lineEvent {
+	event := lineEvent{Time: time.Now(), Chip: chip, Line: newLineInfo(e.Info)}
+	switch e.Type {
+	case gpiocdev.LineRequested:
+		event.Event = "requested"
+	case gpiocdev.LineReleased:
+		event.Event = "released"
+	case gpiocdev.LineReconfigured:
+		event.Event = "reconfigured"
+	}
+	return event
+}
+
+func init() {
+	infoCmd.AddCommand(infoWatchCmd)
+}
@@ -3,11 +3,15 @@ package cmd
 import (
 	"fmt"
 	"github.com/AlejandroPerez92/nanoctl/pkg/gpio"
-	"os"
 
 	"github.com/spf13/cobra"
 )
 
+// initResult is the structured output of init.
+type initResult struct {
+	SwitchReset bool `json:"switch_reset" yaml:"switch_reset"`
+}
+
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize the Nano Cluster hardware",
@@ -15,14 +19,15 @@ var initCmd = &cobra.Command{
 Currently, this resets the switch chip (GPIO 0).
 This should be run once after booting the carrier board.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		controller := gpio.NewController()
+		controller := gpio.NewController(nil)
 
 		if err := controller.ResetSwitch(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error initializing hardware: %v\n", err)
-			os.Exit(1)
+			fail(cmd, exitFailure, fmt.Errorf("failed to initialize hardware: %w", err))
 		}
 
-		fmt.Println("Initialization complete.")
+		printResult(cmd, initResult{SwitchReset: true}, func() {
+			fmt.Println("Initialization complete.")
+		})
 	},
 }
 
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by --output.
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// Exit codes, documented in doc/commands.md.
+const (
+	exitFailure = 1 // The command ran and failed
+	exitUsage   = 2 // Invalid command, flags or arguments
+)
+
+var outputFormat string
+
+// result is the document a command prints with --output json or yaml. Data
+// is specific to each command; Error is set when the exit code is not 0.
+type result struct {
+	Command string       `json:"command" yaml:"command"`
+	OK      bool         `json:"ok" yaml:"ok"`
+	Data    any          `json:"data,omitempty" yaml:"data,omitempty"`
+	Error   *resultError `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type resultError struct {
+	Message  string         `json:"message" yaml:"message"`
+	ExitCode int            `json:"exit_code" yaml:"exit_code"`
+	Issues   []config.Issue `json:"issues,omitempty" yaml:"issues,omitempty"` // Configuration problems, if any
+}
+
+// validateOutputFormat checks the --output flag.
+func validateOutputFormat() error {
+	switch outputFormat {
+	case outputText, outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("invalid --output '%s' (expected text, json or yaml)", outputFormat)
+}
+
+// structured reports whether commands print documents instead of text.
+func structured() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// commandName returns the name of cmd without the program name, e.g.
+// "config get".
+func commandName(cmd *cobra.Command) string {
+	_, name, _ := strings.Cut(cmd.CommandPath(), " ")
+	return name
+}
+
+// textf prints progress and results in text output only.
+func textf(format string, args ...any) {
+	if !structured() {
+		fmt.Printf(format, args...)
+	}
+}
+
+// printResult prints data as the result of cmd in structured output, or
+// calls text otherwise.
+func printResult(cmd *cobra.Command, data any, text func()) {
+	if !structured() {
+		text()
+		return
+	}
+	writeDocument(result{Command: commandName(cmd), OK: true, Data: data})
+}
+
+// fail reports err and exits with code. In text output the error goes to
+// stderr; in structured output a document goes to stdout.
+func fail(cmd *cobra.Command, code int, err error) {
+	failWith(cmd, code, err, nil, nil)
+}
+
+// failWith is fail with the data and configuration issues to include in the
+// document.
+func failWith(cmd *cobra.Command, code int, err error, data any, issues []config.Issue) {
+	if !structured() {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(code)
+	}
+	writeDocument(result{
+		Command: commandName(cmd),
+		Data:    data,
+		Error:   &resultError{Message: err.Error(), ExitCode: code, Issues: issues},
+	})
+	os.Exit(code)
+}
+
+// printEvent prints data as one event of a command that streams its
+// results: a JSON document per line, or a YAML document stream.
+func printEvent(cmd *cobra.Command, data any) {
+	doc := result{Command: commandName(cmd), OK: true, Data: data}
+	if outputFormat == outputYAML {
+		fmt.Println("---")
+		writeDocument(doc)
+		return
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding output: %v\n", err)
+		os.Exit(exitFailure)
+	}
+}
+
+// writeDocument prints v to stdout in the --output format.
+func writeDocument(v any) {
+	var err error
+	if outputFormat == outputYAML {
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err = encoder.Encode(v); err == nil {
+			err = encoder.Close()
+		}
+	} else {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		err = encoder.Encode(v)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding output: %v\n", err)
+		os.Exit(exitFailure)
+	}
+}
+
+// yamlValue decodes a YAML document into plain values that can be written
+// as JSON.
+func yamlValue(data []byte) any {
+	var v any
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return string(data)
+	}
+	return v
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "Output format: text, json or yaml")
+}
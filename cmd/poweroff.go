@@ -3,8 +3,6 @@ package cmd
 import (
 	"fmt"
 	"github.com/AlejandroPerez92/nanoctl/pkg/gpio"
-	"os"
-	"strconv"
 
 	"github.com/spf13/cobra"
 )
@@ -19,41 +17,33 @@ This simulates two short presses of the power button for graceful shutdown
 Use the --force flag for a hard power off (8 second hold).`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		slot := args[0]
-		boardType, _ := cmd.Flags().GetString("board")
+		slot, boardType := slotArgs(cmd, args)
 		force, _ := cmd.Flags().GetBool("force")
 
-		// Validate board type
-		if boardType != "cm5" {
-			fmt.Fprintf(os.Stderr, "Error: unsupported board type '%s'. Only 'cm5' is supported.\n", boardType)
-			os.Exit(1)
-		}
-
-		controller := gpio.NewController()
-
-		// Parse the slot argument
-		slotNum, err := strconv.Atoi(slot)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: slot must be a number, got '%s'\n", slot)
-			os.Exit(1)
-		}
+		controller := gpio.NewController(nil)
 
+		var err error
 		if force {
-			err = controller.ForceOff(slotNum, gpio.BoardType(boardType))
+			err = controller.ForceOff(slot, boardType)
 		} else {
-			err = controller.PowerOff(slotNum, gpio.BoardType(boardType))
+			err = controller.PowerOff(slot, boardType)
 		}
 
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error powering off slot %s: %v\n", slot, err)
-			os.Exit(1)
+			fail(cmd, exitFailure, fmt.Errorf("slot %d: %w", slot, err))
 		}
 
+		action := "poweroff"
 		if force {
-			fmt.Printf("Successfully force powered off slot %s\n", slot)
-		} else {
-			fmt.Printf("Successfully powered off slot %s\n", slot)
+			action = "force_poweroff"
 		}
+		printResult(cmd, powerResult{slot, string(boardType), action}, func() {
+			if force {
+				fmt.Printf("Successfully force powered off slot %d\n", slot)
+			} else {
+				fmt.Printf("Successfully powered off slot %d\n", slot)
+			}
+		})
 	},
 }
 
@@ -3,8 +3,6 @@ package cmd
 import (
 	"fmt"
 	"github.com/AlejandroPerez92/nanoctl/pkg/gpio"
-	"os"
-	"strconv"
 
 	"github.com/spf13/cobra"
 )
@@ -16,30 +14,16 @@ var powerOnCmd = &cobra.Command{
 This simulates a single short press of the power button.`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		slot := args[0]
-		boardType, _ := cmd.Flags().GetString("board")
+		slot, boardType := slotArgs(cmd, args)
 
-		// Validate board type
-		if boardType != "cm5" {
-			fmt.Fprintf(os.Stderr, "Error: unsupported board type '%s'. Only 'cm5' is supported.\n", boardType)
-			os.Exit(1)
+		controller := gpio.NewController(nil)
+		if err := controller.PowerOn(slot, boardType); err != nil {
+			fail(cmd, exitFailure, fmt.Errorf("slot %d: %w", slot, err))
 		}
 
-		controller := gpio.NewController()
-
-		// Parse the slot argument
-		slotNum, err := strconv.Atoi(slot)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: slot must be a number, got '%s'\n", slot)
-			os.Exit(1)
-		}
-
-		if err := controller.PowerOn(slotNum, gpio.BoardType(boardType)); err != nil {
-			fmt.Fprintf(os.Stderr, "Error powering on slot %s: %v\n", slot, err)
-			os.Exit(1)
-		}
-
-		fmt.Printf("Successfully powered on slot %s\n", slot)
+		printResult(cmd, powerResult{slot, string(boardType), "poweron"}, func() {
+			fmt.Printf("Successfully powered on slot %d\n", slot)
+		})
 	},
 }
 
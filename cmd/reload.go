@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/config"
+	"github.com/AlejandroPerez92/nanoctl/pkg/fan"
+	"github.com/AlejandroPerez92/nanoctl/pkg/metrics"
+	"github.com/AlejandroPerez92/nanoctl/pkg/mqtt"
+	"github.com/AlejandroPerez92/nanoctl/pkg/temperature"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce groups the several file events an editor produces on save.
+const reloadDebounce = 500 * time.Millisecond
+
+// configSection is a part of the configuration compared on reload.
+type configSection struct {
+	name string
+	live bool // Applied without a restart
+	get  func(*config.FanConfig) any
+}
+
+// configSections covers every field of config.FanConfig.
+var configSections = []configSection{
+	{"temperature.target", true, func(c *config.FanConfig) any { return c.Temperature.Target }},
+	{"temperature.source", true, func(c *config.FanConfig) any { return c.Temperature.Source }},
+	{"temperature.filter", true, func(c *config.FanConfig) any { return c.Temperature.Filter }},
+	{"pid", true, func(c *config.FanConfig) any { return c.PID }},
+	{"monitor", true, func(c *config.FanConfig) any { return c.Monitor }},
+	{"gpio", false, func(c *config.FanConfig) any { return c.GPIO }},
+	{"pwm", false, func(c *config.FanConfig) any { return c.PWM }},
+	{"metrics", false, func(c *config.FanConfig) any { return c.Metrics }},
+	{"mqtt", false, func(c *config.FanConfig) any { return c.MQTT }},
+	{"history", false, func(c *config.FanConfig) any { return c.History }},
+	{"control", false, func(c *config.FanConfig) any { return c.Control }},
+}
+
+// configReloader reloads the configuration file on SIGHUP or when it
+// changes, and applies the settings the monitor can change while running.
+// Changes to other sections are reported until the daemon is restarted.
+type configReloader struct {
+	path       string
+	startup    *config.FanConfig // Configuration the daemon was started with
+	current    *config.FanConfig // Last applied configuration
+	monitor    *fan.Monitor
+	mqttClient *mqtt.Client
+	inst       *metrics.Instruments
+	logger     *slog.Logger
+
+	filter *temperature.Filter
+	source temperature.Source // Temperature source in use; the caller closes it after run returns
+}
+
+// run handles reload triggers until ctx is cancelled.
+func (r *configReloader) run(ctx context.Context, hup <-chan os.Signal) {
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+
+	// Watch the directory, editors often replace the file instead of writing to it
+	dropInDir := config.DropInDir(r.path)
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = watcher.Add(filepath.Dir(r.path))
+	}
+	if err != nil {
+		r.logger.Warn("Not watching the configuration file, reload with SIGHUP", "path", r.path, "error", err)
+	} else {
+		defer watcher.Close()
+		events, errs = watcher.Events, watcher.Errors
+
+		// Drop-ins are optional, a directory created later needs SIGHUP
+		if err := watcher.Add(dropInDir); err != nil && !os.IsNotExist(err) {
+			r.logger.Warn("Not watching the drop-in directory, reload with SIGHUP", "path", dropInDir, "error", err)
+		}
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.reload("signal")
+		case event := <-events:
+			if r.isConfigFile(event.Name, dropInDir) && event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) {
+				debounce = time.After(reloadDebounce)
+			}
+		case err := <-errs:
+			r.logger.Warn("Configuration file watch error", "error", err)
+		case <-debounce:
+			debounce = nil
+			r.reload("file change")
+		}
+	}
+}
+
+// isConfigFile reports whether name is the configuration file or a drop-in.
+func (r *configReloader) isConfigFile(name, dropInDir string) bool {
+	if filepath.Dir(name) == filepath.Clean(dropInDir) {
+		return filepath.Ext(name) == ".yaml"
+	}
+	return filepath.Base(name) == filepath.Base(r.path)
+}
+
+// reload loads and validates the configuration file and applies it. An
+// invalid file is reported and the current configuration stays in use.
+func (r *configReloader) reload(trigger string) {
+	next, err := config.LoadFanConfig(r.path)
+	if err != nil {
+		r.logger.Error("Configuration reload failed, keeping the current configuration",
+			metrics.Event(metrics.EventConfigReload), "trigger", trigger, "error", err)
+		return
+	}
+
+	var live, restart []string
+	for _, section := range configSections {
+		switch {
+		case section.live && !reflect.DeepEqual(section.get(r.current), section.get(next)):
+			live = append(live, section.name)
+		case !section.live && !reflect.DeepEqual(section.get(r.startup), section.get(next)):
+			restart = append(restart, section.name)
+		}
+	}
+
+	if len(live) > 0 {
+		if err := r.apply(next, live); err != nil {
+			r.logger.Error("Configuration reload failed, keeping the current configuration",
+				metrics.Event(metrics.EventConfigReload), "trigger", trigger, "error", err)
+			return
+		}
+	}
+	changed := len(live) > 0 || !reflect.DeepEqual(r.current, next)
+	r.current = next
+
+	switch {
+	case !changed:
+		r.logger.Debug("Configuration unchanged", "trigger", trigger)
+	case len(restart) > 0:
+		r.logger.Warn("Configuration reloaded, some changes require a restart",
+			metrics.Event(metrics.EventConfigReload), "trigger", trigger, "applied", live, "restart_required", restart)
+	default:
+		r.logger.Info("Configuration reloaded",
+			metrics.Event(metrics.EventConfigReload), "trigger", trigger, "applied", live)
+	}
+}
+
+// apply hands the changed live sections to the monitor.
+func (r *configReloader) apply(next *config.FanConfig, changed []string) error {
+	checkInterval, err := next.GetCheckIntervalDuration()
+	if err != nil {
+		return fmt.Errorf("error parsing check interval: %w", err)
+	}
+
+	filter := r.filter
+	if slices.Contains(changed, "temperature.filter") {
+		filter, err = newTemperatureFilter(next)
+		if err != nil {
+			return fmt.Errorf("error creating temperature filter: %w", err)
+		}
+	}
+
+	update := fan.MonitorConfig{
+		TargetTemp:    *next.Temperature.Target,
+		Kp:            *next.PID.Kp,
+		Ki:            *next.PID.Ki,
+		Kd:            *next.PID.Kd,
+		CheckInterval: checkInterval,
+		Filter:        filter,
+	}
+
+	if slices.Contains(changed, "temperature.source") {
+		source, name, err := createTemperatureSource(next, r.mqttClient, r.inst, r.logger)
+		if err != nil {
+			return fmt.Errorf("error creating temperature source: %w", err)
+		}
+		// The monitor closes the previous source
+		update.TempSource, update.SourceName = source, name
+		r.source = source
+	}
+
+	r.filter = filter
+	r.monitor.Reload(update)
+	return nil
+}
@@ -1,28 +1,62 @@
 package cmd
 
 import (
-	"fmt"
-	"os"
+	"log/slog"
 
+	"github.com/AlejandroPerez92/nanoctl/pkg/logging"
 	"github.com/spf13/cobra"
 )
 
+var (
+	logLevel  string
+	logFormat string
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "nanoctl",
 	Short: "NanoCtl - Manage your Nano Cluster",
 	Long: `NanoCtl is a CLI tool for managing Nano Cluster nodes.
 It provides commands to power on, power off, and reset CM5 nodes
 in your cluster using GPIO controls.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutputFormat(); err != nil {
+			return err
+		}
+
+		// Logs go to stderr so stdout stays clean for command output
+		handler, err := logging.NewHandler(logging.Config{
+			Level:  logLevel,
+			Format: logFormat,
+		})
+		if err != nil {
+			return err
+		}
+		slog.SetDefault(slog.New(handler))
+
+		// Arguments and flags are valid, later errors are failures
+		cmd.SilenceUsage = true
+		return nil
+	},
+	SilenceErrors: true,
 }
 
 // Execute runs the root command
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	cmd, err := rootCmd.ExecuteC()
+	if err == nil {
+		return
+	}
+	if validateOutputFormat() != nil {
+		outputFormat = outputText
+	}
+	code := exitUsage
+	if cmd.SilenceUsage {
+		code = exitFailure
 	}
+	fail(cmd, code, err)
 }
 
 func init() {
-	// Global flags can be added here if needed
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logging.FormatAuto, "Log format: text, json, journald or auto (journald under systemd, text otherwise)")
 }
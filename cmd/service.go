@@ -17,9 +17,12 @@ After=multi-user.target
 
 [Service]
 ExecStart={{.BinaryPath}} fan
+ExecReload=/bin/kill -HUP $MAINPID
 Restart=always
 User=root
 Type=simple
+StateDirectory=nanoctl
+RuntimeDirectory=nanoctl
 
 [Install]
 WantedBy=multi-user.target
@@ -29,6 +32,14 @@ type ServiceConfig struct {
 	BinaryPath string
 }
 
+// installResult is the structured output of install-service.
+type installResult struct {
+	Binary        string `json:"binary" yaml:"binary"`
+	ConfigFile    string `json:"config_file" yaml:"config_file"`
+	ConfigCreated bool   `json:"config_created" yaml:"config_created"`
+	ServiceFile   string `json:"service_file" yaml:"service_file"`
+}
+
 var installServiceCmd = &cobra.Command{
 	Use:   "install-service",
 	Short: "Install nanoctl-fan as a systemd service",
@@ -45,35 +56,33 @@ Example:
   sudo nanoctl install-service`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("Error: This command must be run as root (sudo).")
-			os.Exit(1)
+			fail(cmd, exitFailure, fmt.Errorf("this command must be run as root (sudo)"))
 		}
 
 		// Get absolute path to the current binary
 		binaryPath, err := os.Executable()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to get binary path: %v\n", err)
-			os.Exit(1)
+			fail(cmd, exitFailure, fmt.Errorf("failed to get binary path: %w", err))
 		}
 
 		// Resolve symlinks just in case
 		binaryPath, err = filepath.EvalSymlinks(binaryPath)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to resolve binary path: %v\n", err)
-			os.Exit(1)
+			fail(cmd, exitFailure, fmt.Errorf("failed to resolve binary path: %w", err))
 		}
 
 		// Check if config file exists, if not create default
 		configPath := config.DefaultConfigPath
+		configCreated := false
 		if _, err := os.Stat(configPath); os.IsNotExist(err) {
-			fmt.Printf("Creating default configuration at %s...\n", configPath)
+			textf("Creating default configuration at %s...\n", configPath)
 			if err := config.CreateDefaultConfig(configPath); err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to create config file: %v\n", err)
-				os.Exit(1)
+				fail(cmd, exitFailure, fmt.Errorf("failed to create config file: %w", err))
 			}
-			fmt.Println("Configuration file created.")
+			configCreated = true
+			textf("Configuration file created.\n")
 		} else {
-			fmt.Printf("Configuration file already exists at %s\n", configPath)
+			textf("Configuration file already exists at %s\n", configPath)
 		}
 
 		// Create systemd service file
@@ -82,45 +91,44 @@ Example:
 		}
 
 		servicePath := "/etc/systemd/system/nanoctl-fan.service"
-		fmt.Printf("Creating service file at %s...\n", servicePath)
+		textf("Creating service file at %s...\n", servicePath)
 
 		f, err := os.Create(servicePath)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create service file: %v\n", err)
-			os.Exit(1)
+			fail(cmd, exitFailure, fmt.Errorf("failed to create service file: %w", err))
 		}
 		defer f.Close()
 
 		tmpl, err := template.New("service").Parse(serviceTemplate)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to parse template: %v\n", err)
-			os.Exit(1)
+			fail(cmd, exitFailure, fmt.Errorf("failed to parse template: %w", err))
 		}
 
 		if err := tmpl.Execute(f, serviceConfig); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to write service file: %v\n", err)
-			os.Exit(1)
+			fail(cmd, exitFailure, fmt.Errorf("failed to write service file: %w", err))
 		}
 
-		fmt.Println("Service file created.")
-		fmt.Println("Reloading systemd daemon...")
+		textf("Service file created.\n")
+		textf("Reloading systemd daemon...\n")
 		if err := exec.Command("systemctl", "daemon-reload").Run(); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to reload daemon: %v\n", err)
-			os.Exit(1)
+			fail(cmd, exitFailure, fmt.Errorf("failed to reload daemon: %w", err))
 		}
 
-		fmt.Println("Enabling and starting nanoctl-fan service...")
+		textf("Enabling and starting nanoctl-fan service...\n")
 		if err := exec.Command("systemctl", "enable", "--now", "nanoctl-fan").Run(); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to enable/start service: %v\n", err)
-			os.Exit(1)
+			fail(cmd, exitFailure, fmt.Errorf("failed to enable/start service: %w", err))
 		}
 
-		fmt.Println("\nSuccess! Fan controller is now running as a service.")
-		fmt.Printf("Configuration file: %s\n", configPath)
-		fmt.Println("Check status with: systemctl status nanoctl-fan")
-		fmt.Println("View logs with: journalctl -u nanoctl-fan -f")
-		fmt.Printf("\nTo customize settings, edit %s and restart the service:\n", configPath)
-		fmt.Println("  sudo systemctl restart nanoctl-fan")
+		res := installResult{Binary: binaryPath, ConfigFile: configPath, ConfigCreated: configCreated, ServiceFile: servicePath}
+		printResult(cmd, res, func() {
+			fmt.Println("\nSuccess! Fan controller is now running as a service.")
+			fmt.Printf("Configuration file: %s\n", configPath)
+			fmt.Println("Check status with: systemctl status nanoctl-fan")
+			fmt.Println("View logs with: journalctl -u nanoctl-fan -f")
+			fmt.Printf("\nTo customize settings, edit %s. Changes are picked up automatically;\n", configPath)
+			fmt.Println("GPIO, PWM, metrics, MQTT and history settings need a restart:")
+			fmt.Println("  sudo systemctl restart nanoctl-fan")
+		})
 	},
 }
 
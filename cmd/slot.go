@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/gpio"
+	"github.com/spf13/cobra"
+)
+
+// powerResult is the structured output of poweron, poweroff and reset.
+type powerResult struct {
+	Slot   int    `json:"slot" yaml:"slot"`
+	Board  string `json:"board" yaml:"board"`
+	Action string `json:"action" yaml:"action"` // poweron, poweroff, force_poweroff or reset
+}
+
+// slotArgs parses the slot argument and the --board flag of the power
+// commands, exiting with a usage error if they are invalid.
+func slotArgs(cmd *cobra.Command, args []string) (int, gpio.BoardType) {
+	slot := args[0]
+	boardType, _ := cmd.Flags().GetString("board")
+
+	// Validate board type
+	if boardType != "cm5" {
+		fail(cmd, exitUsage, fmt.Errorf("unsupported board type '%s'. Only 'cm5' is supported.", boardType))
+	}
+
+	// Parse the slot argument
+	slotNum, err := strconv.Atoi(slot)
+	if err != nil {
+		fail(cmd, exitUsage, fmt.Errorf("slot must be a number, got '%s'", slot))
+	}
+	return slotNum, gpio.BoardType(boardType)
+}
@@ -0,0 +1,436 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/config"
+	"github.com/AlejandroPerez92/nanoctl/pkg/control"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	topSocket   string
+	topInterval time.Duration
+)
+
+// overrideStep is the change of the fan override per + or - key press.
+const overrideStep = 5
+
+// topActions are the slot power keys, with the confirmation prompt of each.
+var topActions = map[string]struct{ action, prompt string }{
+	"o": {control.ActionPowerOn, "Power on slot %d?"},
+	"f": {control.ActionPowerOff, "Shut down slot %d?"},
+	"F": {control.ActionForceOff, "Force slot %d off? The node loses power without shutting down."},
+	"r": {control.ActionReset, "Reset slot %d? The node restarts without shutting down."},
+}
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Show a live dashboard of the fan daemon",
+	Long: `Shows a full-screen dashboard of the running fan daemon: the temperature
+and duty cycle of the last minutes, the active temperature source, the PID
+terms and the power state of each slot. Keys override the fan or power
+cycle a slot, after a confirmation.
+
+top talks to the daemon over its control socket (control.socket), so it
+doesn't open the GPIO lines itself. Only root can connect to the socket.
+
+Keys:
+  + / -      raise or lower the fan override by 5%
+  m          run the fan at 100%
+  a          return the fan to automatic control
+  ↑ / ↓      select a slot (also k / j)
+  o          power on the selected slot
+  f          shut down the selected slot
+  F          force the selected slot off
+  r          reset the selected slot
+  q          quit
+
+With --output json or yaml, prints the state of the daemon once.`,
+	Example: `  sudo nanoctl top
+  sudo nanoctl top --interval 500ms
+  sudo nanoctl top -o json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		socket, err := topSocketPath()
+		if err != nil {
+			fail(cmd, exitFailure, err)
+		}
+		client := control.NewClient(socket)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		status, err := client.Status(ctx)
+		if err != nil {
+			fail(cmd, exitFailure, err)
+		}
+		if structured() {
+			printResult(cmd, status, nil)
+			return
+		}
+		if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+			fail(cmd, exitUsage, errors.New("top needs a terminal; use --output json for the state of the daemon"))
+		}
+		if topInterval <= 0 {
+			fail(cmd, exitUsage, errors.New("--interval must be positive"))
+		}
+
+		view := &topView{client: client, status: status}
+		if err := view.run(ctx); err != nil {
+			fail(cmd, exitFailure, err)
+		}
+	},
+}
+
+// topSocketPath returns --socket, or the socket from the configuration file.
+func topSocketPath() (string, error) {
+	if topSocket != "" {
+		return topSocket, nil
+	}
+	cfg, err := config.LoadFanConfig(configPath)
+	if err != nil {
+		return "", fmt.Errorf("%w; pass the control socket with --socket", err)
+	}
+	if !*cfg.Control.Enabled {
+		return "", fmt.Errorf("the control socket is disabled in %s, set control.enabled to true and restart nanoctl-fan", configPath)
+	}
+	return cfg.Control.Socket, nil
+}
+
+// topView is the state of the dashboard.
+type topView struct {
+	client  *control.Client
+	status  *control.Status
+	samples []control.Sample
+
+	selected int // Index in status.Slots
+	confirm  *topConfirm
+	message  string // Result of the last key press or refresh
+}
+
+// topConfirm is a slot power action waiting for confirmation.
+type topConfirm struct {
+	slot           int
+	action, prompt string
+}
+
+// run draws the dashboard until q is pressed or ctx is cancelled.
+func (v *topView) run(ctx context.Context) error {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set up the terminal: %w", err)
+	}
+	// Alternate screen and hidden cursor, restored on exit
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		term.Restore(fd, state)
+	}()
+
+	keys := make(chan []byte)
+	go readKeys(keys)
+
+	resize := make(chan os.Signal, 1)
+	signal.Notify(resize, syscall.SIGWINCH)
+	defer signal.Stop(resize)
+
+	ticker := time.NewTicker(topInterval)
+	defer ticker.Stop()
+
+	v.refresh(ctx)
+	for {
+		v.draw()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-resize:
+		case <-ticker.C:
+			v.refresh(ctx)
+		case input, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, key := range parseKeys(input) {
+				if v.handleKey(ctx, key) {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// readKeys sends what is typed to keys until stdin is closed.
+func readKeys(keys chan<- []byte) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		keys <- append([]byte(nil), buf[:n]...)
+	}
+}
+
+// parseKeys splits input into key names: "up", "down", "esc", "enter",
+// "ctrl-c" or the typed character.
+func parseKeys(input []byte) []string {
+	var keys []string
+	for len(input) > 0 {
+		switch {
+		case len(input) >= 3 && input[0] == 0x1b && (input[1] == '[' || input[1] == 'O'):
+			switch input[2] {
+			case 'A':
+				keys = append(keys, "up")
+			case 'B':
+				keys = append(keys, "down")
+			}
+			input = input[3:]
+			continue
+		case input[0] == 0x1b:
+			keys = append(keys, "esc")
+		case input[0] == 0x03:
+			keys = append(keys, "ctrl-c")
+		case input[0] == '\r' || input[0] == '\n':
+			keys = append(keys, "enter")
+		default:
+			r, size := utf8.DecodeRune(input)
+			keys = append(keys, string(r))
+			input = input[size:]
+			continue
+		}
+		input = input[1:]
+	}
+	return keys
+}
+
+// refresh fetches the state and samples from the daemon. The last state is
+// kept on errors.
+func (v *topView) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	status, err := v.client.Status(ctx)
+	if err != nil {
+		v.message = "Error: " + err.Error()
+		return
+	}
+	samples, err := v.client.Samples(ctx)
+	if err != nil {
+		v.message = "Error: " + err.Error()
+		return
+	}
+	v.status, v.samples = status, samples
+	if v.selected >= len(status.Slots) {
+		v.selected = max(len(status.Slots)-1, 0)
+	}
+}
+
+// handleKey acts on a key press and returns true to quit.
+func (v *topView) handleKey(ctx context.Context, key string) bool {
+	if key == "ctrl-c" {
+		return true
+	}
+	if v.confirm != nil {
+		confirm := v.confirm
+		v.confirm = nil
+		if key != "y" && key != "Y" {
+			v.message = "Cancelled"
+			return false
+		}
+		v.power(ctx, confirm)
+		return false
+	}
+
+	switch key {
+	case "q", "Q":
+		return true
+	case "+", "=":
+		v.setOverride(ctx, v.status.DutyCycle+overrideStep)
+	case "-", "_":
+		v.setOverride(ctx, v.status.DutyCycle-overrideStep)
+	case "m":
+		v.setOverride(ctx, 100)
+	case "a":
+		v.clearOverride(ctx)
+	case "up", "k":
+		v.selected = max(v.selected-1, 0)
+	case "down", "j":
+		v.selected = max(min(v.selected+1, len(v.status.Slots)-1), 0)
+	default:
+		action, ok := topActions[key]
+		if !ok {
+			return false
+		}
+		if len(v.status.Slots) == 0 {
+			v.message = "No slots accept power commands; list them in control.slots"
+			return false
+		}
+		slot := v.status.Slots[v.selected].Slot
+		v.confirm = &topConfirm{slot: slot, action: action.action, prompt: fmt.Sprintf(action.prompt, slot)}
+	}
+	return false
+}
+
+func (v *topView) setOverride(ctx context.Context, dc float64) {
+	// Keep the override on multiples of the step
+	dc = math.Max(0, math.Min(100, math.Round(dc/overrideStep)*overrideStep))
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	status, err := v.client.SetOverride(ctx, dc)
+	if err != nil {
+		v.message = "Error: " + err.Error()
+		return
+	}
+	v.status = status
+	v.message = fmt.Sprintf("Fan overridden to %.0f%%", dc)
+}
+
+func (v *topView) clearOverride(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	status, err := v.client.ClearOverride(ctx)
+	if err != nil {
+		v.message = "Error: " + err.Error()
+		return
+	}
+	v.status = status
+	v.message = "Fan back to automatic control"
+}
+
+func (v *topView) power(ctx context.Context, confirm *topConfirm) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := v.client.Power(ctx, confirm.slot, confirm.action); err != nil {
+		v.message = "Error: " + err.Error()
+		return
+	}
+	v.message = fmt.Sprintf("Sent %s to slot %d", confirm.action, confirm.slot)
+	v.refresh(ctx)
+}
+
+// draw redraws the whole screen.
+func (v *topView) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	lines := v.render(width)
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	for i, line := range lines {
+		if utf8.RuneCountInString(line) > width {
+			lines[i] = string([]rune(line)[:width])
+		}
+	}
+	// In raw mode a newline doesn't return the cursor to the first column
+	fmt.Print("\x1b[H\x1b[2J" + strings.Join(lines, "\r\n"))
+}
+
+// render returns the lines of the dashboard for a terminal width columns
+// wide.
+func (v *topView) render(width int) []string {
+	s := v.status
+	mode := "auto"
+	if s.Override {
+		mode = "override"
+	}
+	updated := "never"
+	if !s.UpdatedAt.IsZero() {
+		updated = s.UpdatedAt.Local().Format("15:04:05")
+	}
+
+	lines := []string{
+		fmt.Sprintf("nanoctl top - fan daemon %s, updated %s", s.Version, updated),
+		"",
+		fmt.Sprintf("Temperature  %.1f°C (raw %.1f°C), target %.1f°C", s.Temperature, s.RawTemperature, s.PID.Setpoint),
+		fmt.Sprintf("Source       %s", orDash(s.Source)),
+		fmt.Sprintf("Fan          %.0f%% (%s)", s.DutyCycle, mode),
+		fmt.Sprintf("PID          error %+.2f  P %+.2f  I %+.2f  D %+.2f  raw %.2f  output %.2f",
+			s.PID.Error, s.PID.P, s.PID.I, s.PID.D, s.PID.Raw, s.PID.Output),
+		"",
+	}
+
+	// Label, two values and the spaces between them take 23 columns
+	samples := v.samples
+	if n := width - 23; n > 0 && len(samples) > n {
+		samples = samples[len(samples)-n:]
+	}
+	temps := make([]float64, len(samples))
+	duties := make([]float64, len(samples))
+	for i, sample := range samples {
+		temps[i], duties[i] = sample.Temperature, sample.DutyCycle
+	}
+	if len(samples) > 0 {
+		span := samples[len(samples)-1].Time.Sub(samples[0].Time).Round(time.Second)
+		lines = append(lines, fmt.Sprintf("Last %s", span))
+	}
+	lines = append(lines, topGraph("Temp °C", temps), topGraph("Duty %", duties), "")
+
+	if len(s.Slots) == 0 {
+		lines = append(lines, "Slots        none accept power commands (control.slots)")
+	} else {
+		lines = append(lines, "Slots")
+		for i, slot := range s.Slots {
+			cursor := " "
+			if i == v.selected {
+				cursor = ">"
+			}
+			lines = append(lines, fmt.Sprintf(" %s %-3d %-8s %s", cursor, slot.Slot, slot.Power, slotDetail(slot)))
+		}
+	}
+	lines = append(lines, "")
+
+	switch {
+	case v.confirm != nil:
+		lines = append(lines, v.confirm.prompt+" [y/N]")
+	case v.message != "":
+		lines = append(lines, v.message)
+	default:
+		lines = append(lines, "")
+	}
+	return append(lines, "+/- override  m max  a auto  ↑/↓ slot  o on  f shut down  F force off  r reset  q quit")
+}
+
+// topGraph draws values as a sparkline scaled to their range.
+func topGraph(label string, values []float64) string {
+	if len(values) == 0 {
+		return fmt.Sprintf("%-8s waiting for samples", label)
+	}
+	var b strings.Builder
+	printSparkline(&b, label, values)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// slotDetail describes the running or last power action of a slot.
+func slotDetail(slot control.SlotState) string {
+	switch {
+	case slot.Busy:
+		return slot.Action + " running..."
+	case slot.Error != "":
+		return fmt.Sprintf("%s failed: %s", slot.Action, slot.Error)
+	case slot.Action != "":
+		return fmt.Sprintf("%s at %s", slot.Action, slot.UpdatedAt.Local().Format("15:04:05"))
+	}
+	return ""
+}
+
+func init() {
+	topCmd.Flags().StringVar(&configPath, "config", config.DefaultConfigPath, "Path to configuration file")
+	topCmd.Flags().StringVar(&topSocket, "socket", "", "Control socket (default: control.socket from the configuration)")
+	topCmd.Flags().DurationVar(&topInterval, "interval", time.Second, "Refresh interval")
+	rootCmd.AddCommand(topCmd)
+}
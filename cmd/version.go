@@ -12,11 +12,20 @@ var (
 	BuildDate = "unknown"
 )
 
+// versionResult is the structured output of version.
+type versionResult struct {
+	Version   string `json:"version" yaml:"version"`
+	Commit    string `json:"commit" yaml:"commit"`
+	BuildDate string `json:"build_date" yaml:"build_date"`
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print the version number of nanoctl",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("nanoctl version %s (commit: %s, built: %s)\n", Version, Commit, BuildDate)
+		printResult(cmd, versionResult{Version, Commit, BuildDate}, func() {
+			fmt.Printf("nanoctl version %s (commit: %s, built: %s)\n", Version, Commit, BuildDate)
+		})
 	},
 }
 
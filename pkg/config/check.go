@@ -0,0 +1,544 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity is the severity of an Issue.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a problem found in a configuration file.
+type Issue struct {
+	Severity Severity `json:"severity" yaml:"severity"`
+	File     string   `json:"file,omitempty" yaml:"file,omitempty"` // File the issue is in
+	Path     string   `json:"path,omitempty" yaml:"path,omitempty"` // Key path, e.g. "monitor.check_interval"; empty if unknown
+	Line     int      `json:"line,omitempty" yaml:"line,omitempty"` // 1-based line in the file; 0 when the value isn't in the file
+	Column   int      `json:"column,omitempty" yaml:"column,omitempty"`
+	Message  string   `json:"message" yaml:"message"`
+}
+
+func (i Issue) String() string {
+	var prefix string
+	if i.File != "" {
+		prefix = i.File + ":"
+	}
+	if i.Line > 0 {
+		prefix += fmt.Sprintf("%d:%d:", i.Line, i.Column)
+	}
+	if prefix != "" {
+		prefix += " "
+	}
+	return fmt.Sprintf("%s%s: %s", prefix, i.Severity, i.Message)
+}
+
+var (
+	yamlLineRe  = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	errorPathRe = regexp.MustCompile(`^[a-z_]+(?:\.[a-z_]+|\[\d+\])+`)
+)
+
+// Check reads the configuration file at path and its drop-ins strictly and
+// reports every problem it finds: YAML syntax errors, unknown keys, values
+// of the wrong type, validation errors and suspicious values (as warnings).
+// Unlike LoadFanConfig it doesn't stop at the first error. Drop-ins, host
+// sections, environment variables and secret files are resolved the same
+// way.
+//
+// The returned configuration has defaults applied; it is nil when a file
+// can't be read or is not valid YAML.
+func Check(path string) (*FanConfig, []Issue) {
+	return check(path, nil)
+}
+
+// CheckContent is like Check, with data as the content of the file at path.
+// It is used to check a change before writing it.
+func CheckContent(path string, data []byte) (*FanConfig, []Issue) {
+	return check(path, data)
+}
+
+// check implements Check; data replaces the content of the file at path
+// unless nil.
+func check(path string, data []byte) (*FanConfig, []Issue) {
+	files, err := ConfigFiles(path)
+	if err != nil {
+		return nil, []Issue{{Severity: SeverityError, File: path, Message: err.Error()}}
+	}
+	hostname, _ := os.Hostname()
+
+	var issues []Issue
+	failed := false
+	doc := newDocument()
+	unversioned := 0 // Drop-ins without a version key have the layout of the main file
+	for _, file := range files {
+		content := data
+		if file != path || content == nil {
+			var err error
+			if content, err = os.ReadFile(file); err != nil {
+				issues = append(issues, Issue{Severity: SeverityError, File: file, Message: err.Error()})
+				failed = true
+				continue
+			}
+		}
+		var root yaml.Node
+		if err := yaml.Unmarshal(content, &root); err != nil {
+			issues = append(issues, fileIssue(file, err.Error()))
+			failed = true
+			continue
+		}
+		versioned, _ := findNode(&root, []string{versionKey})
+		from, err := migrate(&root, false, unversioned, file != path)
+		if err != nil {
+			issues = append(issues, fileIssue(file, err.Error()))
+			failed = true
+			continue
+		}
+		if file == path {
+			unversioned = from
+		}
+		if from < CurrentVersion && (file == path || versioned != nil) {
+			issues = append(issues, Issue{
+				Severity: SeverityWarning,
+				File:     file,
+				Message:  fmt.Sprintf("configuration version %d is outdated, upgrade it with 'nanoctl config migrate'", from),
+			})
+		}
+		for _, issue := range fileUnknownKeys(&root) {
+			issue.File = file
+			issues = append(issues, issue)
+		}
+		if err := doc.add(file, &root, hostname); err != nil {
+			issues = append(issues, fileIssue(file, err.Error()))
+			failed = true
+		}
+	}
+	if failed {
+		return nil, issues
+	}
+
+	for _, err := range expandEnv(doc.root, nil) {
+		issues = append(issues, doc.issueAt(SeverityError, errorPathRe.FindString(err.Error()), err.Error()))
+	}
+
+	var config FanConfig
+	var typeErr *yaml.TypeError
+	if err := doc.root.Decode(&config); errors.As(err, &typeErr) {
+		// The lines of the merged document refer to different files, so
+		// each file's values are decoded on their own to place the errors
+		for _, file := range files {
+			issues = append(issues, doc.typeIssues(file)...)
+		}
+	} else if err != nil {
+		return nil, append(issues, fileIssue(path, err.Error()))
+	}
+
+	applyDefaults(&config)
+
+	for _, err := range config.loadSecretFiles() {
+		issues = append(issues, doc.issueAt(SeverityError, errorPathRe.FindString(err.Error()), err.Error()))
+	}
+
+	// A value that failed to expand or decode is left zero; don't report
+	// it twice
+	reported := make(map[string]bool)
+	for _, issue := range issues {
+		if issue.Path != "" {
+			reported[issue.Path] = true
+		}
+	}
+	for _, err := range config.ValidateAll() {
+		path := errorPathRe.FindString(err.Error())
+		if reported[path] {
+			continue
+		}
+		issues = append(issues, doc.issueAt(SeverityError, path, err.Error()))
+	}
+	for _, warning := range config.warnings() {
+		issues = append(issues, doc.issueAt(SeverityWarning, warning.Path, warning.Message))
+	}
+
+	// Order by file and position; issues without a line go last
+	order := func(file string) int { return slices.Index(files, file) }
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if (a.Line == 0) != (b.Line == 0) {
+			return b.Line == 0
+		}
+		if a.File != b.File {
+			return order(a.File) < order(b.File)
+		}
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	for i := range issues {
+		if issues[i].File == "" {
+			issues[i].File = path
+		}
+	}
+
+	return &config, issues
+}
+
+// yamlIssue converts a yaml error message into an Issue.
+func yamlIssue(msg string) Issue {
+	if m := yamlLineRe.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return Issue{Severity: SeverityError, Line: line, Column: 1, Message: m[2]}
+	}
+	return Issue{Severity: SeverityError, Message: strings.TrimPrefix(msg, "yaml: ")}
+}
+
+// fileIssue converts a yaml error message about file into an Issue.
+func fileIssue(file, msg string) Issue {
+	issue := yamlIssue(msg)
+	issue.File = file
+	return issue
+}
+
+// typeIssues reports the values read from file, and still in effect, that
+// don't match the type of their field.
+func (d *document) typeIssues(file string) []Issue {
+	node := d.fileNode(d.root, file)
+	var config FanConfig
+	var typeErr *yaml.TypeError
+	if err := node.Decode(&config); !errors.As(err, &typeErr) {
+		return nil
+	}
+
+	var issues []Issue
+	for _, msg := range typeErr.Errors {
+		issue := yamlIssue(msg)
+		issue.File = file
+		if path, key := pathAtLine(node, nil, issue.Line); key != nil {
+			issue.Path, issue.Column = joinPath(path), key.Column
+			issue.Message = issue.Path + ": " + issue.Message
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// fileNode returns the keys of the merged mapping node that were read from
+// file, with their values.
+func (d *document) fileNode(node *yaml.Node, file string) *yaml.Node {
+	out := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if value.Kind == yaml.MappingNode {
+			// Drop-ins merge into mappings key by key
+			if child := d.fileNode(value, file); len(child.Content) > 0 || d.files[key] == file {
+				child.Line, child.Column = value.Line, value.Column
+				out.Content = append(out.Content, key, child)
+			}
+		} else if d.files[key] == file {
+			out.Content = append(out.Content, key, value)
+		}
+	}
+	return out
+}
+
+// issueAt creates an issue positioned at path, or at its closest parent
+// present in the document.
+func (d *document) issueAt(severity Severity, path, message string) Issue {
+	issue := Issue{Severity: severity, Path: path, Message: message}
+	if file, key := d.position(splitPath(path)); key != nil {
+		issue.File, issue.Line, issue.Column = file, key.Line, key.Column
+	}
+	return issue
+}
+
+// fileUnknownKeys reports unknown keys in a parsed configuration file,
+// including the sections under hosts.
+func fileUnknownKeys(root *yaml.Node) []Issue {
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	t := reflect.TypeOf(FanConfig{})
+
+	var issues []Issue
+	settings := &yaml.Node{Kind: yaml.MappingNode}
+	for body, i := root.Content[0], 0; i+1 < len(body.Content); i += 2 {
+		key, value := body.Content[i], body.Content[i+1]
+		if key.Value != hostsKey {
+			settings.Content = append(settings.Content, key, value)
+			continue
+		}
+		if value.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				path := []string{hostsKey, value.Content[j].Value}
+				issues = append(issues, unknownKeys(value.Content[j+1], t, path)...)
+			}
+		}
+	}
+	return append(issues, unknownKeys(settings, t, nil)...)
+}
+
+// unknownKeys reports mapping keys that don't match a field of t.
+func unknownKeys(node *yaml.Node, t reflect.Type, path []string) []Issue {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var issues []Issue
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				issues = append(issues, Issue{
+					Severity: SeverityError,
+					Path:     joinPath(append(path, key.Value)),
+					Line:     key.Line,
+					Column:   key.Column,
+					Message:  unknownKeyMessage(key.Value, path, fields),
+				})
+				continue
+			}
+			issues = append(issues, unknownKeys(value, field.Type, append(path, key.Value))...)
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			issues = append(issues, unknownKeys(node.Content[i+1], t.Elem(), append(path, node.Content[i].Value))...)
+		}
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for i, item := range node.Content {
+			issues = append(issues, unknownKeys(item, t.Elem(), append(path, fmt.Sprintf("[%d]", i)))...)
+		}
+	}
+	return issues
+}
+
+// yamlFields maps the YAML keys of a struct to its fields, including the
+// fields of inlined structs.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for key, inlined := range yamlFields(field.Type) {
+				fields[key] = inlined
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+func unknownKeyMessage(key string, path []string, fields map[string]reflect.StructField) string {
+	where := "at the top level"
+	if len(path) > 0 {
+		where = "in " + joinPath(path)
+	}
+	msg := fmt.Sprintf("unknown key '%s' %s", key, where)
+
+	best, bestDistance := "", 3
+	for name := range fields {
+		if d := levenshtein(key, name); d < bestDistance || (d == bestDistance && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	if best != "" {
+		msg += fmt.Sprintf(" (did you mean '%s'?)", best)
+	}
+	return msg
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// findNode returns the key and value nodes at path in a parsed document.
+// Sequence items are addressed as "[i]" segments.
+func findNode(root *yaml.Node, path []string) (key, value *yaml.Node) {
+	node := root
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		node = node.Content[0]
+	}
+
+	for _, segment := range path {
+		found := false
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == segment {
+					key, node, found = node.Content[i], node.Content[i+1], true
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			var index int
+			if _, err := fmt.Sscanf(segment, "[%d]", &index); err == nil && index >= 0 && index < len(node.Content) {
+				key, node, found = node.Content[index], node.Content[index], true
+			}
+		}
+		if !found {
+			return nil, nil
+		}
+	}
+	return key, node
+}
+
+// pathAtLine returns the path and key node of the first scalar value on line.
+func pathAtLine(node *yaml.Node, path []string, line int) ([]string, *yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			if p, key := pathAtLine(child, path, line); key != nil {
+				return p, key
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			childPath := append(slices.Clone(path), key.Value)
+			if value.Kind == yaml.ScalarNode && value.Line == line {
+				return childPath, key
+			}
+			if p, k := pathAtLine(value, childPath, line); k != nil {
+				return p, k
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			childPath := append(slices.Clone(path), fmt.Sprintf("[%d]", i))
+			if item.Kind == yaml.ScalarNode && item.Line == line {
+				return childPath, item
+			}
+			if p, k := pathAtLine(item, childPath, line); k != nil {
+				return p, k
+			}
+		}
+	}
+	return nil, nil
+}
+
+// splitPath splits "metrics.outputs[0].url" into its segments.
+func splitPath(path string) []string {
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		if part == "" {
+			continue
+		}
+		name, index, hasIndex := strings.Cut(part, "[")
+		if name != "" {
+			segments = append(segments, name)
+		}
+		if hasIndex {
+			segments = append(segments, "["+index)
+		}
+	}
+	return segments
+}
+
+// joinPath is the inverse of splitPath.
+func joinPath(segments []string) string {
+	var b strings.Builder
+	for _, segment := range segments {
+		if b.Len() > 0 && !strings.HasPrefix(segment, "[") {
+			b.WriteByte('.')
+		}
+		b.WriteString(segment)
+	}
+	return b.String()
+}
+
+// warnings reports values that are valid but probably not intended.
+func (c *FanConfig) warnings() []Issue {
+	var warnings []Issue
+	warn := func(path, format string, args ...any) {
+		warnings = append(warnings, Issue{Severity: SeverityWarning, Path: path, Message: path + ": " + fmt.Sprintf(format, args...)})
+	}
+
+	if target := *c.Temperature.Target; target > 80 {
+		warn("temperature.target", "%.1f°C is close to the CM5 throttling point (85°C)", target)
+	} else if target < 35 {
+		warn("temperature.target", "%.1f°C is below typical idle temperatures, the fan will rarely slow down", target)
+	}
+
+	if interval, err := time.ParseDuration(c.Monitor.CheckInterval); err == nil {
+		if interval < 100*time.Millisecond {
+			warn("monitor.check_interval", "%s polls very often and wastes CPU", interval)
+		} else if interval > 10*time.Second {
+			warn("monitor.check_interval", "%s reacts slowly to temperature changes", interval)
+		}
+	}
+
+	if frequency := *c.PWM.FrequencyKHz; frequency > 0 && frequency < 20 {
+		warn("pwm.frequency_khz", "%.1f kHz may be audible, 4-pin fans expect 25 kHz", frequency)
+	}
+
+	if alpha := c.Temperature.Filter.EMAAlpha; alpha > 0 && alpha < 0.05 {
+		warn("temperature.filter.ema_alpha", "%.3f smooths so much that the fan reacts late", alpha)
+	}
+
+	source := c.Temperature.Source
+	if source.Primary == "file" || source.Fallback == "file" {
+		if _, err := os.Stat(source.File.Path); err != nil {
+			warn("temperature.source.file.path", "%s does not exist on this host", source.File.Path)
+		}
+	}
+
+	// Credentials sent in clear text
+	plainHTTP := func(url string) bool { return strings.HasPrefix(url, "http://") }
+	if c.Metrics.Enabled && c.Metrics.Auth != nil && plainHTTP(c.Metrics.Endpoint) {
+		warn("metrics.auth", "credentials are sent unencrypted to %s", c.Metrics.Endpoint)
+	}
+	if p := source.Prometheus; source.Primary == "prometheus" && p != nil && p.Auth != nil && plainHTTP(p.Host) {
+		warn("temperature.source.prometheus.auth", "credentials are sent unencrypted to %s", p.Host)
+	}
+	if h := source.HTTP; source.Primary == "http" && h != nil && h.Auth != nil && plainHTTP(h.URL) {
+		warn("temperature.source.http.auth", "credentials are sent unencrypted to %s", h.URL)
+	}
+	for i, output := range c.Metrics.Outputs {
+		if output.Auth != nil && plainHTTP(output.URL) {
+			warn(fmt.Sprintf("metrics.outputs[%d].auth", i), "credentials are sent unencrypted to %s", output.URL)
+		}
+	}
+	if c.MQTT.Auth != nil && strings.HasPrefix(c.MQTT.Broker, "tcp://") {
+		warn("mqtt.auth", "credentials are sent unencrypted to %s", c.MQTT.Broker)
+	}
+
+	return warnings
+}
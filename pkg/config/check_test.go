@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	// The file source path is checked on this host
+	fileSource := "temperature:\n  source:\n    file:\n      path: /dev/null\n"
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string // "<file>:<line> <severity> <path>"
+	}{
+		{"valid", map[string]string{"fan.yaml": "version: 1\n" + fileSource}, nil},
+		{"every error in a section", map[string]string{
+			"fan.yaml": "version: 1\n" + fileSource + "pid:\n  kp: -1\n  ki: -2\n",
+		}, []string{"fan.yaml:7 error pid.kp", "fan.yaml:8 error pid.ki"}},
+		{"unknown key", map[string]string{
+			"fan.yaml": "version: 1\n" + fileSource + "gpio:\n  pins: 12\n",
+		}, []string{"fan.yaml:7 error gpio.pins"}},
+		{"wrong type", map[string]string{
+			"fan.yaml": "version: 1\n" + fileSource + "gpio:\n  pin: twelve\n",
+		}, []string{"fan.yaml:7 error gpio.pin"}},
+		{"warning", map[string]string{
+			"fan.yaml": "version: 1\n" + fileSource + "  target: 85\n",
+		}, []string{"fan.yaml:6 warning temperature.target"}},
+		{"outdated version", map[string]string{"fan.yaml": fileSource}, []string{"fan.yaml:0 warning "}},
+		{"error in a drop-in", map[string]string{
+			"fan.yaml":           "version: 1\n" + fileSource,
+			"conf.d/10-pid.yaml": "pid:\n  kd: -1\n",
+		}, []string{"10-pid.yaml:2 error pid.kd"}},
+		{"unset variable", map[string]string{
+			"fan.yaml": "version: 1\n" + fileSource + "mqtt:\n  auth:\n    password: ${NANOCTL_TEST_UNSET}\n",
+		}, []string{"fan.yaml:7 warning mqtt.auth", "fan.yaml:8 error mqtt.auth.password"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, issues := Check(writeFiles(t, tt.files))
+			var got []string
+			for _, issue := range issues {
+				got = append(got, fmt.Sprintf("%s:%d %s %s", filepath.Base(issue.File), issue.Line, issue.Severity, issue.Path))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Check issues = %q, want %q\n%v", got, tt.want, issues)
+			}
+		})
+	}
+}
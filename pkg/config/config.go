@@ -3,11 +3,15 @@ package config
 import (
 	_ "embed"
 	"fmt"
+	"maps"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"time"
-
-	"gopkg.in/yaml.v3"
 )
 
 //go:embed default.yaml
@@ -16,53 +20,114 @@ var defaultConfigYAML []byte
 // DefaultConfigPath is the default location for the fan configuration file
 const DefaultConfigPath = "/etc/nanoctl/fan.yaml"
 
+// DefaultControlSocket is the default location of the daemon's control socket
+const DefaultControlSocket = "/run/nanoctl/control.sock"
+
 // FanConfig represents the fan controller configuration
 type FanConfig struct {
+	Version int `yaml:"version"` // Layout version, see CurrentVersion
+
 	GPIO struct {
 		ChipName string `yaml:"chip_name"`
-		Pin      int    `yaml:"pin"`
+		Pin      *int   `yaml:"pin"` // Unset means 13; 0 is a valid pin
 	} `yaml:"gpio"`
 
 	PWM struct {
-		Mode         string  `yaml:"mode"`
-		FrequencyKHz float64 `yaml:"frequency_khz"`
+		Mode         string   `yaml:"mode"`
+		FrequencyKHz *float64 `yaml:"frequency_khz"`
 		Hardware     struct {
 			Chip     string `yaml:"chip"`
-			Channel  int    `yaml:"channel"`
+			Channel  *int   `yaml:"channel"` // Unset means 1; 0 selects pwm0
 			Inverted bool   `yaml:"inverted"`
 		} `yaml:"hardware"`
 	} `yaml:"pwm"`
 
 	Temperature struct {
-		Target float64      `yaml:"target"`
+		Target *float64     `yaml:"target"`
 		Source SourceConfig `yaml:"source"`
+		Filter FilterConfig `yaml:"filter"`
 	} `yaml:"temperature"`
 
 	PID struct {
-		Kp float64 `yaml:"kp"`
-		Ki float64 `yaml:"ki"`
-		Kd float64 `yaml:"kd"`
+		Kp *float64 `yaml:"kp"`
+		Ki *float64 `yaml:"ki"` // 0 disables the integral term
+		Kd *float64 `yaml:"kd"` // 0 disables the derivative term
 	} `yaml:"pid"`
 
 	Metrics struct {
-		Enabled  bool        `yaml:"enabled"`
-		Endpoint string      `yaml:"endpoint"` // e.g. "localhost:4317"
-		Insecure bool        `yaml:"insecure"`
-		Interval string      `yaml:"interval"` // e.g. "5s"
-		Auth     *AuthConfig `yaml:"auth,omitempty"`
+		Enabled  bool               `yaml:"enabled"`
+		Endpoint string             `yaml:"endpoint"` // e.g. "localhost:4317"
+		Insecure bool               `yaml:"insecure"`
+		Interval string             `yaml:"interval"` // e.g. "5s"
+		Auth     *MetricsAuthConfig `yaml:"auth,omitempty"`
+		TLS      *TLSConfig         `yaml:"tls,omitempty"`     // Optional: CA bundle and client certificate (mTLS)
+		Headers  map[string]string  `yaml:"headers,omitempty"` // Optional: extra headers sent with every export
+
+		Resource struct {
+			Attributes map[string]string `yaml:"attributes,omitempty"` // e.g. cluster: "home", location: "rack-1"
+			Detectors  []string          `yaml:"detectors"`            // Resource detectors; defaults to host and os
+		} `yaml:"resource"`
+
+		Logs struct {
+			Enabled bool `yaml:"enabled"` // Export daemon events as OTLP logs to the same endpoint
+		} `yaml:"logs"`
+
+		Prometheus struct {
+			Enabled bool   `yaml:"enabled"`
+			Listen  string `yaml:"listen"` // e.g. ":9101"
+			Path    string `yaml:"path"`   // e.g. "/metrics"
+		} `yaml:"prometheus"`
+
+		Outputs []MetricsOutputConfig `yaml:"outputs,omitempty"` // Optional: InfluxDB and StatsD outputs
 	} `yaml:"metrics"`
 
 	Monitor struct {
 		CheckInterval string `yaml:"check_interval"`
 	} `yaml:"monitor"`
+
+	MQTT MQTTConfig `yaml:"mqtt"`
+
+	History HistoryConfig `yaml:"history"`
+
+	Control ControlConfig `yaml:"control"`
+}
+
+// HistoryConfig holds the on-device history settings.
+type HistoryConfig struct {
+	Enabled       *bool  `yaml:"enabled"`        // Defaults to true
+	Path          string `yaml:"path"`           // e.g. "/var/lib/nanoctl/history.bin"
+	Resolution    string `yaml:"resolution"`     // Aggregation interval, e.g. "10s"
+	Retention     string `yaml:"retention"`      // How far back to keep, e.g. "168h"
+	FlushInterval string `yaml:"flush_interval"` // How often to write to disk, e.g. "5m"
+}
+
+// ControlConfig holds the local control socket used by 'nanoctl top'.
+type ControlConfig struct {
+	Enabled *bool  `yaml:"enabled"`         // Defaults to true
+	Socket  string `yaml:"socket"`          // e.g. "/run/nanoctl/control.sock"
+	Slots   []int  `yaml:"slots,omitempty"` // Optional: slots that can be powered on, off and reset
+}
+
+// maxHistorySamples bounds the history file size (24 bytes per sample).
+const maxHistorySamples = 1_000_000
+
+// FilterConfig holds the smoothing applied to readings before the PID controller.
+// A zero value disables the corresponding stage.
+type FilterConfig struct {
+	MaxRate      float64 `yaml:"max_rate"`      // Max plausible change in °C/s; faster jumps are rejected
+	MedianWindow int     `yaml:"median_window"` // Median of the last N readings
+	EMAAlpha     float64 `yaml:"ema_alpha"`     // Exponential moving average factor (0 < alpha <= 1)
 }
 
 // SourceConfig holds configuration for temperature sources
 type SourceConfig struct {
-	Primary    string            `yaml:"primary"`              // "prometheus" or "file"
-	Fallback   string            `yaml:"fallback"`             // "file"
-	Prometheus *PrometheusConfig `yaml:"prometheus,omitempty"` // Optional
-	File       FileSourceConfig  `yaml:"file"`
+	Primary    string               `yaml:"primary"`              // "prometheus", "http", "mqtt", "command" or "file"
+	Fallback   string               `yaml:"fallback"`             // "file"
+	Prometheus *PrometheusConfig    `yaml:"prometheus,omitempty"` // Optional
+	HTTP       *HTTPSourceConfig    `yaml:"http,omitempty"`       // Optional
+	MQTT       *MQTTSourceConfig    `yaml:"mqtt,omitempty"`       // Optional, uses the top-level mqtt broker
+	Command    *CommandSourceConfig `yaml:"command,omitempty"`    // Optional
+	File       FileSourceConfig     `yaml:"file"`
 }
 
 // PrometheusConfig holds configuration specific to the Prometheus source.
@@ -70,13 +135,106 @@ type PrometheusConfig struct {
 	Host    string      `yaml:"host"`              // Required: http://host:port or https://host:port
 	Query   string      `yaml:"query,omitempty"`   // Optional: defaults to max(node_hwmon_temp_celsius{sensor="temp0"})
 	Timeout string      `yaml:"timeout,omitempty"` // Optional: defaults to "5s"
-	Auth    *AuthConfig `yaml:"auth,omitempty"`    // Optional: Basic auth
+	MaxAge  string      `yaml:"max_age,omitempty"` // Optional: samples older than this are errors, disabled by default
+	Auth    *AuthConfig `yaml:"auth,omitempty"`    // Optional: Basic or bearer auth
+}
+
+// HTTPSourceConfig holds configuration specific to the generic HTTP/JSON source.
+type HTTPSourceConfig struct {
+	URL      string            `yaml:"url"`                // Required: http://host/path or https://host/path
+	Method   string            `yaml:"method,omitempty"`   // Optional: defaults to "GET"
+	Headers  map[string]string `yaml:"headers,omitempty"`  // Optional: extra request headers
+	Body     string            `yaml:"body,omitempty"`     // Optional: request body (e.g. for POST)
+	Selector string            `yaml:"selector,omitempty"` // Optional: JSONPath-style selector, e.g. "$.sensors[0].value"
+	Unit     string            `yaml:"unit,omitempty"`     // Optional: "celsius" (default) or "millicelsius"
+	Timeout  string            `yaml:"timeout,omitempty"`  // Optional: defaults to "5s"
+	Auth     *AuthConfig       `yaml:"auth,omitempty"`     // Optional: Basic or bearer auth
+	TLS      *TLSConfig        `yaml:"tls,omitempty"`      // Optional: TLS settings for https:// URLs
 }
 
 // AuthConfig holds authentication details.
+// Token enables bearer authentication and takes precedence over basic auth.
+// The password and token can be read from a file instead, e.g. a systemd
+// credential.
 type AuthConfig struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password,omitempty"`
+	Username     string `yaml:"username,omitempty"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"` // Optional: relative to $CREDENTIALS_DIRECTORY when set
+	Token        string `yaml:"token,omitempty"`
+	TokenFile    string `yaml:"token_file,omitempty"` // Optional: relative to $CREDENTIALS_DIRECTORY when set
+}
+
+// MetricsAuthConfig holds authentication for the OTLP exporter.
+// Token (bearer) takes precedence over an API key, which takes precedence over basic auth.
+type MetricsAuthConfig struct {
+	AuthConfig   `yaml:",inline"`
+	APIKey       string `yaml:"api_key,omitempty"`
+	APIKeyFile   string `yaml:"api_key_file,omitempty"`   // Optional: relative to $CREDENTIALS_DIRECTORY when set
+	APIKeyHeader string `yaml:"api_key_header,omitempty"` // Optional: defaults to "X-API-Key"
+}
+
+// MetricsOutputConfig holds an InfluxDB or StatsD metrics output.
+type MetricsOutputConfig struct {
+	Type     string            `yaml:"type"`               // "influx", "statsd" or "dogstatsd"
+	URL      string            `yaml:"url"`                // e.g. "http://influxdb:8086", "udp://telegraf:8089" or "udp://localhost:8125"
+	Interval string            `yaml:"interval,omitempty"` // Optional: defaults to metrics.interval
+	Prefix   string            `yaml:"prefix,omitempty"`   // Optional: prepended to metric names
+	Tags     map[string]string `yaml:"tags,omitempty"`     // Optional: extra tags added to every metric
+
+	// InfluxDB over HTTP only
+	Org      string            `yaml:"org,omitempty"`      // v2 organisation
+	Bucket   string            `yaml:"bucket,omitempty"`   // v2 bucket
+	Database string            `yaml:"database,omitempty"` // v1 database
+	Auth     *AuthConfig       `yaml:"auth,omitempty"`     // Token or basic auth
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	TLS      *TLSConfig        `yaml:"tls,omitempty"`
+}
+
+// MetricsOutputTypes lists the supported metrics.outputs types.
+var MetricsOutputTypes = []string{"influx", "statsd", "dogstatsd"}
+
+// ResourceDetectors lists the supported OTel resource detectors.
+var ResourceDetectors = []string{"host", "os", "process", "container"}
+
+// TLSConfig holds TLS client settings.
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file,omitempty"`              // Optional: PEM bundle used to verify the server
+	CertFile           string `yaml:"cert_file,omitempty"`            // Optional: client certificate (mTLS)
+	KeyFile            string `yaml:"key_file,omitempty"`             // Optional: client key (mTLS)
+	ServerName         string `yaml:"server_name,omitempty"`          // Optional: overrides the name used for verification
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"` // Optional: disables server certificate verification
+}
+
+// MQTTSourceConfig holds configuration specific to the MQTT topic source.
+type MQTTSourceConfig struct {
+	Topic    string `yaml:"topic"`              // Required: topic carrying temperature readings
+	Selector string `yaml:"selector,omitempty"` // Optional: JSONPath-style selector for JSON payloads
+	Unit     string `yaml:"unit,omitempty"`     // Optional: "celsius" (default) or "millicelsius"
+	MaxAge   string `yaml:"max_age,omitempty"`  // Optional: readings older than this are errors, defaults to "1m"
+}
+
+// MQTTConfig holds the MQTT broker connection and state publishing settings.
+type MQTTConfig struct {
+	Enabled         bool        `yaml:"enabled"`                    // Publish state and accept commands
+	Broker          string      `yaml:"broker"`                     // e.g. "tcp://localhost:1883"
+	ClientID        string      `yaml:"client_id,omitempty"`        // Optional: defaults to "nanoctl-<hostname>"
+	TopicPrefix     string      `yaml:"topic_prefix,omitempty"`     // Optional: defaults to "nanoctl/<hostname>"
+	PublishInterval string      `yaml:"publish_interval,omitempty"` // Optional: defaults to "10s"
+	Auth            *AuthConfig `yaml:"auth,omitempty"`             // Optional: username/password
+	Slots           []int       `yaml:"slots,omitempty"`            // Optional: slots exposed for power control
+	Discovery       struct {
+		Enabled bool   `yaml:"enabled"`          // Publish Home Assistant discovery payloads
+		Prefix  string `yaml:"prefix,omitempty"` // Optional: defaults to "homeassistant"
+	} `yaml:"discovery"`
+}
+
+// CommandSourceConfig holds configuration specific to the command (plugin) source.
+type CommandSourceConfig struct {
+	Path    string   `yaml:"path"`              // Required: executable to run
+	Args    []string `yaml:"args,omitempty"`    // Optional: arguments passed to the executable
+	Timeout string   `yaml:"timeout,omitempty"` // Optional: defaults to "5s"
+	Unit    string   `yaml:"unit,omitempty"`    // Optional: "celsius" (default) or "millicelsius"
+	Stream  bool     `yaml:"stream,omitempty"`  // Optional: keep the command running and read one value per line
 }
 
 // FileSourceConfig holds configuration specific to the file source.
@@ -84,24 +242,29 @@ type FileSourceConfig struct {
 	Path string `yaml:"path"`
 }
 
-// LoadFanConfig loads the fan configuration from a YAML file
+// LoadFanConfig loads the fan configuration from a YAML file, merged with
+// its drop-ins and the host sections matching this host
 func LoadFanConfig(path string) (*FanConfig, error) {
-	data, err := os.ReadFile(path)
+	doc, err := readDocument(path)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("config file not found at %s. Run 'sudo nanoctl install-service' to create it", path)
-		}
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
+	}
+	if errs := expandEnv(doc.root, nil); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errs[0])
 	}
 
 	var config FanConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if err := doc.root.Decode(&config); err != nil {
 		return nil, fmt.Errorf("failed to parse YAML config: %w", err)
 	}
 
 	// Apply defaults
 	applyDefaults(&config)
 
+	if errs := config.loadSecretFiles(); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errs[0])
+	}
+
 	// Validate configuration
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
@@ -110,153 +273,326 @@ func LoadFanConfig(path string) (*FanConfig, error) {
 	return &config, nil
 }
 
-// applyDefaults applies default values to empty fields
+// applyDefaults applies default values to empty fields. Optional (pointer)
+// fields are only set when absent, so explicit zero values are kept.
 func applyDefaults(config *FanConfig) {
-	if config.GPIO.ChipName == "" {
-		config.GPIO.ChipName = "gpiochip0"
+	// Static defaults, see specs
+	applySpecDefaults(reflect.ValueOf(config).Elem(), nil)
+
+	// Defaults that depend on other settings
+	if config.Metrics.Auth != nil && config.Metrics.Auth.APIKey != "" && config.Metrics.Auth.APIKeyHeader == "" {
+		config.Metrics.Auth.APIKeyHeader = "X-API-Key"
 	}
-	if config.GPIO.Pin == 0 {
-		config.GPIO.Pin = 13
+	for i := range config.Metrics.Outputs {
+		if config.Metrics.Outputs[i].Interval == "" {
+			config.Metrics.Outputs[i].Interval = config.Metrics.Interval
+		}
 	}
-	if config.PWM.Mode == "" {
-		config.PWM.Mode = "software"
+
+	// Default MQTT identifiers are unique per host
+	hostname := hostnameOrDefault()
+	if config.MQTT.ClientID == "" {
+		config.MQTT.ClientID = "nanoctl-" + hostname
 	}
-	if config.PWM.FrequencyKHz == 0 {
-		config.PWM.FrequencyKHz = 25.0
+	if config.MQTT.TopicPrefix == "" {
+		config.MQTT.TopicPrefix = "nanoctl/" + hostname
 	}
-	if config.PWM.Hardware.Chip == "" {
-		config.PWM.Hardware.Chip = "pwmchip0"
+}
+
+// hostnameOrDefault returns the short hostname, used to build unique MQTT identifiers
+func hostnameOrDefault() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "nanoctl"
 	}
-	if config.PWM.Hardware.Channel == 0 {
-		config.PWM.Hardware.Channel = 1
+	if i := strings.IndexByte(hostname, '.'); i > 0 {
+		hostname = hostname[:i]
 	}
-	if config.Temperature.Target == 0 {
-		config.Temperature.Target = 55.0
+	return hostname
+}
+
+// Validate validates the configuration values and returns the first error.
+// Defaults must have been applied, as LoadFanConfig does.
+func (c *FanConfig) Validate() error {
+	if errs := c.ValidateAll(); len(errs) > 0 {
+		return errs[0]
 	}
+	return nil
+}
 
-	// Default temperature source settings
-	if config.Temperature.Source.Primary == "" {
-		config.Temperature.Source.Primary = "file"
+// ValidateAll runs every section's checks and returns all errors found,
+// in section order.
+func (c *FanConfig) ValidateAll() []error {
+	var errs []error
+	for _, validate := range c.validators() {
+		errs = append(errs, validate()...)
 	}
-	if config.Temperature.Source.Fallback == "" {
-		config.Temperature.Source.Fallback = "file"
+	return errs
+}
+
+// validators returns the configuration checks, one per section. Each
+// returns every error found in its section.
+func (c *FanConfig) validators() []func() []error {
+	return []func() []error{
+		c.validateGPIO,
+		c.validatePWM,
+		c.validateTemperature,
+		c.validatePID,
+		c.validateMonitor,
+		c.validateTemperatureSource,
+		c.validateMetrics,
+		c.validateMQTT,
+		c.validateHistory,
+		c.validateControl,
 	}
-	if config.Temperature.Source.File.Path == "" {
-		config.Temperature.Source.File.Path = "/sys/class/thermal/thermal_zone0/temp"
+}
+
+func (c *FanConfig) validateGPIO() []error {
+	// Valid BCM pins for RPi are 0-27, see specs
+	return c.checkSection("gpio")
+}
+
+func (c *FanConfig) validatePWM() []error {
+	errs := c.checkSection("pwm")
+	if c.PWM.Mode == "hardware" && c.PWM.Hardware.Chip == "" {
+		errs = append(errs, fmt.Errorf("pwm.hardware.chip is required when pwm.mode is 'hardware'"))
 	}
+	return errs
+}
+
+func (c *FanConfig) validateTemperature() []error {
+	// Target range, filter and source settings, see specs
+	return c.checkSection("temperature")
+}
 
-	if config.PID.Kp == 0 {
-		config.PID.Kp = 5.0
+func (c *FanConfig) validatePID() []error {
+	// Kp drives the fan; Ki or Kd of 0 give a PD or PI controller
+	return c.checkSection("pid")
+}
+
+func (c *FanConfig) validateMonitor() []error {
+	return c.checkSection("monitor")
+}
+
+func (c *FanConfig) validateTemperatureSource() []error {
+	var errs []error
+	fail := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+	source := c.Temperature.Source
+	isURL := func(s string) bool { return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") }
+
+	// The settings of the primary source are required
+	switch source.Primary {
+	case "prometheus":
+		p := source.Prometheus
+		if p == nil {
+			fail("temperature.source.prometheus configuration is required when primary is 'prometheus'")
+			break
+		}
+		if p.Host == "" {
+			fail("temperature.source.prometheus.host is required")
+		} else if !isURL(p.Host) {
+			fail("temperature.source.prometheus.host must start with http:// or https://")
+		}
+
+	case "http":
+		h := source.HTTP
+		if h == nil {
+			fail("temperature.source.http configuration is required when primary is 'http'")
+			break
+		}
+		if h.URL == "" {
+			fail("temperature.source.http.url is required")
+		} else if !isURL(h.URL) {
+			fail("temperature.source.http.url must start with http:// or https://")
+		}
+		if tls := h.TLS; tls != nil && (tls.CertFile == "") != (tls.KeyFile == "") {
+			fail("temperature.source.http.tls.cert_file and key_file must be set together")
+		}
+
+	case "mqtt":
+		if source.MQTT == nil {
+			fail("temperature.source.mqtt configuration is required when primary is 'mqtt'")
+		} else if source.MQTT.Topic == "" {
+			fail("temperature.source.mqtt.topic is required")
+		}
+
+	case "command":
+		if source.Command == nil {
+			fail("temperature.source.command configuration is required when primary is 'command'")
+		} else if source.Command.Path == "" {
+			fail("temperature.source.command.path is required")
+		}
 	}
-	if config.PID.Ki == 0 {
-		config.PID.Ki = 0.1
+
+	// Validate file source path
+	if source.File.Path == "" {
+		fail("temperature.source.file.path is required")
 	}
-	if config.PID.Kd == 0 {
-		config.PID.Kd = 0.5
+
+	return errs
+}
+
+func (c *FanConfig) validateMetrics() []error {
+	errs := c.checkSection("metrics")
+	fail := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+
+	// Validate OTLP TLS settings
+	if tls := c.Metrics.TLS; c.Metrics.Enabled && tls != nil {
+		if c.Metrics.Insecure {
+			fail("metrics.tls cannot be combined with metrics.insecure")
+		}
+		if (tls.CertFile == "") != (tls.KeyFile == "") {
+			fail("metrics.tls.cert_file and key_file must be set together")
+		}
 	}
 
-	// Default metrics settings
-	if config.Metrics.Endpoint == "" {
-		config.Metrics.Endpoint = "localhost:4317"
+	if c.Metrics.Logs.Enabled && !c.Metrics.Enabled {
+		fail("metrics.logs requires metrics.enabled, logs are exported to the OTLP endpoint")
 	}
-	if config.Metrics.Interval == "" {
-		config.Metrics.Interval = "10s"
+
+	for _, name := range slices.Sorted(maps.Keys(c.Metrics.Headers)) {
+		if name == "" || strings.ContainsAny(name, " :\r\n") {
+			fail("metrics.headers contains an invalid header name '%s'", name)
+		}
 	}
 
-	if config.Monitor.CheckInterval == "" {
-		config.Monitor.CheckInterval = "1s"
+	if _, ok := c.Metrics.Resource.Attributes[""]; ok {
+		fail("metrics.resource.attributes keys must not be empty")
 	}
-}
 
-// Validate validates the configuration values
-func (c *FanConfig) Validate() error {
-	// Validate GPIO pin (valid BCM pins for RPi are 0-27)
-	if c.GPIO.Pin < 0 || c.GPIO.Pin > 27 {
-		return fmt.Errorf("gpio.pin must be between 0 and 27, got %d", c.GPIO.Pin)
+	// Validate Prometheus pull endpoint
+	if c.Metrics.Prometheus.Enabled && !strings.HasPrefix(c.Metrics.Prometheus.Path, "/") {
+		fail("metrics.prometheus.path must start with '/', got '%s'", c.Metrics.Prometheus.Path)
 	}
 
-	// Validate PWM configuration
-	switch c.PWM.Mode {
-	case "software":
-		if c.PWM.FrequencyKHz <= 0 {
-			return fmt.Errorf("pwm.frequency_khz must be positive, got %.2f", c.PWM.FrequencyKHz)
+	for i, output := range c.Metrics.Outputs {
+		for _, err := range validateMetricsOutput(output) {
+			fail("metrics.outputs[%d]: %w", i, err)
 		}
-	case "hardware":
-		if c.PWM.Hardware.Chip == "" {
-			return fmt.Errorf("pwm.hardware.chip is required when pwm.mode is 'hardware'")
+	}
+
+	return errs
+}
+
+func validateMetricsOutput(output MetricsOutputConfig) []error {
+	var errs []error
+	fail := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+
+	if output.Type == "" {
+		// The URL and the other settings depend on the type
+		fail("type is required, supported: %s", strings.Join(MetricsOutputTypes, ", "))
+		return errs
+	}
+
+	// Invalid durations are reported by the spec check
+	if output.Interval == "" {
+		fail("interval is required")
+	}
+
+	validURL, overHTTP := true, false
+	if output.Type == "influx" {
+		u, err := url.Parse(output.URL)
+		if err != nil || u.Host == "" || !slices.Contains([]string{"http", "https", "udp"}, u.Scheme) {
+			fail("url must be http(s)://host:port or udp://host:port for influx, got '%s'", output.URL)
+			validURL = false
+		} else {
+			overHTTP = u.Scheme != "udp"
 		}
-		if c.PWM.Hardware.Channel < 0 {
-			return fmt.Errorf("pwm.hardware.channel must be >= 0, got %d", c.PWM.Hardware.Channel)
+	} else {
+		address := strings.TrimPrefix(output.URL, "udp://")
+		if _, _, err := net.SplitHostPort(address); err != nil || strings.Contains(address, "://") {
+			fail("url must be udp://host:port or host:port for %s, got '%s'", output.Type, output.URL)
 		}
-	default:
-		return fmt.Errorf("pwm.mode must be 'software' or 'hardware', got '%s'", c.PWM.Mode)
 	}
 
-	// Validate target temperature (reasonable range)
-	if c.Temperature.Target < 20.0 || c.Temperature.Target > 90.0 {
-		return fmt.Errorf("temperature.target must be between 20 and 90°C, got %.1f", c.Temperature.Target)
+	httpOnly := output.Org != "" || output.Bucket != "" || output.Database != "" ||
+		output.Auth != nil || len(output.Headers) > 0 || output.TLS != nil
+	if httpOnly && validURL && !overHTTP {
+		fail("org, bucket, database, auth, headers and tls only apply to influx over http(s)")
 	}
-
-	// Validate PID values (must be positive)
-	if c.PID.Kp <= 0 {
-		return fmt.Errorf("pid.kp must be positive, got %.2f", c.PID.Kp)
+	if output.Bucket != "" && output.Database != "" {
+		fail("bucket (InfluxDB v2) and database (InfluxDB v1) are mutually exclusive")
 	}
-	if c.PID.Ki <= 0 {
-		return fmt.Errorf("pid.ki must be positive, got %.2f", c.PID.Ki)
+	if output.Org != "" && output.Bucket == "" {
+		fail("org requires bucket")
 	}
-	if c.PID.Kd <= 0 {
-		return fmt.Errorf("pid.kd must be positive, got %.2f", c.PID.Kd)
+	if tls := output.TLS; tls != nil && (tls.CertFile == "") != (tls.KeyFile == "") {
+		fail("tls.cert_file and key_file must be set together")
 	}
 
-	// Validate check interval (must be parseable as duration)
-	if _, err := time.ParseDuration(c.Monitor.CheckInterval); err != nil {
-		return fmt.Errorf("monitor.check_interval must be a valid duration (e.g., '1s', '500ms'): %w", err)
-	}
+	return errs
+}
 
-	// Validate temperature source configuration
-	if err := c.validateTemperatureSource(); err != nil {
-		return err
+func (c *FanConfig) validateHistory() []error {
+	errs := c.checkSection("history")
+
+	// Invalid and non-positive durations are reported by the spec check;
+	// the retention is only compared to a valid resolution
+	resolution, err := time.ParseDuration(c.History.Resolution)
+	valid := err == nil && resolution > 0
+	if valid && resolution < time.Second {
+		errs = append(errs, fmt.Errorf("history.resolution must be at least 1s, got %s", c.History.Resolution))
+	} else if retention, retErr := time.ParseDuration(c.History.Retention); valid && retErr == nil && retention > 0 {
+		if retention < resolution {
+			errs = append(errs, fmt.Errorf("history.retention must be at least history.resolution, got %s", c.History.Retention))
+		} else if retention/resolution > maxHistorySamples {
+			errs = append(errs, fmt.Errorf("history.retention / history.resolution must not exceed %d samples, got %d", maxHistorySamples, retention/resolution))
+		}
 	}
 
-	return nil
+	return errs
 }
 
-func (c *FanConfig) validateTemperatureSource() error {
-	// Validate primary source type
-	if c.Temperature.Source.Primary != "file" && c.Temperature.Source.Primary != "prometheus" {
-		return fmt.Errorf("temperature.source.primary must be 'file' or 'prometheus', got '%s'", c.Temperature.Source.Primary)
+func (c *FanConfig) validateMQTT() []error {
+	errs := c.checkSection("mqtt")
+
+	// The broker is only used when publishing state or reading temperatures from MQTT
+	if !c.MQTT.Enabled && c.Temperature.Source.Primary != "mqtt" {
+		return errs
 	}
 
-	// If prometheus is primary, validate prometheus config
-	if c.Temperature.Source.Primary == "prometheus" {
-		if c.Temperature.Source.Prometheus == nil {
-			return fmt.Errorf("temperature.source.prometheus configuration is required when primary is 'prometheus'")
-		}
+	if !strings.Contains(c.MQTT.Broker, "://") {
+		errs = append(errs, fmt.Errorf("mqtt.broker must be a URL such as tcp://host:1883 or ssl://host:8883, got '%s'", c.MQTT.Broker))
+	}
 
-		if c.Temperature.Source.Prometheus.Host == "" {
-			return fmt.Errorf("temperature.source.prometheus.host is required")
-		}
+	if strings.ContainsAny(c.MQTT.TopicPrefix, "#+") {
+		errs = append(errs, fmt.Errorf("mqtt.topic_prefix must not contain wildcards, got '%s'", c.MQTT.TopicPrefix))
+	}
 
-		// Validate URL format
-		if !strings.HasPrefix(c.Temperature.Source.Prometheus.Host, "http://") &&
-			!strings.HasPrefix(c.Temperature.Source.Prometheus.Host, "https://") {
-			return fmt.Errorf("temperature.source.prometheus.host must start with http:// or https://")
+	for _, slot := range c.MQTT.Slots {
+		if slot < 1 {
+			errs = append(errs, fmt.Errorf("mqtt.slots must contain slot numbers >= 1, got %d", slot))
 		}
+	}
 
-		// Validate timeout if provided
-		if c.Temperature.Source.Prometheus.Timeout != "" {
-			if _, err := time.ParseDuration(c.Temperature.Source.Prometheus.Timeout); err != nil {
-				return fmt.Errorf("temperature.source.prometheus.timeout must be a valid duration: %w", err)
-			}
-		}
+	return errs
+}
+
+func (c *FanConfig) validateControl() []error {
+	errs := c.checkSection("control")
+
+	if *c.Control.Enabled && !filepath.IsAbs(c.Control.Socket) {
+		errs = append(errs, fmt.Errorf("control.socket must be an absolute path, got '%s'", c.Control.Socket))
 	}
 
-	// Validate file source path
-	if c.Temperature.Source.File.Path == "" {
-		return fmt.Errorf("temperature.source.file.path is required")
+	for _, slot := range c.Control.Slots {
+		if slot < 1 {
+			errs = append(errs, fmt.Errorf("control.slots must contain slot numbers >= 1, got %d", slot))
+		}
 	}
 
-	return nil
+	return errs
+}
+
+// checkSection checks the values of a top-level section against specs.
+func (c *FanConfig) checkSection(name string) []error {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if key, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ","); key == name {
+			return checkSpecs(v.Type(), v.Field(i), []string{name})
+		}
+	}
+	return []error{fmt.Errorf("%s: unknown configuration section", name)}
 }
 
 // GetCheckIntervalDuration parses and returns the check interval as time.Duration
@@ -272,8 +608,8 @@ func CreateDefaultConfig(path string) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	// Write default config
-	if err := os.WriteFile(path, defaultConfigYAML, 0644); err != nil {
+	// Write default config, readable only by root if it holds secrets
+	if err := os.WriteFile(path, defaultConfigYAML, configFileMode(defaultConfigYAML)); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// hostsKey is the top-level key holding per-host sections, keyed by
+// hostname or glob pattern (e.g. "cm5-*").
+const hostsKey = "hosts"
+
+// DropInDir returns the drop-in directory of the configuration file at
+// path, e.g. /etc/nanoctl/conf.d.
+func DropInDir(path string) string {
+	return filepath.Join(filepath.Dir(path), "conf.d")
+}
+
+// ConfigFiles returns the configuration file at path followed by its
+// drop-ins (*.yaml in DropInDir) in lexical order, which is the order they
+// are merged in.
+func ConfigFiles(path string) ([]string, error) {
+	dropIns, err := filepath.Glob(filepath.Join(DropInDir(path), "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drop-in files: %w", err)
+	}
+	return append([]string{path}, dropIns...), nil
+}
+
+// document is a configuration merged from several files.
+type document struct {
+	root  *yaml.Node            // Merged top-level mapping
+	files map[*yaml.Node]string // File each key node was read from
+}
+
+func newDocument() *document {
+	return &document{
+		root:  &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"},
+		files: make(map[*yaml.Node]string),
+	}
+}
+
+// readDocument reads the configuration file at path and its drop-ins,
+// migrates them to the current layout and merges them for this host.
+func readDocument(path string) (*document, error) {
+	files, err := ConfigFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+
+	doc := newDocument()
+	unversioned := 0 // Drop-ins without a version key have the layout of the main file
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) && file == path {
+				return nil, fmt.Errorf("config file not found at %s. Run 'sudo nanoctl install-service' to create it", path)
+			}
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML config %s: %w", file, err)
+		}
+		from, err := migrate(&root, false, unversioned, file != path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration %s: %w", file, err)
+		}
+		if file == path {
+			unversioned = from
+		}
+		if err := doc.add(file, &root, hostname); err != nil {
+			return nil, fmt.Errorf("invalid configuration %s: %w", file, err)
+		}
+	}
+	return doc, nil
+}
+
+// add merges a parsed file into the document: its settings first, then the
+// sections under hosts matching hostname, in the order they appear. Later
+// values replace earlier ones; mappings are merged key by key.
+func (d *document) add(file string, root *yaml.Node, hostname string) error {
+	if len(root.Content) == 0 {
+		return nil
+	}
+	body := root.Content[0]
+	if body.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: configuration must be a mapping", body.Line)
+	}
+
+	var hosts *yaml.Node
+	for i := 0; i+1 < len(body.Content); i += 2 {
+		key, value := body.Content[i], body.Content[i+1]
+		if key.Value == hostsKey {
+			hosts = value
+			continue
+		}
+		d.set(d.root, key, value, file)
+	}
+
+	if hosts == nil {
+		return nil
+	}
+	if hosts.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: hosts must map hostnames to settings", hosts.Line)
+	}
+	for i := 0; i+1 < len(hosts.Content); i += 2 {
+		pattern, section := hosts.Content[i], hosts.Content[i+1]
+		matched, err := path.Match(pattern.Value, hostname)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid host pattern %q: %w", pattern.Line, pattern.Value, err)
+		}
+		if !matched {
+			continue
+		}
+		if section.Kind != yaml.MappingNode {
+			return fmt.Errorf("line %d: hosts.%s must be a mapping", section.Line, pattern.Value)
+		}
+		for j := 0; j+1 < len(section.Content); j += 2 {
+			d.set(d.root, section.Content[j], section.Content[j+1], file)
+		}
+	}
+	return nil
+}
+
+// set merges key: value from file into the mapping dst.
+func (d *document) set(dst, key, value *yaml.Node, file string) {
+	d.record(key, value, file)
+	for i := 0; i+1 < len(dst.Content); i += 2 {
+		if dst.Content[i].Value != key.Value {
+			continue
+		}
+		if current := dst.Content[i+1]; current.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				d.set(current, value.Content[j], value.Content[j+1], file)
+			}
+			return
+		}
+		dst.Content[i], dst.Content[i+1] = key, value
+		return
+	}
+	dst.Content = append(dst.Content, key, value)
+}
+
+// record notes file as the origin of key and every key below value.
+func (d *document) record(key, value *yaml.Node, file string) {
+	d.files[key] = file
+	switch value.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			d.record(value.Content[i], value.Content[i+1], file)
+		}
+	case yaml.SequenceNode:
+		for _, item := range value.Content {
+			d.record(item, item, file)
+		}
+	}
+}
+
+// position returns the file and line of the key at path, or of its closest
+// parent present in the document.
+func (d *document) position(path []string) (file string, key *yaml.Node) {
+	for ; len(path) > 0; path = path[:len(path)-1] {
+		if key, _ := findNode(d.root, path); key != nil {
+			return d.files[key], key
+		}
+	}
+	return "", nil
+}
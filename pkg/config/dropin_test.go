@@ -0,0 +1,139 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadDropIns(t *testing.T) {
+	main := "version: 1\ngpio:\n  chip_name: gpiochip4\n  pin: 12\npid:\n  kp: 4\n"
+
+	tests := []struct {
+		name      string
+		files     map[string]string
+		pin       int
+		chip      string
+		kp        float64
+		detectors []string
+	}{
+		{"main file only", map[string]string{"fan.yaml": main}, 12, "gpiochip4", 4, []string{"host", "os"}},
+		{"mappings are merged", map[string]string{
+			"fan.yaml":           main,
+			"conf.d/10-pin.yaml": "gpio:\n  pin: 18\n",
+		}, 18, "gpiochip4", 4, []string{"host", "os"}},
+		{"later drop-ins win", map[string]string{
+			"fan.yaml":           main,
+			"conf.d/20-pin.yaml": "gpio:\n  pin: 20\n",
+			"conf.d/10-pin.yaml": "gpio:\n  pin: 10\n",
+		}, 20, "gpiochip4", 4, []string{"host", "os"}},
+		{"lists are replaced", map[string]string{
+			"fan.yaml":               main + "metrics:\n  resource:\n    detectors: [host, os]\n",
+			"conf.d/10-metrics.yaml": "metrics:\n  resource:\n    detectors: [container]\n",
+		}, 12, "gpiochip4", 4, []string{"container"}},
+		{"other files are ignored", map[string]string{
+			"fan.yaml":               main,
+			"conf.d/10-pin.yml":      "gpio:\n  pin: 18\n",
+			"conf.d/10-pin.yaml.bak": "gpio:\n  pin: 19\n",
+		}, 12, "gpiochip4", 4, []string{"host", "os"}},
+		{"matching host section", map[string]string{
+			"fan.yaml": main + "hosts:\n  \"*\":\n    pid:\n      kp: 6\n",
+		}, 12, "gpiochip4", 6, []string{"host", "os"}},
+		{"other host section", map[string]string{
+			"fan.yaml": main + "hosts:\n  no-such-host-*:\n    pid:\n      kp: 6\n",
+		}, 12, "gpiochip4", 4, []string{"host", "os"}},
+		{"host section before a drop-in", map[string]string{
+			"fan.yaml":          main + "hosts:\n  \"*\":\n    pid:\n      kp: 6\n",
+			"conf.d/10-kp.yaml": "pid:\n  kp: 7\n",
+		}, 12, "gpiochip4", 7, []string{"host", "os"}},
+		{"host section of a drop-in", map[string]string{
+			"fan.yaml":          main,
+			"conf.d/10-kp.yaml": "pid:\n  kp: 7\nhosts:\n  \"*\":\n    pid:\n      kp: 8\n",
+		}, 12, "gpiochip4", 8, []string{"host", "os"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadFanConfig(writeFiles(t, tt.files))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *cfg.GPIO.Pin != tt.pin || cfg.GPIO.ChipName != tt.chip || *cfg.PID.Kp != tt.kp {
+				t.Errorf("gpio.pin, gpio.chip_name, pid.kp = %d, %s, %g, want %d, %s, %g",
+					*cfg.GPIO.Pin, cfg.GPIO.ChipName, *cfg.PID.Kp, tt.pin, tt.chip, tt.kp)
+			}
+			if !slices.Equal(cfg.Metrics.Resource.Detectors, tt.detectors) {
+				t.Errorf("metrics.resource.detectors = %q, want %q", cfg.Metrics.Resource.Detectors, tt.detectors)
+			}
+		})
+	}
+}
+
+func TestLoadDropInErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{"invalid drop-in", map[string]string{
+			"fan.yaml":           "version: 1\n",
+			"conf.d/10-pin.yaml": "gpio: [\n",
+		}, "10-pin.yaml"},
+		{"invalid value in a drop-in", map[string]string{
+			"fan.yaml":           "version: 1\n",
+			"conf.d/10-pin.yaml": "pid:\n  kp: -1\n",
+		}, "pid.kp"},
+		{"invalid host pattern", map[string]string{
+			"fan.yaml": "version: 1\nhosts:\n  \"[\":\n    pid:\n      kp: 6\n",
+		}, "invalid host pattern"},
+		{"host section not a mapping", map[string]string{
+			"fan.yaml": "version: 1\nhosts:\n  \"*\": 6\n",
+		}, "hosts.* must be a mapping"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFanConfig(writeFiles(t, tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadFanConfig error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOverriddenBy(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		key   string
+		want  string // File of the overriding value, empty if not overridden
+	}{
+		{"not overridden", map[string]string{"fan.yaml": "version: 1\npid:\n  kp: 4\n"}, "pid.kp", ""},
+		{"drop-in", map[string]string{
+			"fan.yaml":          "version: 1\npid:\n  kp: 4\n",
+			"conf.d/10-kp.yaml": "pid:\n  kp: 7\n",
+		}, "pid.kp", "10-kp.yaml"},
+		{"other key in a drop-in", map[string]string{
+			"fan.yaml":          "version: 1\npid:\n  kp: 4\n",
+			"conf.d/10-kp.yaml": "pid:\n  ki: 0.2\n",
+		}, "pid.kp", ""},
+		{"host section", map[string]string{
+			"fan.yaml": "version: 1\npid:\n  kp: 4\nhosts:\n  \"*\":\n    pid:\n      kp: 6\n",
+		}, "pid.kp", "fan.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OverriddenBy(writeFiles(t, tt.files), tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if got != "" {
+					t.Errorf("OverriddenBy(%s) = %q, want not overridden", tt.key, got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("OverriddenBy(%s) = %q, want %s", tt.key, got, tt.want)
+			}
+		})
+	}
+}
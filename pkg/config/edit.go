@@ -0,0 +1,482 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// keyType returns the Go type of the value at path, e.g. temperature.target
+// or hosts.cm5-*.pid.kp.
+func keyType(path []string) (reflect.Type, error) {
+	if len(path) == 0 {
+		return nil, errors.New("key must not be empty")
+	}
+	settings := path
+	if path[0] == hostsKey {
+		if len(path) < 3 {
+			return nil, fmt.Errorf("host settings are set as %s.<host>.<key>", hostsKey)
+		}
+		settings = path[2:]
+	}
+	prefix := path[:len(path)-len(settings)]
+
+	t := reflect.TypeOf(FanConfig{})
+	for i, segment := range settings {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		parent := append(prefix[:len(prefix):len(prefix)], settings[:i]...)
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := yamlFields(t)
+			field, ok := fields[segment]
+			if !ok {
+				return nil, errors.New(unknownKeyMessage(segment, parent, fields))
+			}
+			t = field.Type
+		case reflect.Slice:
+			if !indexRe.MatchString(segment) {
+				return nil, fmt.Errorf("%s is a list, select an item with %s[0]", joinPath(parent), joinPath(parent))
+			}
+			t = t.Elem()
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return nil, fmt.Errorf("%s is not a section", joinPath(parent))
+		}
+	}
+	return t, nil
+}
+
+// Get returns the value of key (e.g. "temperature.target") in config: a
+// scalar as is, a section or list as YAML. Secrets are redacted.
+func Get(config *FanConfig, key string) (string, error) {
+	path := splitPath(key)
+	if len(path) > 0 && path[0] == hostsKey {
+		return "", fmt.Errorf("%s sections are merged into the configuration, get the setting without the %s prefix", hostsKey, hostsKey)
+	}
+	if _, err := keyType(path); err != nil {
+		return "", err
+	}
+
+	var root yaml.Node
+	if err := root.Encode(config); err != nil {
+		return "", fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	redactSecrets(&root)
+	_, value := findNode(&root, path)
+	if value == nil {
+		return "", fmt.Errorf("%s is not set", key)
+	}
+	if value.Kind == yaml.ScalarNode {
+		return value.Value, nil
+	}
+	out, err := encodeNode(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// redactSecrets replaces the passwords, tokens and API keys below node.
+func redactSecrets(node *yaml.Node) {
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && i%2 == 1 && slices.Contains(secretKeys, node.Content[i-1].Value) &&
+			child.Kind == yaml.ScalarNode && child.Value != "" {
+			child.Value, child.Tag, child.Style = "<redacted>", "!!str", 0
+			continue
+		}
+		redactSecrets(child)
+	}
+}
+
+// RedactValue returns value, as passed to Set for key, with passwords,
+// tokens and API keys replaced so it can be printed.
+func RedactValue(key, value string) string {
+	if path := splitPath(key); len(path) > 0 && slices.Contains(secretKeys, path[len(path)-1]) && value != "" {
+		return "<redacted>"
+	}
+
+	// A list of sections may carry secrets, e.g. metrics.outputs with auth
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &root); err != nil || len(root.Content) == 0 || root.Content[0].Kind == yaml.ScalarNode {
+		return value
+	}
+	redactSecrets(&root)
+	setStyle(root.Content[0], yaml.FlowStyle)
+	out, err := encodeNode(root.Content[0])
+	if err != nil {
+		return "<redacted>"
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+// setStyle sets the style of node and every collection below it.
+func setStyle(node *yaml.Node, style yaml.Style) {
+	if node.Kind == yaml.ScalarNode {
+		return
+	}
+	node.Style = style
+	for _, child := range node.Content {
+		setStyle(child, style)
+	}
+}
+
+// Set sets key to value in the configuration file data and returns the new
+// content. value is parsed as YAML, e.g. "4.5" or "[host, os]", and must
+// fit the type of key; missing sections are created.
+//
+// Comments are kept. A value written on one line is replaced in place and a
+// new key is added at the end of its section, so the rest of the file is
+// unchanged; other edits reformat the file.
+func Set(data []byte, key, value string) ([]byte, error) {
+	path := splitPath(key)
+	t, err := keyType(path)
+	if err != nil {
+		return nil, err
+	}
+	node, err := parseValue(key, value, t)
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML config: %w", err)
+	}
+	if len(root.Content) == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	body := root.Content[0]
+	if body.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: configuration must be a mapping", body.Line)
+	}
+
+	// Find what is in the file before changing the tree
+	var edit func() ([]byte, bool)
+	if _, old := findNode(body, path); old != nil {
+		// Quoted strings stay quoted
+		if old.Kind == yaml.ScalarNode && node.Kind == yaml.ScalarNode && node.Style == 0 && node.ShortTag() == "!!str" {
+			node.Style = old.Style & (yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle)
+		}
+		if text := sourceText(data, old); text != "" {
+			line, column := old.Line, old.Column
+			edit = func() ([]byte, bool) { return replaceText(data, line, column, text, node) }
+		}
+	} else if parent, missing := closestSection(body, path); parent.Kind == yaml.MappingNode && parent.Style&yaml.FlowStyle == 0 &&
+		len(parent.Content) > 0 && !slices.ContainsFunc(missing, indexRe.MatchString) {
+		after, indent := lastLine(parent), parent.Content[0].Column-1
+		edit = func() ([]byte, bool) { return insertText(data, after, indent, missing, node) }
+	}
+
+	if err := setNode(body, path, node); err != nil {
+		return nil, fmt.Errorf("cannot set %s: %w", key, err)
+	}
+	want, err := encodeNode(&root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the text edit if it parses to the same configuration
+	if edit != nil {
+		if out, ok := edit(); ok && sameContent(out, want) {
+			return out, nil
+		}
+	}
+	return want, nil
+}
+
+// sameContent reports whether two files hold the same YAML, ignoring
+// comments and formatting.
+func sameContent(a, b []byte) bool {
+	var encoded [2][]byte
+	for i, data := range [][]byte{a, b} {
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return false
+		}
+		stripComments(&root)
+		out, err := encodeNode(&root)
+		if err != nil {
+			return false
+		}
+		encoded[i] = out
+	}
+	return bytes.Equal(encoded[0], encoded[1])
+}
+
+func stripComments(node *yaml.Node) {
+	node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+	for _, child := range node.Content {
+		stripComments(child)
+	}
+}
+
+// parseValue parses value as YAML and checks that it decodes into t.
+// Strings that would be read as another type, such as "on" or "0755", are
+// quoted.
+func parseValue(key, value string, t reflect.Type) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("invalid value for %s: value is empty, use '\"\"' for an empty string", key)
+	}
+	node := doc.Content[0]
+	node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+
+	if t.Kind() == reflect.Struct || (t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct) {
+		return nil, fmt.Errorf("%s is a section, set its keys one by one", key)
+	}
+	if err := node.Decode(reflect.New(t).Interface()); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			err = errors.New(strings.Join(typeErr.Errors, "; "))
+		}
+		return nil, fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.String && node.Kind == yaml.ScalarNode && node.ShortTag() != "!!str" {
+		node.Tag, node.Style = "!!str", yaml.DoubleQuotedStyle
+	}
+	return node, nil
+}
+
+// setNode sets the value at path below node, creating missing sections and
+// appending to a list when the index is its length.
+func setNode(node *yaml.Node, path []string, value *yaml.Node) error {
+	// An empty section ("gpio:") becomes a mapping or list
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+		*node = *newSection(path[0])
+	}
+
+	var child *yaml.Node
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == path[0] {
+				child = node.Content[i+1]
+				break
+			}
+		}
+		if child == nil {
+			child = newSection(next(path))
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}, child)
+		}
+	case yaml.SequenceNode:
+		index, err := strconv.Atoi(strings.Trim(path[0], "[]"))
+		if err != nil || index > len(node.Content) {
+			return fmt.Errorf("index %s is out of range, the list has %d items", path[0], len(node.Content))
+		}
+		if index == len(node.Content) {
+			node.Content = append(node.Content, newSection(next(path)))
+		}
+		child = node.Content[index]
+	default:
+		return fmt.Errorf("line %d: %s is not a section", node.Line, path[0])
+	}
+
+	if len(path) > 1 {
+		return setNode(child, path[1:], value)
+	}
+	value.HeadComment, value.LineComment, value.FootComment = child.HeadComment, child.LineComment, child.FootComment
+	*child = *value
+	return nil
+}
+
+// next returns the segment after the first one of path, or "".
+func next(path []string) string {
+	if len(path) < 2 {
+		return ""
+	}
+	return path[1]
+}
+
+// newSection returns an empty node holding the segment: a list for an
+// index, a mapping for a key and null at the end of the path.
+func newSection(segment string) *yaml.Node {
+	switch {
+	case segment == "":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+	case indexRe.MatchString(segment):
+		return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	default:
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+}
+
+// closestSection returns the deepest node on path that is in the file and
+// the segments below it that are missing.
+func closestSection(body *yaml.Node, path []string) (*yaml.Node, []string) {
+	for i := len(path) - 1; i > 0; i-- {
+		if _, value := findNode(body, path[:i]); value != nil {
+			return value, path[i:]
+		}
+	}
+	return body, path
+}
+
+// lastLine returns the last line of node and its children.
+func lastLine(node *yaml.Node) int {
+	line := node.Line
+	for _, child := range node.Content {
+		line = max(line, lastLine(child))
+	}
+	return line
+}
+
+// sourceText returns how a value written on a single line, a scalar or a
+// flow list or mapping, appears in data, or "" if it can't be told.
+func sourceText(data []byte, node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		switch node.Style {
+		case 0:
+			return node.Value
+		case yaml.DoubleQuotedStyle:
+			if !strings.ContainsAny(node.Value, "\"\\") {
+				return `"` + node.Value + `"`
+			}
+		case yaml.SingleQuotedStyle:
+			return "'" + strings.ReplaceAll(node.Value, "'", "''") + "'"
+		}
+	case yaml.SequenceNode, yaml.MappingNode:
+		if node.Style&yaml.FlowStyle == 0 || lastLine(node) != node.Line {
+			return ""
+		}
+		lines := strings.Split(string(data), "\n")
+		if node.Line > len(lines) || node.Column-1 > len([]rune(lines[node.Line-1])) {
+			return ""
+		}
+		text := string([]rune(lines[node.Line-1])[node.Column-1:])
+
+		// Up to the matching bracket, skipping quoted strings
+		depth, quote := 0, rune(0)
+		for i, r := range text {
+			switch {
+			case quote != 0:
+				if r == quote {
+					quote = 0
+				}
+			case r == '"' || r == '\'':
+				quote = r
+			case r == '[' || r == '{':
+				depth++
+			case r == ']' || r == '}':
+				if depth--; depth == 0 {
+					return text[:i+1]
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// replaceText replaces text at line and column (1-based) with value. A
+// comment after it stays in the same column if there is room.
+func replaceText(data []byte, line, column int, text string, value *yaml.Node) ([]byte, bool) {
+	lines := strings.SplitAfter(string(data), "\n")
+	if line > len(lines) {
+		return nil, false
+	}
+	runes := []rune(lines[line-1])
+	if column-1 > len(runes) {
+		return nil, false
+	}
+	before, after := string(runes[:column-1]), string(runes[column-1:])
+	if !strings.HasPrefix(after, text) {
+		return nil, false
+	}
+
+	written := *value
+	written.HeadComment, written.LineComment, written.FootComment = "", "", ""
+	out, err := encodeNode(&written)
+	if err != nil {
+		return nil, false
+	}
+	replacement := strings.TrimSuffix(string(out), "\n")
+	if strings.Contains(replacement, "\n") {
+		return nil, false
+	}
+
+	rest := after[len(text):]
+	if comment := strings.TrimLeft(rest, " "); strings.HasPrefix(comment, "#") {
+		padding := len(rest) - len(comment) + utf8.RuneCountInString(text) - utf8.RuneCountInString(replacement)
+		rest = strings.Repeat(" ", max(padding, 1)) + comment
+	}
+	lines[line-1] = before + replacement + rest
+	return []byte(strings.Join(lines, "")), true
+}
+
+// insertText adds the keys in missing, holding value, after the given line
+// with the given indentation.
+func insertText(data []byte, after, indent int, missing []string, value *yaml.Node) ([]byte, bool) {
+	lines := strings.SplitAfter(string(data), "\n")
+	if after > len(lines) {
+		return nil, false
+	}
+
+	node := value
+	for i := len(missing) - 1; i >= 0; i-- {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: missing[i]}
+		node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{key, node}}
+	}
+	out, err := encodeNode(node)
+	if err != nil {
+		return nil, false
+	}
+
+	var snippet []string
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(string(out), "\n"), "\n") {
+		snippet = append(snippet, strings.Repeat(" ", indent)+line)
+	}
+	snippet[len(snippet)-1] += "\n"
+	if !strings.HasSuffix(lines[after-1], "\n") {
+		lines[after-1] += "\n"
+	}
+	lines = append(lines[:after], append(snippet, lines[after:]...)...)
+	return []byte(strings.Join(lines, "")), true
+}
+
+// OverriddenBy returns the file and line that override the value of key set
+// in the configuration file at path on this host, a drop-in or a host
+// section, or "" if the file's value is used.
+func OverriddenBy(path, key string) (string, error) {
+	segments := splitPath(key)
+	if len(segments) > 0 && segments[0] == hostsKey {
+		return "", nil
+	}
+	doc, err := readDocument(path)
+	if err != nil {
+		return "", err
+	}
+	used, _ := findNode(doc.root, segments)
+	if used == nil {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config file: %w", err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return "", fmt.Errorf("failed to parse YAML config: %w", err)
+	}
+	if own, _ := findNode(&root, segments); own != nil && doc.files[used] == path && used.Line == own.Line {
+		return "", nil
+	}
+	return doc.origin(segments), nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretKeys are redacted by Effective.
+var secretKeys = []string{"password", "token", "api_key"}
+
+// Effective renders config as YAML with every value annotated with where it
+// came from: "<file>:<line>" for values set in the configuration file at
+// path or one of its drop-ins, or "default". Passwords, tokens and API keys
+// are redacted.
+func Effective(config *FanConfig, path string) ([]byte, error) {
+	doc, err := readDocument(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var out yaml.Node
+	if err := out.Encode(config); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	annotate(&out, doc, nil)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&out); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// annotate sets the line comment of every scalar below node to its origin.
+func annotate(node *yaml.Node, doc *document, path []string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			annotate(value, doc, append(path, key.Value))
+			if slices.Contains(secretKeys, key.Value) && value.Kind == yaml.ScalarNode && value.Value != "" {
+				value.Value, value.Tag, value.Style = "<redacted>", "!!str", 0
+				// Secrets read from a file come from its *_file key
+				if value.LineComment == "default" {
+					value.LineComment = doc.origin(append(path, key.Value+"_file"))
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			node.LineComment = doc.origin(path)
+		}
+		for i, item := range node.Content {
+			annotate(item, doc, append(path, fmt.Sprintf("[%d]", i)))
+		}
+	case yaml.ScalarNode:
+		node.LineComment = doc.origin(path)
+	}
+}
+
+func (d *document) origin(path []string) string {
+	if key, _ := findNode(d.root, path); key != nil {
+		return fmt.Sprintf("%s:%d", d.files[key], key.Line)
+	}
+	return "default"
+}
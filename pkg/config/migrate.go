@@ -0,0 +1,329 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the configuration layout written by this release. Files
+// without a version key are version 0.
+const CurrentVersion = 1
+
+const versionKey = "version"
+
+// migration upgrades a configuration layout by one version. apply is applied
+// to the top-level settings and to every section under hosts; applyMain, if
+// set, only to the top-level settings of fan.yaml, for defaults that
+// drop-ins and host sections inherit from it.
+type migration struct {
+	description string
+	apply       func(settings *yaml.Node) error
+	applyMain   func(settings *yaml.Node) error
+}
+
+// migrations[i] upgrades version i to i+1.
+var migrations = [CurrentVersion]migration{
+	// Version 1 introduced the version key, keeps explicit zeros and turns
+	// history and the control socket on by default
+	{"keep the version 0 defaults", dropDefaultedZeros, keepDisabled},
+}
+
+// defaultedKeys are the settings for which version 0 replaced an explicit 0
+// with the default. Version 1 keeps the 0 (e.g. gpio.pin: 0 is GPIO 0) or
+// rejects it (e.g. temperature.target: 0).
+var defaultedKeys = [][]string{
+	{"gpio", "pin"},
+	{"pwm", "frequency_khz"},
+	{"pwm", "hardware", "channel"},
+	{"temperature", "target"},
+	{"pid", "kp"},
+	{"pid", "ki"},
+	{"pid", "kd"},
+}
+
+// dropDefaultedZeros removes the defaultedKeys set to 0, so the defaults
+// still apply. Sections left empty are removed too.
+func dropDefaultedZeros(settings *yaml.Node) error {
+	for _, path := range defaultedKeys {
+		_, value := findNode(settings, path)
+		if value == nil || (value.ShortTag() != "!!int" && value.ShortTag() != "!!float") {
+			continue
+		}
+		if v, err := strconv.ParseFloat(value.Value, 64); err != nil || v != 0 {
+			continue
+		}
+		for i := len(path); i > 0; i-- {
+			_, section := findNode(settings, path[:i-1])
+			removeKey(section, path[i-1])
+			if len(section.Content) > 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// keepDisabled turns history and the control socket off explicitly unless
+// they are set, as version 0 left them off by default.
+func keepDisabled(settings *yaml.Node) error {
+	for _, section := range []string{"history", "control"} {
+		path := []string{section, "enabled"}
+		if key, _ := findNode(settings, path); key != nil {
+			continue
+		}
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"}
+		if err := setNode(settings, path, value); err != nil {
+			return err
+		}
+	}
+
+	// Keep the host sections last
+	for i := 0; i+1 < len(settings.Content); i += 2 {
+		if settings.Content[i].Value == hostsKey {
+			hosts := slices.Clone(settings.Content[i : i+2])
+			settings.Content = append(slices.Delete(settings.Content, i, i+2), hosts...)
+			break
+		}
+	}
+	return nil
+}
+
+// removeKey removes key and its value from the mapping node.
+func removeKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = slices.Delete(node.Content, i, i+2)
+			return
+		}
+	}
+}
+
+// migrate upgrades a parsed configuration file to CurrentVersion in place
+// and returns the version it had; a file without a version key has version
+// unversioned. dropIn is false for fan.yaml. With stamp, the version key is
+// added or updated; otherwise an existing key is updated and a missing one
+// is left out.
+func migrate(root *yaml.Node, stamp bool, unversioned int, dropIn bool) (int, error) {
+	from, err := fileVersion(root, unversioned)
+	if err != nil || from == CurrentVersion {
+		return from, err
+	}
+	if err := upgrade(root, from, dropIn); err != nil {
+		return 0, err
+	}
+	setVersion(root, stamp)
+	return from, nil
+}
+
+// fileVersion returns the version key of a parsed configuration file, or
+// unversioned if it has none.
+func fileVersion(root *yaml.Node, unversioned int) (int, error) {
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return CurrentVersion, nil
+	}
+	_, value := findNode(root, []string{versionKey})
+	if value == nil {
+		return unversioned, nil
+	}
+	version, err := strconv.Atoi(value.Value)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("line %d: version must be a non-negative integer, got %q", value.Line, value.Value)
+	}
+	if version > CurrentVersion {
+		return 0, fmt.Errorf("line %d: version %d is newer than this release supports (%d), upgrade nanoctl", value.Line, version, CurrentVersion)
+	}
+	return version, nil
+}
+
+// upgrade applies the migrations after version from.
+func upgrade(root *yaml.Node, from int, dropIn bool) error {
+	body := root.Content[0]
+	for version := from; version < CurrentVersion; version++ {
+		m := migrations[version]
+		if m.applyMain != nil && !dropIn {
+			if err := m.applyMain(body); err != nil {
+				return fmt.Errorf("failed to migrate to version %d (%s): %w", version+1, m.description, err)
+			}
+		}
+		sections := []*yaml.Node{body}
+		if _, hosts := findNode(body, []string{hostsKey}); hosts != nil && hosts.Kind == yaml.MappingNode {
+			for i := 1; i < len(hosts.Content); i += 2 {
+				if hosts.Content[i].Kind == yaml.MappingNode {
+					sections = append(sections, hosts.Content[i])
+				}
+			}
+		}
+		for _, section := range sections {
+			if err := m.apply(section); err != nil {
+				return fmt.Errorf("failed to migrate to version %d (%s): %w", version+1, m.description, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setVersion sets the version key to CurrentVersion. A missing key is only
+// added with add.
+func setVersion(root *yaml.Node, add bool) {
+	body := root.Content[0]
+	current := strconv.Itoa(CurrentVersion)
+	if _, value := findNode(body, []string{versionKey}); value != nil {
+		value.Value, value.Tag, value.Style = current, "!!int", 0
+	} else if add {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: versionKey}
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: current}
+		body.Content = append([]*yaml.Node{key, value}, body.Content...)
+	}
+}
+
+// Migrate upgrades a configuration file to CurrentVersion, keeping its
+// comments, and returns the version it had. A file without a version key
+// has version unversioned: 0 for fan.yaml and the version of fan.yaml for
+// its drop-ins, for which dropIn is true. The data is returned unchanged if
+// it is already current. If only the version key changes the file is edited
+// as text; otherwise it is reformatted.
+func Migrate(data []byte, unversioned int, dropIn bool) ([]byte, int, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse YAML config: %w", err)
+	}
+	from, err := fileVersion(&root, unversioned)
+	if err != nil || from == CurrentVersion {
+		return data, from, err
+	}
+
+	before, err := encodeNode(&root)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := upgrade(&root, from, dropIn); err != nil {
+		return nil, 0, err
+	}
+	after, err := encodeNode(&root)
+	if err != nil {
+		return nil, 0, err
+	}
+	if bytes.Equal(before, after) {
+		if out, ok := setVersionText(data); ok {
+			return out, from, nil
+		}
+	}
+
+	setVersion(&root, true)
+	out, err := encodeNode(&root)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, from, nil
+}
+
+// setVersionText sets the version key by editing the file as text: the
+// value is replaced, or the key is added after the header comment. It
+// reports false if the result doesn't have the expected version.
+func setVersionText(data []byte) ([]byte, bool) {
+	lines := strings.SplitAfter(string(data), "\n")
+	line := fmt.Sprintf("%s: %d\n", versionKey, CurrentVersion)
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, false
+	}
+	if key, value := findNode(&root, []string{versionKey}); key != nil && value.Line == key.Line && key.Column == 1 {
+		lines[key.Line-1] = line
+	} else {
+		// After a header comment separated by a blank line, else at the top
+		header := 0
+		for header < len(lines) && strings.HasPrefix(lines[header], "#") {
+			header++
+		}
+		if header > 0 && header < len(lines) && strings.TrimSpace(lines[header]) == "" {
+			lines = slices.Insert(lines, header+1, line, "\n")
+		} else {
+			lines = slices.Insert(lines, 0, line, "\n")
+		}
+	}
+
+	out := []byte(strings.Join(lines, ""))
+	var check yaml.Node
+	if err := yaml.Unmarshal(out, &check); err != nil {
+		return nil, false
+	}
+	if version, err := fileVersion(&check, 0); err != nil || version != CurrentVersion {
+		return nil, false
+	}
+	return out, true
+}
+
+func encodeNode(node *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile replaces the configuration file at path atomically and durably:
+// the data and the rename are synced to disk before it returns. An existing
+// file keeps its permissions, except that only its owner may read it once
+// it holds secrets; a new one is only readable by root if it holds secrets.
+func WriteFile(path string, data []byte) error {
+	mode := configFileMode(data)
+	if info, err := os.Stat(path); err == nil {
+		secrets := mode == 0600
+		mode = info.Mode().Perm()
+		if secrets {
+			mode &= 0600
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes a directory, making a rename in it durable.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to sync config directory: %w", err)
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("failed to sync config directory: %w", err)
+	}
+	return nil
+}
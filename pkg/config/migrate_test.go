@@ -0,0 +1,214 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes files, keyed by their path relative to the
+// configuration directory (e.g. "conf.d/10-pin.yaml"), and returns the path
+// of fan.yaml.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "fan.yaml")
+}
+
+func TestMigrateFixture(t *testing.T) {
+	data, err := os.ReadFile("testdata/v0.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/v0.migrated.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, from, err := Migrate(data, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 0 || string(got) != string(want) {
+		t.Errorf("Migrate = version %d\n%s\nwant version 0\n%s", from, got, want)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		unversioned int
+		dropIn      bool
+		want        string
+		wantFrom    int
+	}{
+		{
+			name:   "version key only",
+			data:   "# Fan\n\ngpio:\n  pin: 12 # Fan header\n",
+			dropIn: true,
+			want:   "# Fan\n\nversion: 1\n\ngpio:\n  pin: 12 # Fan header\n",
+		},
+		{
+			name: "history and control stay off",
+			data: "gpio:\n  pin: 12\nhistory:\n",
+			want: "version: 1\ngpio:\n  pin: 12\nhistory:\n  enabled: false\ncontrol:\n  enabled: false\n",
+		},
+		{
+			name: "history enabled",
+			data: "history:\n  enabled: true\ncontrol:\n  socket: /run/fan.sock\n",
+			want: "version: 1\nhistory:\n  enabled: true\ncontrol:\n  socket: /run/fan.sock\n  enabled: false\n",
+		},
+		{
+			name:   "zero pin",
+			data:   "gpio:\n  chip_name: gpiochip4\n  pin: 0\n",
+			dropIn: true,
+			want:   "version: 1\ngpio:\n  chip_name: gpiochip4\n",
+		},
+		{
+			name:   "zero gains",
+			dropIn: true,
+			data:   "pid:\n  kp: 4\n  ki: 0\n  kd: 0.0\n",
+			want:   "version: 1\npid:\n  kp: 4\n",
+		},
+		{
+			name:   "emptied sections",
+			dropIn: true,
+			data:   "pwm:\n  frequency_khz: 0\n  hardware:\n    channel: 0\ntemperature:\n  target: 0\n",
+			want:   "version: 1\n",
+		},
+		{
+			name:   "zeros in host sections",
+			dropIn: true,
+			data:   "hosts:\n  nas:\n    gpio:\n      pin: 0\n    pid:\n      kd: 0\n      ki: 0.3\n",
+			want:   "version: 1\nhosts:\n  nas:\n    pid:\n      ki: 0.3\n",
+		},
+		{
+			name:   "quoted zero",
+			dropIn: true,
+			data:   "gpio:\n  pin: \"0\"\n",
+			want:   "version: 1\n\ngpio:\n  pin: \"0\"\n",
+		},
+		{
+			name:   "other zeros",
+			dropIn: true,
+			data:   "temperature:\n  filter:\n    max_rate: 0\n",
+			want:   "version: 1\n\ntemperature:\n  filter:\n    max_rate: 0\n",
+		},
+		{
+			name:     "current",
+			data:     "version: 1\ngpio:\n  pin: 0\n",
+			want:     "version: 1\ngpio:\n  pin: 0\n",
+			wantFrom: 1,
+		},
+		{
+			name:        "drop-in of a current file",
+			data:        "gpio:\n  pin: 0\n",
+			unversioned: 1,
+			dropIn:      true,
+			want:        "gpio:\n  pin: 0\n",
+			wantFrom:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, from, err := Migrate([]byte(tt.data), tt.unversioned, tt.dropIn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from != tt.wantFrom || string(got) != tt.want {
+				t.Errorf("Migrate = version %d\n%s\nwant version %d\n%s", from, got, tt.wantFrom, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadVersion0Zeros(t *testing.T) {
+	zeros := "gpio:\n  pin: 0\npwm:\n  frequency_khz: 0\n  hardware:\n    channel: 0\n" +
+		"temperature:\n  target: 0\npid:\n  kp: 0\n  ki: 0\n  kd: 0\n"
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		pin   int
+		ki    float64
+	}{
+		{"version 0 defaults", map[string]string{"fan.yaml": zeros}, 13, 0.1},
+		{"version 1 zeros", map[string]string{"fan.yaml": "version: 1\ngpio:\n  pin: 0\npid:\n  ki: 0\n"}, 0, 0},
+		{"drop-in of a version 0 file", map[string]string{
+			"fan.yaml":           "gpio:\n  pin: 12\n",
+			"conf.d/10-pin.yaml": "gpio:\n  pin: 0\npid:\n  ki: 0\n",
+		}, 12, 0.1},
+		{"drop-in of a version 1 file", map[string]string{
+			"fan.yaml":           "version: 1\ngpio:\n  pin: 12\n",
+			"conf.d/10-pin.yaml": "gpio:\n  pin: 0\npid:\n  ki: 0\n",
+		}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadFanConfig(writeFiles(t, tt.files))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *cfg.GPIO.Pin != tt.pin || *cfg.PID.Ki != tt.ki {
+				t.Errorf("gpio.pin = %d, pid.ki = %g, want %d and %g", *cfg.GPIO.Pin, *cfg.PID.Ki, tt.pin, tt.ki)
+			}
+		})
+	}
+
+	t.Run("all defaults", func(t *testing.T) {
+		cfg, err := LoadFanConfig(writeFiles(t, map[string]string{"fan.yaml": zeros}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []float64{*cfg.PWM.FrequencyKHz, float64(*cfg.PWM.Hardware.Channel), *cfg.Temperature.Target, *cfg.PID.Kp, *cfg.PID.Kd}
+		want := []float64{25, 1, 55, 5, 0.5}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("frequency_khz, channel, target, kp, kd = %v, want %v", got, want)
+				break
+			}
+		}
+	})
+}
+
+func TestLoadHistoryAndControlDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		history bool
+		control bool
+	}{
+		{"version 0", map[string]string{"fan.yaml": "gpio:\n  pin: 12\n"}, false, false},
+		{"version 1", map[string]string{"fan.yaml": "version: 1\n"}, true, true},
+		{"version 0 with history", map[string]string{"fan.yaml": "history:\n  enabled: true\n"}, true, false},
+		{"drop-in of a version 0 file", map[string]string{
+			"fan.yaml":               "gpio:\n  pin: 12\n",
+			"conf.d/10-history.yaml": "history:\n  enabled: true\n",
+		}, true, false},
+		{"host section of a version 0 file", map[string]string{
+			"fan.yaml": "hosts:\n  \"*\":\n    control:\n      enabled: true\n",
+		}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadFanConfig(writeFiles(t, tt.files))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *cfg.History.Enabled != tt.history || *cfg.Control.Enabled != tt.control {
+				t.Errorf("history.enabled = %t, control.enabled = %t, want %t and %t",
+					*cfg.History.Enabled, *cfg.Control.Enabled, tt.history, tt.control)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SchemaID is the JSON Schema dialect of the generated schema.
+const SchemaID = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern matches Go durations such as "500ms" or "1h30m". Zero
+// durations such as "0s" match but are rejected by the spec check.
+const durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+// Schema returns a JSON Schema describing fan.yaml, generated from
+// FanConfig and specs. Editors use it for completion and validation.
+func Schema() ([]byte, error) {
+	schema := typeSchema(nil, reflect.TypeOf(FanConfig{}), nil)
+	schema["$schema"] = SchemaID
+	schema["title"] = "nanoctl fan configuration"
+	schema["properties"].(map[string]any)[hostsKey] = map[string]any{
+		"description":          "Settings for matching hosts, keyed by hostname or glob pattern (e.g. cm5-*)",
+		"type":                 "object",
+		"additionalProperties": map[string]any{"$ref": "#"},
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schema: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// typeSchema returns the schema of the key at path, of type t and a field of
+// the struct parent.
+func typeSchema(parent, t reflect.Type, path []string) map[string]any {
+	schema := map[string]any{}
+	var spec fieldSpec
+	if parent != nil {
+		spec = specFor(parent, path)
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		schema["type"] = "object"
+		properties := map[string]any{}
+		addProperties(properties, t, path)
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+	case reflect.Slice:
+		schema["type"] = "array"
+		// Fields of list items are keyed as <path>[].<key>
+		items := typeSchema(parent, t.Elem(), append(path[:len(path):len(path)], "[]"))
+		if spec.enum != nil {
+			items["enum"] = spec.enum
+		}
+		schema["items"] = items
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = map[string]any{"type": "string"}
+	case reflect.Int:
+		schema["type"] = "integer"
+	case reflect.Float64:
+		schema["type"] = "number"
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.String:
+		schema["type"] = "string"
+		if spec.enum != nil {
+			schema["enum"] = spec.enum
+		}
+		if spec.duration {
+			schema["pattern"] = durationPattern
+		}
+	}
+
+	if spec.description != "" {
+		schema["description"] = spec.description
+	}
+	if spec.def != nil {
+		schema["default"] = spec.def
+	}
+	if spec.min != nil {
+		schema["minimum"] = *spec.min
+	}
+	if spec.max != nil {
+		schema["maximum"] = *spec.max
+	}
+	if spec.positive {
+		schema["exclusiveMinimum"] = 0
+	}
+	return schema
+}
+
+// addProperties adds the fields of the struct t to properties, including
+// those of inline structs.
+func addProperties(properties map[string]any, t reflect.Type, path []string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if strings.Contains(opts, "inline") {
+			addProperties(properties, field.Type, path)
+			continue
+		}
+		properties[name] = typeSchema(t, field.Type, append(path[:len(path):len(path)], name))
+	}
+}
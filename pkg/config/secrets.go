@@ -0,0 +1,196 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envRe matches ${VAR}, ${VAR:-default} and the $${ escape.
+var envRe = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandEnv replaces ${VAR} references in the scalar values below node with
+// the value of the environment variable. ${VAR:-default} uses default when
+// VAR is unset or empty, and $${ is a literal ${. It returns an error for
+// every unset variable without a default.
+func expandEnv(node *yaml.Node, path []string) []error {
+	var errs []error
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			errs = append(errs, expandEnv(child, path)...)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			errs = append(errs, expandEnv(node.Content[i+1], slices.Concat(path, []string{node.Content[i].Value}))...)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			errs = append(errs, expandEnv(item, slices.Concat(path, []string{fmt.Sprintf("[%d]", i)}))...)
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "${") {
+			return nil
+		}
+		node.Value = envRe.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			if ref == "$${" {
+				return "${"
+			}
+			m := envRe.FindStringSubmatch(ref)
+			if value := os.Getenv(m[1]); value != "" {
+				return value
+			}
+			if strings.Contains(ref, ":-") {
+				return m[2]
+			}
+			errs = append(errs, fmt.Errorf("%s: environment variable %s is not set", joinPath(path), m[1]))
+			return ""
+		})
+		// Resolve the type again, ${PORT} may expand to a number
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	}
+	return errs
+}
+
+// authSection is an auth block and its key path.
+type authSection struct {
+	path string
+	auth *AuthConfig
+}
+
+// authSections returns every auth block set in the configuration.
+func (c *FanConfig) authSections() []authSection {
+	var sections []authSection
+	if c.Metrics.Auth != nil {
+		sections = append(sections, authSection{"metrics.auth", &c.Metrics.Auth.AuthConfig})
+	}
+	for i := range c.Metrics.Outputs {
+		if auth := c.Metrics.Outputs[i].Auth; auth != nil {
+			sections = append(sections, authSection{fmt.Sprintf("metrics.outputs[%d].auth", i), auth})
+		}
+	}
+	if p := c.Temperature.Source.Prometheus; p != nil && p.Auth != nil {
+		sections = append(sections, authSection{"temperature.source.prometheus.auth", p.Auth})
+	}
+	if h := c.Temperature.Source.HTTP; h != nil && h.Auth != nil {
+		sections = append(sections, authSection{"temperature.source.http.auth", h.Auth})
+	}
+	if c.MQTT.Auth != nil {
+		sections = append(sections, authSection{"mqtt.auth", c.MQTT.Auth})
+	}
+	return sections
+}
+
+// loadSecretFiles reads the password_file, token_file and api_key_file
+// settings into the corresponding values.
+func (c *FanConfig) loadSecretFiles() []error {
+	var errs []error
+	load := func(path, name string, value *string, file string) {
+		if file == "" {
+			return
+		}
+		if *value != "" {
+			errs = append(errs, fmt.Errorf("%s: set either %s or %s_file, not both", path, name, name))
+			return
+		}
+		secret, err := readSecretFile(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s_file: %w", path+"."+name, err))
+			return
+		}
+		*value = secret
+	}
+
+	for _, section := range c.authSections() {
+		load(section.path, "password", &section.auth.Password, section.auth.PasswordFile)
+		load(section.path, "token", &section.auth.Token, section.auth.TokenFile)
+	}
+	if auth := c.Metrics.Auth; auth != nil {
+		load("metrics.auth", "api_key", &auth.APIKey, auth.APIKeyFile)
+	}
+	return errs
+}
+
+// readSecretFile reads a secret from path. Relative paths are resolved
+// against $CREDENTIALS_DIRECTORY, where systemd places credentials loaded
+// with LoadCredential=. A trailing newline is removed.
+func readSecretFile(path string) (string, error) {
+	if dir := os.Getenv("CREDENTIALS_DIRECTORY"); dir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// containsSecrets reports whether a configuration file has a password,
+// token or API key written in it, rather than read from the environment or
+// a file.
+func containsSecrets(node *yaml.Node) bool {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		return slices.ContainsFunc(node.Content, containsSecrets)
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if slices.Contains(secretKeys, key.Value) && value.Kind == yaml.ScalarNode {
+				if envRe.ReplaceAllString(value.Value, "") != "" {
+					return true
+				}
+				continue
+			}
+			if containsSecrets(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SecretsExposed returns the configuration file at path and drop-ins that
+// contain secrets and can be read by users other than their owner.
+func SecretsExposed(path string) ([]string, error) {
+	files, err := ConfigFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var exposed []string
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if info.Mode().Perm()&0o077 == 0 {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err == nil && containsSecrets(&root) {
+			exposed = append(exposed, file)
+		}
+	}
+	return exposed, nil
+}
+
+// configFileMode returns the permissions for a configuration file: only
+// the owner may read it when it contains secrets.
+func configFileMode(data []byte) os.FileMode {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err == nil && !containsSecrets(&root) {
+		return 0644
+	}
+	return 0600
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("NANOCTL_TEST_PASSWORD", "s3cret")
+	t.Setenv("NANOCTL_TEST_PORT", "1883")
+	t.Setenv("NANOCTL_TEST_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		data    string
+		want    string // Expanded document, if not empty
+		wantErr []string
+	}{
+		{"variable", "password: ${NANOCTL_TEST_PASSWORD}\n", "password: s3cret\n", nil},
+		{"in a string", "broker: tcp://host:${NANOCTL_TEST_PORT}\n", "broker: tcp://host:1883\n", nil},
+		{"default", "user: ${NANOCTL_TEST_UNSET:-fan}\n", "user: fan\n", nil},
+		{"empty uses default", "user: ${NANOCTL_TEST_EMPTY:-fan}\n", "user: fan\n", nil},
+		{"escape", "password: $${NANOCTL_TEST_PASSWORD}\n", "password: ${NANOCTL_TEST_PASSWORD}\n", nil},
+		{"unset", "mqtt:\n  password: ${NANOCTL_TEST_UNSET}\n", "",
+			[]string{"mqtt.password: environment variable NANOCTL_TEST_UNSET is not set"}},
+		{"unset in sequences", "hosts:\n  nas:\n    headers:\n      - ${NANOCTL_TEST_A}\n      - ok\n      - ${NANOCTL_TEST_B}\n", "", []string{
+			"hosts.nas.headers[0]: environment variable NANOCTL_TEST_A is not set",
+			"hosts.nas.headers[2]: environment variable NANOCTL_TEST_B is not set",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var root yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.data), &root); err != nil {
+				t.Fatal(err)
+			}
+
+			var errs []string
+			for _, err := range expandEnv(&root, nil) {
+				errs = append(errs, err.Error())
+			}
+			if !slices.Equal(errs, tt.wantErr) {
+				t.Errorf("expandEnv errors = %q, want %q", errs, tt.wantErr)
+			}
+			if tt.want == "" {
+				return
+			}
+			out, err := yaml.Marshal(&root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("expandEnv =\n%s\nwant\n%s", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandEnvKeepsPath(t *testing.T) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte("gpio:\n  pin: 12\nlist: [a, b]\n"), &root); err != nil {
+		t.Fatal(err)
+	}
+
+	// The callee must not write into the spare capacity of the caller's path
+	backing := []string{"hosts", "", "", ""}
+	expandEnv(&root, backing[:1])
+	if !slices.Equal(backing, []string{"hosts", "", "", ""}) {
+		t.Errorf("expandEnv changed the caller's path to %q", backing)
+	}
+}
@@ -0,0 +1,324 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// fieldSpec documents a configuration value and its constraints. The specs
+// table is the single source for the defaults applied by applyDefaults, the
+// value checks in Validate and the JSON Schema.
+type fieldSpec struct {
+	description string
+	def         any      // Default applied when the value is unset
+	enum        []string // Allowed values
+	min, max    *float64 // Inclusive bounds
+	positive    bool     // Must be greater than 0
+	duration    bool     // Positive Go duration such as "500ms" or "1h30m"
+}
+
+func bounds(min, max float64) fieldSpec { return fieldSpec{min: &min, max: &max} }
+
+func atLeast(min float64) fieldSpec { return fieldSpec{min: &min} }
+
+func (s fieldSpec) describe(description string) fieldSpec {
+	s.description = description
+	return s
+}
+
+func (s fieldSpec) withDefault(def any) fieldSpec {
+	s.def = def
+	return s
+}
+
+var indexRe = regexp.MustCompile(`\[\d+\]`)
+
+var (
+	temperatureUnits = []string{"celsius", "millicelsius"}
+	sourceTypes      = []string{"file", "prometheus", "http", "mqtt", "command"}
+)
+
+// specs maps a key to its fieldSpec. Keys are either paths from the top of
+// the file ("gpio.pin", with "[]" for list items) or, for fields of named
+// types used in several places, "<Type>.<key>" (e.g. "AuthConfig.password").
+var specs = map[string]fieldSpec{
+	"version": {description: "Configuration layout version, upgrade with 'nanoctl config migrate'", def: CurrentVersion},
+
+	"gpio":           {description: "GPIO line driving the fan in software PWM mode"},
+	"gpio.chip_name": {description: "GPIO chip device, e.g. gpiochip0 (CM5) or gpiochip4", def: "gpiochip0"},
+	"gpio.pin":       bounds(0, 27).describe("BCM pin number of the fan PWM line").withDefault(13),
+
+	"pwm":                   {description: "PWM signal settings"},
+	"pwm.mode":              {description: "software (bit-banged GPIO) or hardware (sysfs PWM)", def: "software", enum: []string{"software", "hardware"}},
+	"pwm.frequency_khz":     {description: "Software PWM frequency in kHz, 4-pin fans expect 25", def: 25.0, positive: true},
+	"pwm.hardware":          {description: "Hardware PWM settings, used when pwm.mode is hardware"},
+	"pwm.hardware.chip":     {description: "PWM chip under /sys/class/pwm", def: "pwmchip0"},
+	"pwm.hardware.channel":  atLeast(0).describe("PWM channel, e.g. 1 for pwm1").withDefault(1),
+	"pwm.hardware.inverted": {description: "Invert the duty cycle (high = 0%)"},
+
+	"temperature":        {description: "Temperature source and target"},
+	"temperature.target": bounds(20, 90).describe("Temperature in °C the PID controller maintains").withDefault(55.0),
+	"temperature.source": {description: "Where temperatures are read from"},
+	"temperature.filter": {description: "Smoothing applied before the PID controller, 0 disables a stage"},
+
+	"pid":    {description: "PID controller gains"},
+	"pid.kp": {description: "Proportional gain", def: 5.0, positive: true},
+	"pid.ki": atLeast(0).describe("Integral gain, 0 for a PD controller").withDefault(0.1),
+	"pid.kd": atLeast(0).describe("Derivative gain, 0 for a PI controller").withDefault(0.5),
+
+	"metrics":                     {description: "OpenTelemetry metrics and logs export"},
+	"metrics.enabled":             {description: "Push metrics over OTLP"},
+	"metrics.endpoint":            {description: "OTLP endpoint, e.g. localhost:4317 (gRPC) or http://host:4318/v1/metrics", def: "localhost:4317"},
+	"metrics.insecure":            {description: "Disable TLS"},
+	"metrics.interval":            {description: "Export interval", def: "10s", duration: true},
+	"metrics.auth":                {description: "Credentials sent with every export, token > api_key > username/password"},
+	"metrics.tls":                 {description: "TLS settings for the OTLP endpoint"},
+	"metrics.headers":             {description: "Extra headers sent with every export"},
+	"metrics.resource":            {description: "Resource describing this host"},
+	"metrics.resource.attributes": {description: "Extra resource attributes, e.g. cluster: home"},
+	"metrics.resource.detectors":  {description: "Resource detectors", def: []string{"host", "os"}, enum: ResourceDetectors},
+	"metrics.logs":                {description: "OTLP logs export"},
+	"metrics.logs.enabled":        {description: "Export daemon events as OTLP logs, requires metrics.enabled"},
+	"metrics.prometheus":          {description: "Prometheus scrape endpoint"},
+	"metrics.prometheus.enabled":  {description: "Serve metrics for Prometheus"},
+	"metrics.prometheus.listen":   {description: "Listen address", def: ":9101"},
+	"metrics.prometheus.path":     {description: "HTTP path, must start with /", def: "/metrics"},
+	"metrics.outputs":             {description: "InfluxDB and StatsD outputs"},
+	"metrics.auth.api_key":        {description: "API key"},
+	"metrics.auth.api_key_file":   {description: "File holding the API key, relative to $CREDENTIALS_DIRECTORY when set"},
+	"metrics.auth.api_key_header": {description: "Header carrying the API key, defaults to X-API-Key"},
+
+	"metrics.outputs[].type":     {description: "Output protocol", enum: MetricsOutputTypes},
+	"metrics.outputs[].url":      {description: "http(s):// or udp:// URL for influx, udp://host:port for StatsD"},
+	"metrics.outputs[].interval": {description: "Export interval, defaults to metrics.interval", duration: true},
+	"metrics.outputs[].prefix":   {description: "Prefix added to metric names"},
+	"metrics.outputs[].tags":     {description: "Extra tags added to every metric"},
+	"metrics.outputs[].org":      {description: "InfluxDB v2 organisation"},
+	"metrics.outputs[].bucket":   {description: "InfluxDB v2 bucket"},
+	"metrics.outputs[].database": {description: "InfluxDB v1 database"},
+	"metrics.outputs[].auth":     {description: "InfluxDB token or username/password"},
+	"metrics.outputs[].headers":  {description: "Extra HTTP headers"},
+	"metrics.outputs[].tls":      {description: "TLS settings for https:// URLs"},
+
+	"monitor":                {description: "Control loop settings"},
+	"monitor.check_interval": {description: "How often the temperature is read and the fan adjusted", def: "1s", duration: true},
+
+	"mqtt":                   {description: "MQTT broker, state publishing and commands"},
+	"mqtt.enabled":           {description: "Publish state and accept commands"},
+	"mqtt.broker":            {description: "Broker URL, e.g. tcp://host:1883 or ssl://host:8883", def: "tcp://localhost:1883"},
+	"mqtt.client_id":         {description: "Client ID, defaults to nanoctl-<hostname>"},
+	"mqtt.topic_prefix":      {description: "Topic prefix, defaults to nanoctl/<hostname>"},
+	"mqtt.publish_interval":  {description: "State publish interval", def: "10s", duration: true},
+	"mqtt.auth":              {description: "Broker username and password"},
+	"mqtt.slots":             {description: "Slots exposed for power control"},
+	"mqtt.discovery":         {description: "Home Assistant MQTT discovery"},
+	"mqtt.discovery.enabled": {description: "Publish Home Assistant discovery payloads"},
+	"mqtt.discovery.prefix":  {description: "Discovery topic prefix", def: "homeassistant"},
+
+	"history":                {description: "On-device history of temperatures, duty cycle and events"},
+	"history.enabled":        {description: "Record history", def: true},
+	"history.path":           {description: "History file", def: "/var/lib/nanoctl/history.bin"},
+	"history.resolution":     {description: "Aggregation interval, at least 1s", def: "10s", duration: true},
+	"history.retention":      {description: "How far back history is kept", def: "168h", duration: true},
+	"history.flush_interval": {description: "How often history is written to disk", def: "5m", duration: true},
+
+	"control":         {description: "Local control socket used by nanoctl top"},
+	"control.enabled": {description: "Serve the control socket", def: true},
+	"control.socket":  {description: "Unix socket path, only root can connect", def: DefaultControlSocket},
+	"control.slots":   {description: "Slots that can be powered on, off and reset over the socket"},
+
+	"temperature.filter.max_rate":      atLeast(0).describe("Largest plausible change in °C/s, faster jumps are rejected"),
+	"temperature.filter.median_window": bounds(0, 100).describe("Median of the last N readings"),
+	"temperature.filter.ema_alpha":     bounds(0, 1).describe("Exponential moving average factor, lower is smoother"),
+
+	"temperature.source.primary":    {description: "Primary temperature source", def: "file", enum: sourceTypes},
+	"temperature.source.fallback":   {description: "Source used while the primary fails", def: "file", enum: []string{"file"}},
+	"temperature.source.prometheus": {description: "Prometheus query source"},
+	"temperature.source.http":       {description: "HTTP/JSON endpoint source"},
+	"temperature.source.mqtt":       {description: "MQTT topic source, uses the mqtt broker"},
+	"temperature.source.command":    {description: "External command (plugin) source"},
+	"temperature.source.file":       {description: "Local sensor file source"},
+	"temperature.source.file.path":  {description: "Sensor file in millidegrees Celsius", def: "/sys/class/thermal/thermal_zone0/temp"},
+
+	"temperature.source.prometheus.host":    {description: "Prometheus URL, http://host:port or https://host:port"},
+	"temperature.source.prometheus.query":   {description: "PromQL query, defaults to max(node_hwmon_temp_celsius{sensor=\"temp0\"})"},
+	"temperature.source.prometheus.timeout": {description: "Query timeout, defaults to 5s", duration: true},
+	"temperature.source.prometheus.max_age": {description: "Samples older than this are errors", duration: true},
+	"temperature.source.prometheus.auth":    {description: "Basic or bearer authentication"},
+
+	"temperature.source.http.url":      {description: "Endpoint URL, http:// or https://"},
+	"temperature.source.http.method":   {description: "Request method, defaults to GET"},
+	"temperature.source.http.headers":  {description: "Extra request headers"},
+	"temperature.source.http.body":     {description: "Request body"},
+	"temperature.source.http.selector": {description: "JSONPath-style selector, e.g. $.sensors[0].value"},
+	"temperature.source.http.unit":     {description: "Unit of the value, defaults to celsius", enum: temperatureUnits},
+	"temperature.source.http.timeout":  {description: "Request timeout, defaults to 5s", duration: true},
+	"temperature.source.http.auth":     {description: "Basic or bearer authentication"},
+	"temperature.source.http.tls":      {description: "TLS settings for https:// URLs"},
+
+	"temperature.source.mqtt.topic":    {description: "Topic carrying temperature readings"},
+	"temperature.source.mqtt.selector": {description: "JSONPath-style selector for JSON payloads"},
+	"temperature.source.mqtt.unit":     {description: "Unit of the value, defaults to celsius", enum: temperatureUnits},
+	"temperature.source.mqtt.max_age":  {description: "Readings older than this are errors, defaults to 1m", duration: true},
+
+	"temperature.source.command.path":    {description: "Executable to run"},
+	"temperature.source.command.args":    {description: "Arguments passed to the executable"},
+	"temperature.source.command.timeout": {description: "Timeout per reading, defaults to 5s", duration: true},
+	"temperature.source.command.unit":    {description: "Unit of the value, defaults to celsius", enum: temperatureUnits},
+	"temperature.source.command.stream":  {description: "Keep the command running and read one value per line"},
+
+	"AuthConfig.username":      {description: "Username for basic authentication"},
+	"AuthConfig.password":      {description: "Password for basic authentication"},
+	"AuthConfig.password_file": {description: "File holding the password, relative to $CREDENTIALS_DIRECTORY when set"},
+	"AuthConfig.token":         {description: "Bearer token, takes precedence over basic authentication"},
+	"AuthConfig.token_file":    {description: "File holding the token, relative to $CREDENTIALS_DIRECTORY when set"},
+
+	"TLSConfig.ca_file":              {description: "PEM bundle used to verify the server"},
+	"TLSConfig.cert_file":            {description: "Client certificate (mTLS), requires key_file"},
+	"TLSConfig.key_file":             {description: "Client key (mTLS), requires cert_file"},
+	"TLSConfig.server_name":          {description: "Name used to verify the server certificate"},
+	"TLSConfig.insecure_skip_verify": {description: "Don't verify the server certificate"},
+}
+
+// specFor returns the spec of the key at path, a field of the struct t.
+func specFor(t reflect.Type, path []string) fieldSpec {
+	key := indexRe.ReplaceAllString(joinPath(path), "[]")
+	if spec, ok := specs[key]; ok {
+		return spec
+	}
+	return specs[t.Name()+"."+path[len(path)-1]]
+}
+
+// applySpecDefaults sets every unset value below v (a struct) that has a
+// default in specs. Optional sections that are not set are left out.
+func applySpecDefaults(v reflect.Value, path []string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if strings.Contains(opts, "inline") {
+			applySpecDefaults(value, path)
+			continue
+		}
+		fieldPath := append(path[:len(path):len(path)], name)
+
+		if def := specFor(t, fieldPath).def; def != nil && value.IsZero() {
+			defValue := reflect.ValueOf(def)
+			if value.Kind() == reflect.Pointer {
+				ptr := reflect.New(value.Type().Elem())
+				ptr.Elem().Set(defValue.Convert(value.Type().Elem()))
+				value.Set(ptr)
+			} else {
+				value.Set(defValue.Convert(value.Type()))
+			}
+			continue
+		}
+
+		switch {
+		case value.Kind() == reflect.Struct:
+			applySpecDefaults(value, fieldPath)
+		case value.Kind() == reflect.Pointer && !value.IsNil() && value.Elem().Kind() == reflect.Struct:
+			applySpecDefaults(value.Elem(), fieldPath)
+		case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct:
+			for j := 0; j < value.Len(); j++ {
+				applySpecDefaults(value.Index(j), append(fieldPath, fmt.Sprintf("[%d]", j)))
+			}
+		}
+	}
+}
+
+// checkSpecs checks every value below v against its spec and returns every
+// violation. parent is the struct type holding v. Optional sections
+// that are not set and empty strings are skipped.
+func checkSpecs(parent reflect.Type, v reflect.Value, path []string) []error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	var errs []error
+	switch {
+	case v.Kind() == reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, opts, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			fieldPath := path
+			if !strings.Contains(opts, "inline") {
+				fieldPath = append(path[:len(path):len(path)], name)
+			}
+			errs = append(errs, checkSpecs(t, v.Field(i), fieldPath)...)
+		}
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, checkSpecs(parent, v.Index(i), append(path[:len(path):len(path)], fmt.Sprintf("[%d]", i)))...)
+		}
+	default:
+		if err := specFor(parent, path).check(joinPath(path), v.Interface()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// check checks a single value; name is its key path, used in the error.
+func (spec fieldSpec) check(name string, value any) error {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		if spec.enum != nil && !slices.Contains(spec.enum, v) {
+			return fmt.Errorf("%s must be %s, got '%s'", name, quoteList(spec.enum), v)
+		}
+		if spec.duration {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s must be a valid duration (e.g. '1s', '500ms'): %w", name, err)
+			}
+			if d <= 0 {
+				return fmt.Errorf("%s must be positive, got %s", name, v)
+			}
+		}
+	case []string:
+		for _, item := range v {
+			if spec.enum != nil && !slices.Contains(spec.enum, item) {
+				return fmt.Errorf("%s: unknown value '%s', supported: %s", name, item, strings.Join(spec.enum, ", "))
+			}
+		}
+	case int:
+		return checkNumber(spec, name, float64(v), fmt.Sprint(v))
+	case float64:
+		return checkNumber(spec, name, v, fmt.Sprint(v))
+	}
+	return nil
+}
+
+func checkNumber(spec fieldSpec, name string, v float64, text string) error {
+	switch {
+	case spec.min != nil && spec.max != nil && (v < *spec.min || v > *spec.max):
+		return fmt.Errorf("%s must be between %g and %g, got %s", name, *spec.min, *spec.max, text)
+	case spec.min != nil && v < *spec.min:
+		return fmt.Errorf("%s must be >= %g, got %s", name, *spec.min, text)
+	case spec.positive && v <= 0:
+		return fmt.Errorf("%s must be positive, got %s", name, text)
+	}
+	return nil
+}
+
+// quoteList formats values as "'a', 'b' or 'c'".
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + v + "'"
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// loadConfig loads content as fan.yaml, adding the version key.
+func loadConfig(t *testing.T, content string) (*FanConfig, error) {
+	t.Helper()
+	return LoadFanConfig(writeFiles(t, map[string]string{"fan.yaml": "version: 1\n" + content}))
+}
+
+func TestSpecCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  fieldSpec
+		value any
+		want  string // Empty if the value is valid
+	}{
+		{"duration", fieldSpec{duration: true}, "1m30s", ""},
+		{"zero duration", fieldSpec{duration: true}, "0s", "key must be positive, got 0s"},
+		{"bare zero duration", fieldSpec{duration: true}, "0", "key must be positive, got 0"},
+		{"negative duration", fieldSpec{duration: true}, "-5s", "key must be positive, got -5s"},
+		{"invalid duration", fieldSpec{duration: true}, "5", "key must be a valid duration (e.g. '1s', '500ms'): time: missing unit in duration \"5\""},
+		{"unset duration", fieldSpec{duration: true}, "", ""},
+		{"enum", fieldSpec{enum: []string{"a", "b"}}, "c", "key must be 'a' or 'b', got 'c'"},
+		{"in bounds", bounds(0, 27), 27, ""},
+		{"out of bounds", bounds(0, 27), 28, "key must be between 0 and 27, got 28"},
+		{"below minimum", atLeast(0), -1, "key must be >= 0, got -1"},
+		{"zero not positive", fieldSpec{positive: true}, 0.0, "key must be positive, got 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.spec.check("key", tt.value)
+			if got := errorString(err); got != tt.want {
+				t.Errorf("check(%v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsNonPositiveDurations(t *testing.T) {
+	tests := []struct {
+		key, content string
+	}{
+		{"monitor.check_interval", "monitor:\n  check_interval: %s\n"},
+		{"metrics.interval", "metrics:\n  interval: %s\n"},
+		{"history.flush_interval", "history:\n  flush_interval: %s\n"},
+		{"mqtt.publish_interval", "mqtt:\n  publish_interval: %s\n"},
+		{"metrics.outputs[0].interval", "metrics:\n  outputs:\n    - type: statsd\n      url: udp://localhost:8125\n      interval: %s\n"},
+		{"temperature.source.command.timeout", "temperature:\n  source:\n    command:\n      path: /bin/true\n      timeout: %s\n"},
+		{"temperature.source.prometheus.max_age", "temperature:\n  source:\n    prometheus:\n      host: http://localhost:9090\n      max_age: %s\n"},
+	}
+	for _, tt := range tests {
+		for _, value := range []string{"0s", "-1s"} {
+			t.Run(tt.key+"="+value, func(t *testing.T) {
+				_, err := loadConfig(t, strings.ReplaceAll(tt.content, "%s", value))
+				want := tt.key + " must be positive, got " + value
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("LoadFanConfig error = %v, want %q", err, want)
+				}
+			})
+		}
+	}
+}
+
+// errorString returns the message of err, or "" if err is nil.
+// specKeys returns every key a field below the struct t can be looked up
+// by: its path and, for named types, "<Type>.<key>".
+func specKeys(t reflect.Type, path []string, keys map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if strings.Contains(opts, "inline") {
+			specKeys(ft, path, keys)
+			continue
+		}
+		fieldPath := append(path[:len(path):len(path)], name)
+		keys[joinPath(fieldPath)] = true
+		keys[t.Name()+"."+name] = true
+
+		switch {
+		case ft.Kind() == reflect.Struct:
+			specKeys(ft, fieldPath, keys)
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct:
+			specKeys(ft.Elem(), append(fieldPath, "[]"), keys)
+		}
+	}
+}
+
+func TestSpecKeys(t *testing.T) {
+	keys := map[string]bool{}
+	specKeys(reflect.TypeOf(FanConfig{}), nil, keys)
+
+	for key := range specs {
+		if !keys[key] {
+			t.Errorf("spec %q matches no configuration key", key)
+		}
+		// Type keys are for types used in several places; the others are
+		// keyed by their path, e.g. "mqtt.broker"
+		if typeName, _, ok := strings.Cut(key, "."); ok && typeName != "AuthConfig" && typeName != "TLSConfig" &&
+			strings.ToLower(typeName) != typeName {
+			t.Errorf("spec %q is keyed by type, use its path", key)
+		}
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,130 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Client talks to the control socket of a running fan daemon.
+type Client struct {
+	socket string
+	http   *http.Client
+}
+
+// NewClient returns a client of the control socket at socket.
+func NewClient(socket string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &Client{socket: socket, http: &http.Client{Transport: transport, Timeout: 5 * time.Second}}
+}
+
+// Status returns the state of the daemon.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.do(ctx, http.MethodGet, "/v1/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Samples returns the recent samples of the control loop, oldest first.
+func (c *Client) Samples(ctx context.Context) ([]Sample, error) {
+	var samples []Sample
+	if err := c.do(ctx, http.MethodGet, "/v1/samples", nil, &samples); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// SetOverride forces the fan to dc percent.
+func (c *Client) SetOverride(ctx context.Context, dc float64) (*Status, error) {
+	var status Status
+	if err := c.do(ctx, http.MethodPut, "/v1/fan/override", overrideRequest{DutyCycle: &dc}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ClearOverride returns the fan to automatic control.
+func (c *Client) ClearOverride(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.do(ctx, http.MethodDelete, "/v1/fan/override", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Power starts a power action on slot. It returns before the action is done;
+// its outcome is reported in the slot state.
+func (c *Client) Power(ctx context.Context, slot int, action string) (*SlotState, error) {
+	var state SlotState
+	if err := c.do(ctx, http.MethodPost, "/v1/slots/"+strconv.Itoa(slot)+"/"+action, nil, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	// The host is ignored, the transport always dials the socket
+	req, err := http.NewRequestWithContext(ctx, method, "http://nanoctl"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return c.connError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+			return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		return errors.New(e.Error)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", path, err)
+	}
+	return nil
+}
+
+// connError explains the usual reasons the socket can't be reached.
+func (c *Client) connError(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("%s not found: is nanoctl fan running with control.enabled?", c.socket)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("permission denied on %s: run with sudo", c.socket)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("nothing is listening on %s: is nanoctl fan running?", c.socket)
+	}
+	return fmt.Errorf("failed to reach the fan daemon on %s: %w", c.socket, err)
+}
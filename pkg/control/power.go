@@ -0,0 +1,175 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/gpio"
+)
+
+// Slot power actions, as accepted at /v1/slots/<slot>/<action>.
+const (
+	ActionPowerOn  = "poweron"
+	ActionPowerOff = "poweroff"
+	ActionForceOff = "force_poweroff"
+	ActionReset    = "reset"
+)
+
+// Slot power states.
+const (
+	PowerOn      = "on"
+	PowerOff     = "off"
+	PowerUnknown = "unknown" // No command was sent since the daemon started
+)
+
+// ErrBusy is returned when a command is sent to a slot that is still
+// running the previous one.
+var ErrBusy = errors.New("a power command is already running")
+
+// PowerController is the part of gpio.Controller Power needs.
+type PowerController interface {
+	PowerOn(slot int, boardType gpio.BoardType) error
+	PowerOff(slot int, boardType gpio.BoardType) error
+	ForceOff(slot int, boardType gpio.BoardType) error
+	Reset(slot int, boardType gpio.BoardType) error
+}
+
+// SlotState is the power state of a slot, as last commanded by the daemon.
+// The board doesn't report whether a node is actually running.
+type SlotState struct {
+	Slot      int       `json:"slot"`
+	Power     string    `json:"power"`               // on, off or unknown
+	Busy      bool      `json:"busy"`                // A command is running
+	Action    string    `json:"action,omitempty"`    // The last or running action
+	Error     string    `json:"error,omitempty"`     // Set if the last action failed
+	UpdatedAt time.Time `json:"updated_at,omitzero"` // When the last action finished
+}
+
+// Power runs power commands one at a time and keeps the state of each slot.
+// It implements mqtt.PowerController, so commands from MQTT and the control
+// socket share the state.
+type Power struct {
+	controller PowerController
+
+	run   sync.Mutex // Serialises GPIO operations; each one holds a line for a second or more
+	mu    sync.Mutex
+	slots map[int]*SlotState
+}
+
+// NewPower creates a Power that sends commands through controller.
+func NewPower(controller PowerController) *Power {
+	return &Power{controller: controller, slots: map[int]*SlotState{}}
+}
+
+// PowerOn powers on a node.
+func (p *Power) PowerOn(slot int, boardType gpio.BoardType) error {
+	return p.Do(slot, ActionPowerOn, boardType)
+}
+
+// PowerOff gracefully shuts down a node.
+func (p *Power) PowerOff(slot int, boardType gpio.BoardType) error {
+	return p.Do(slot, ActionPowerOff, boardType)
+}
+
+// Do runs action on slot and blocks until it is done. It returns ErrBusy if
+// the slot is running another command.
+func (p *Power) Do(slot int, action string, boardType gpio.BoardType) error {
+	done, err := p.Start(slot, action, boardType)
+	if err != nil {
+		return err
+	}
+	return <-done
+}
+
+// Start runs action on slot in the background and returns a channel that
+// receives its result. It returns ErrBusy if the slot is running another
+// command.
+func (p *Power) Start(slot int, action string, boardType gpio.BoardType) (<-chan error, error) {
+	run, err := p.command(action)
+	if err != nil {
+		return nil, err
+	}
+	if !p.begin(slot, action) {
+		return nil, ErrBusy
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		p.run.Lock()
+		err := run(slot, boardType)
+		p.run.Unlock()
+
+		p.finish(slot, action, err)
+		done <- err
+	}()
+	return done, nil
+}
+
+// command returns the controller method for action.
+func (p *Power) command(action string) (func(int, gpio.BoardType) error, error) {
+	switch action {
+	case ActionPowerOn:
+		return p.controller.PowerOn, nil
+	case ActionPowerOff:
+		return p.controller.PowerOff, nil
+	case ActionForceOff:
+		return p.controller.ForceOff, nil
+	case ActionReset:
+		return p.controller.Reset, nil
+	}
+	return nil, fmt.Errorf("unknown action '%s' (expected %s, %s, %s or %s)",
+		action, ActionPowerOn, ActionPowerOff, ActionForceOff, ActionReset)
+}
+
+// begin marks slot as busy, unless it already is.
+func (p *Power) begin(slot int, action string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state := p.slot(slot)
+	if state.Busy {
+		return false
+	}
+	state.Busy, state.Action = true, action
+	return true
+}
+
+func (p *Power) finish(slot int, action string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state := p.slot(slot)
+	state.Busy, state.Error, state.UpdatedAt = false, "", time.Now()
+	if err != nil {
+		state.Error = err.Error()
+		return
+	}
+	state.Power = PowerOn
+	if action == ActionPowerOff || action == ActionForceOff {
+		state.Power = PowerOff
+	}
+}
+
+// slot returns the state of slot, creating it if needed. p.mu must be held.
+func (p *Power) slot(slot int) *SlotState {
+	state, ok := p.slots[slot]
+	if !ok {
+		state = &SlotState{Slot: slot, Power: PowerUnknown}
+		p.slots[slot] = state
+	}
+	return state
+}
+
+// States returns the state of the given slots, in order.
+func (p *Power) States(slots []int) []SlotState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sorted := append([]int(nil), slots...)
+	sort.Ints(sorted)
+	states := make([]SlotState, 0, len(sorted))
+	for _, slot := range sorted {
+		states = append(states, *p.slot(slot))
+	}
+	return states
+}
@@ -0,0 +1,323 @@
+// Package control serves the state of the fan daemon on a local Unix socket
+// and accepts fan overrides and slot power commands, for 'nanoctl top'.
+//
+// Endpoints:
+//
+//	GET    /v1/status                 Status
+//	GET    /v1/samples                recent []Sample, oldest first
+//	PUT    /v1/fan/override           {"duty_cycle_percent": 0-100}
+//	DELETE /v1/fan/override           back to automatic control
+//	POST   /v1/slots/<slot>/<action>  poweron, poweroff, force_poweroff or reset
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/fan"
+	"github.com/AlejandroPerez92/nanoctl/pkg/gpio"
+	"github.com/AlejandroPerez92/nanoctl/pkg/logging"
+	"github.com/AlejandroPerez92/nanoctl/pkg/metrics"
+)
+
+// maxSamples is the number of samples kept for /v1/samples, 10 minutes at
+// the default check interval.
+const maxSamples = 600
+
+// FanController is the part of fan.Monitor the server needs.
+type FanController interface {
+	State() fan.State
+	SetOverride(dc float64)
+	ClearOverride()
+}
+
+// Status is the state of the daemon returned by /v1/status.
+type Status struct {
+	Version        string      `json:"version"`
+	Temperature    float64     `json:"temperature"`     // Filtered, °C
+	RawTemperature float64     `json:"raw_temperature"` // As read from the source, °C
+	DutyCycle      float64     `json:"duty_cycle_percent"`
+	Override       bool        `json:"override"`
+	Source         string      `json:"source"` // Active temperature source
+	PID            PIDTerms    `json:"pid"`
+	UpdatedAt      time.Time   `json:"updated_at"` // Of the last control loop iteration
+	Slots          []SlotState `json:"slots"`      // Slots that accept power commands
+}
+
+// PIDTerms are the terms of the last PID update.
+type PIDTerms struct {
+	Setpoint float64 `json:"setpoint"`
+	Error    float64 `json:"error"`
+	P        float64 `json:"p"`
+	I        float64 `json:"i"`
+	D        float64 `json:"d"`
+	Raw      float64 `json:"raw"`
+	Output   float64 `json:"output"`
+}
+
+// Sample is one iteration of the control loop.
+type Sample struct {
+	Time        time.Time `json:"time"`
+	Temperature float64   `json:"temperature"`
+	DutyCycle   float64   `json:"duty_cycle_percent"`
+}
+
+// overrideRequest is the body of PUT /v1/fan/override.
+type overrideRequest struct {
+	DutyCycle *float64 `json:"duty_cycle_percent"`
+}
+
+// errorResponse is the body of every failed request.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Config holds the control server settings.
+type Config struct {
+	Socket         string
+	Slots          []int         // Slots that accept power commands
+	SampleInterval time.Duration // How often the fan state is sampled for /v1/samples
+	Version        string
+	Logger         *slog.Logger // Optional: defaults to slog.Default()
+}
+
+// Server serves the control socket.
+type Server struct {
+	fan    FanController
+	power  *Power
+	config Config
+	logger *slog.Logger
+
+	listener net.Listener // Set by Listen
+
+	mu      sync.Mutex
+	samples []Sample
+}
+
+// NewServer creates a control server. power may be nil if no slots are
+// configured.
+func NewServer(fan FanController, power *Power, config Config) *Server {
+	if config.SampleInterval <= 0 {
+		config.SampleInterval = time.Second
+	}
+	if power == nil {
+		config.Slots = nil
+	}
+	return &Server{
+		fan:    fan,
+		power:  power,
+		config: config,
+		logger: logging.OrDefault(config.Logger).With("component", "control"),
+	}
+}
+
+// Listen creates the socket, which is only accessible by the user running
+// the daemon. It fails if another daemon is serving the socket.
+func (s *Server) Listen() error {
+	if err := os.MkdirAll(filepath.Dir(s.config.Socket), 0o755); err != nil {
+		return fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+	if err := removeStaleSocket(s.config.Socket); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("unix", s.config.Socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	if err := os.Chmod(s.config.Socket, 0o600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to set control socket permissions: %w", err)
+	}
+	s.listener = listener
+	return nil
+}
+
+// removeStaleSocket removes a socket left behind by a daemon that didn't
+// shut down cleanly. A socket that accepts connections belongs to a running
+// daemon and is left alone.
+func removeStaleSocket(path string) error {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	switch {
+	case err == nil:
+		conn.Close()
+		return fmt.Errorf("control socket %s is in use by another daemon", path)
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case !errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("failed to check control socket: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale control socket: %w", err)
+	}
+	return nil
+}
+
+// Run serves requests until ctx is cancelled, calling Listen first if it
+// hasn't been called.
+func (s *Server) Run(ctx context.Context) error {
+	if s.listener == nil {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+
+	server := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	go s.sample(ctx)
+
+	if err := server.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("control socket error: %w", err)
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler of the control endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", s.handleStatus)
+	mux.HandleFunc("GET /v1/samples", s.handleSamples)
+	mux.HandleFunc("PUT /v1/fan/override", s.handleSetOverride)
+	mux.HandleFunc("DELETE /v1/fan/override", s.handleClearOverride)
+	mux.HandleFunc("POST /v1/slots/{slot}/{action}", s.handlePower)
+	return mux
+}
+
+// sample records the fan state every SampleInterval until ctx is cancelled.
+func (s *Server) sample(ctx context.Context) {
+	ticker := time.NewTicker(s.config.SampleInterval)
+	defer ticker.Stop()
+
+	var last time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			state := s.fan.State()
+			if state.UpdatedAt.IsZero() || state.UpdatedAt.Equal(last) {
+				continue
+			}
+			last = state.UpdatedAt
+
+			s.mu.Lock()
+			if len(s.samples) == maxSamples {
+				s.samples = slices.Delete(s.samples, 0, 1)
+			}
+			s.samples = append(s.samples, Sample{Time: state.UpdatedAt, Temperature: state.Temperature, DutyCycle: state.DutyCycle})
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Server) status() Status {
+	state := s.fan.State()
+	status := Status{
+		Version:        s.config.Version,
+		Temperature:    state.Temperature,
+		RawTemperature: state.RawTemperature,
+		DutyCycle:      state.DutyCycle,
+		Override:       state.Override,
+		Source:         state.ActiveSource,
+		PID:            PIDTerms(state.PID),
+		UpdatedAt:      state.UpdatedAt,
+		Slots:          []SlotState{},
+	}
+	if s.power != nil {
+		status.Slots = s.power.States(s.config.Slots)
+	}
+	return status
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.status())
+}
+
+func (s *Server) handleSamples(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	samples := slices.Clone(s.samples)
+	s.mu.Unlock()
+	if samples == nil {
+		samples = []Sample{}
+	}
+	writeJSON(w, http.StatusOK, samples)
+}
+
+func (s *Server) handleSetOverride(w http.ResponseWriter, r *http.Request) {
+	var req overrideRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.DutyCycle == nil || *req.DutyCycle < 0 || *req.DutyCycle > 100 {
+		writeError(w, http.StatusBadRequest, errors.New("duty_cycle_percent must be between 0 and 100"))
+		return
+	}
+
+	s.fan.SetOverride(*req.DutyCycle)
+	s.logger.Info("Fan override set", metrics.Event(metrics.EventOverride), "origin", "control", "duty_cycle", *req.DutyCycle)
+	writeJSON(w, http.StatusOK, s.status())
+}
+
+func (s *Server) handleClearOverride(w http.ResponseWriter, r *http.Request) {
+	s.fan.ClearOverride()
+	s.logger.Info("Fan override cleared", metrics.Event(metrics.EventOverride), "origin", "control")
+	writeJSON(w, http.StatusOK, s.status())
+}
+
+func (s *Server) handlePower(w http.ResponseWriter, r *http.Request) {
+	slot, err := strconv.Atoi(r.PathValue("slot"))
+	if err != nil || !slices.Contains(s.config.Slots, slot) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("slot %s is not in control.slots", r.PathValue("slot")))
+		return
+	}
+	action := r.PathValue("action")
+
+	done, err := s.power.Start(slot, action, gpio.BoardCM5)
+	switch {
+	case errors.Is(err, ErrBusy):
+		writeError(w, http.StatusConflict, fmt.Errorf("slot %d: %w", slot, err))
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	logger := s.logger.With(metrics.Event(metrics.EventPower), "origin", "control", "slot", slot, "action", action)
+	go func() {
+		if err := <-done; err != nil {
+			logger.Error("Power operation failed", "error", err)
+			return
+		}
+		logger.Info("Power operation sent")
+	}()
+	writeJSON(w, http.StatusAccepted, s.power.States([]int{slot})[0])
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,155 @@
+package control
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/fan"
+	"github.com/AlejandroPerez92/nanoctl/pkg/gpio"
+)
+
+func newTestServer(socket string) *Server {
+	return NewServer(nil, nil, Config{Socket: socket, Logger: slog.New(slog.DiscardHandler)})
+}
+
+// fakeFan is a FanController with a fixed state.
+type fakeFan struct {
+	mu       sync.Mutex
+	override *float64
+}
+
+func (f *fakeFan) State() fan.State {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state := fan.State{Temperature: 52, DutyCycle: 40, UpdatedAt: time.Unix(1_700_000_000, 0)}
+	if f.override != nil {
+		state.DutyCycle, state.Override = *f.override, true
+	}
+	return state
+}
+
+func (f *fakeFan) SetOverride(dc float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.override = &dc
+}
+
+func (f *fakeFan) ClearOverride() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.override = nil
+}
+
+// fakePower is a PowerController that succeeds at once.
+type fakePower struct{}
+
+func (fakePower) PowerOn(int, gpio.BoardType) error  { return nil }
+func (fakePower) PowerOff(int, gpio.BoardType) error { return nil }
+func (fakePower) ForceOff(int, gpio.BoardType) error { return nil }
+func (fakePower) Reset(int, gpio.BoardType) error    { return nil }
+
+func TestServerListen(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, socket string)
+		wantErr string // Empty if Listen is expected to succeed
+	}{
+		{"no socket", func(*testing.T, string) {}, ""},
+		{"stale socket", func(t *testing.T, socket string) {
+			// A daemon that was killed leaves the socket file behind
+			listener, err := net.Listen("unix", socket)
+			if err != nil {
+				t.Fatal(err)
+			}
+			listener.(*net.UnixListener).SetUnlinkOnClose(false)
+			listener.Close()
+		}, ""},
+		{"running daemon", func(t *testing.T, socket string) {
+			listener, err := net.Listen("unix", socket)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { listener.Close() })
+		}, "in use by another daemon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			socket := filepath.Join(t.TempDir(), "run", "control.sock")
+			if err := os.MkdirAll(filepath.Dir(socket), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			tt.setup(t, socket)
+
+			server := newTestServer(socket)
+			err := server.Listen()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Listen error = %v, want %q", err, tt.wantErr)
+				}
+				// The running daemon keeps its socket
+				if _, err := os.Stat(socket); err != nil {
+					t.Errorf("socket of the running daemon: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.listener.Close()
+
+			info, err := os.Stat(socket)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0o600 {
+				t.Errorf("socket permissions = %o, want 600", perm)
+			}
+		})
+	}
+}
+
+func TestServerHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantBody string // Substring of the response
+	}{
+		{"status", http.MethodGet, "/v1/status", "", http.StatusOK, `"duty_cycle_percent":40`},
+		{"no samples", http.MethodGet, "/v1/samples", "", http.StatusOK, "[]"},
+		{"override", http.MethodPut, "/v1/fan/override", `{"duty_cycle_percent": 75}`, http.StatusOK, `"override":true`},
+		{"override out of range", http.MethodPut, "/v1/fan/override", `{"duty_cycle_percent": 101}`, http.StatusBadRequest, "between 0 and 100"},
+		{"override without duty cycle", http.MethodPut, "/v1/fan/override", `{}`, http.StatusBadRequest, "between 0 and 100"},
+		{"override not JSON", http.MethodPut, "/v1/fan/override", `75`, http.StatusBadRequest, "invalid request body"},
+		{"clear override", http.MethodDelete, "/v1/fan/override", "", http.StatusOK, `"override":false`},
+		{"power", http.MethodPost, "/v1/slots/2/poweron", "", http.StatusAccepted, `"slot":2`},
+		{"slot not configured", http.MethodPost, "/v1/slots/3/poweron", "", http.StatusNotFound, "not in control.slots"},
+		{"unknown action", http.MethodPost, "/v1/slots/2/explode", "", http.StatusBadRequest, "unknown action"},
+		{"wrong method", http.MethodGet, "/v1/fan/override", "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(&fakeFan{}, NewPower(fakePower{}), Config{
+				Slots:  []int{1, 2},
+				Logger: slog.New(slog.DiscardHandler),
+			})
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s = %d %s, want %d with %q", tt.method, tt.path, rec.Code, rec.Body, tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Package doctor checks the hardware, configuration and services nanoctl
+// depends on, and suggests how to fix what it finds.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/config"
+	"golang.org/x/sys/unix"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip" // The check doesn't apply to the configuration
+)
+
+// Check is the result of one check.
+type Check struct {
+	Group   string `json:"group" yaml:"group"` // config, service, gpio, pwm, boot, temperature or network
+	Name    string `json:"name" yaml:"name"`
+	Status  Status `json:"status" yaml:"status"`
+	Message string `json:"message" yaml:"message"`
+	Hint    string `json:"hint,omitempty" yaml:"hint,omitempty"` // How to fix a warning or failure
+}
+
+// Doctor runs the checks against a system.
+type Doctor struct {
+	// Root is the directory system paths such as /sys and /dev are read
+	// from. Any other root than / is a copy of a system, e.g. a fake sysfs
+	// in tests; devices are then not opened, systemctl is not run and the
+	// network is not checked.
+	Root       string
+	ConfigPath string        // fan.yaml
+	Timeout    time.Duration // Of each network check
+
+	config     *config.FanConfig
+	servicePID int // Main PID of the running daemon, 0 if not running
+	checks     []Check
+}
+
+// New returns a Doctor for the system under root and the configuration file
+// at configPath.
+func New(root, configPath string) *Doctor {
+	if root == "" {
+		root = "/"
+	}
+	return &Doctor{Root: root, ConfigPath: configPath, Timeout: 5 * time.Second}
+}
+
+// Run runs every check and returns the results in order.
+func (d *Doctor) Run(ctx context.Context) []Check {
+	d.config, d.servicePID, d.checks = nil, 0, nil
+
+	d.checkConfig()
+	d.checkService(ctx)
+	if d.config != nil {
+		d.checkGPIO()
+		d.checkPWM()
+		d.checkBootConfig()
+		d.checkTemperature()
+	}
+	d.checkHwmon()
+	if d.config != nil {
+		d.checkEndpoints(ctx)
+	}
+	return d.checks
+}
+
+// live reports whether the checks run against this system rather than a
+// copy under Root.
+func (d *Doctor) live() bool {
+	return filepath.Clean(d.Root) == "/"
+}
+
+// path returns the location of the system path name under Root.
+func (d *Doctor) path(name string) string {
+	return filepath.Join(d.Root, name)
+}
+
+func (d *Doctor) add(group, name string, status Status, message, hint string) {
+	d.checks = append(d.checks, Check{Group: group, Name: name, Status: status, Message: message, Hint: hint})
+}
+
+// addAccess adds a check of the permissions of path, which the daemon
+// reads, or also writes if write is set. It returns false if the check
+// failed.
+func (d *Doctor) addAccess(group, name, path string, write bool) bool {
+	err := access(path, write)
+	if err == nil {
+		return true
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		d.add(group, name, StatusFail, fmt.Sprintf("%s: %v", path, err), "")
+		return false
+	}
+
+	what := "readable"
+	if write {
+		what = "writable"
+	}
+	if os.Geteuid() != 0 {
+		d.add(group, name, StatusWarn, fmt.Sprintf("%s is not %s by %s", path, what, currentUser()),
+			"The fan service runs as root; run nanoctl doctor with sudo to check its permissions")
+		return false
+	}
+	d.add(group, name, StatusFail, fmt.Sprintf("%s is not %s", path, what),
+		"Check the overlay that creates it and that nothing else (e.g. a kernel driver) has claimed it")
+	return false
+}
+
+// access checks that the current user can open path for reading, and also
+// for writing if write is set. Root can open any file except sysfs
+// attributes without a write permission bit, so only those are checked.
+func access(path string, write bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if os.Geteuid() == 0 {
+		if write && info.Mode().Perm()&0o222 == 0 {
+			return fs.ErrPermission
+		}
+		return nil
+	}
+
+	mode := uint32(unix.R_OK)
+	if write {
+		mode |= unix.W_OK
+	}
+	return unix.Access(path, mode)
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return fmt.Sprintf("uid %d", os.Geteuid())
+}
+
+// readValue returns the trimmed content of a sysfs attribute.
+func readValue(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// names returns the base names of the files matching pattern under Root,
+// or "none".
+func (d *Doctor) names(pattern string) string {
+	matches, _ := filepath.Glob(d.path(pattern))
+	if len(matches) == 0 {
+		return "none"
+	}
+	names := make([]string, len(matches))
+	for i, match := range matches {
+		names[i] = filepath.Base(match)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,93 @@
+package doctor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/config"
+)
+
+// testRoot is a fake CM5 system: gpiochip0 and gpiochip4, pwmchip0 with 2
+// channels and pwm1 exported, pwmchip2 with an invalid channel count, a
+// thermal zone reading 52.3°C and one out of range, the cpu_thermal and
+// pwmfan hwmon sensors, a pwm-2chan overlay on GPIO 18 and 19 and the
+// service unit.
+const testRoot = "testdata/cm5"
+
+// testConfig returns the configuration in content with defaults applied.
+func testConfig(t *testing.T, content string) *config.FanConfig {
+	t.Helper()
+	path := writeFile(t, "fan.yaml", "version: 1\n"+content)
+	cfg, err := config.LoadFanConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// writeFile writes content to a new file called name and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// runCheck runs check against the system under root with cfg and returns
+// the results.
+func runCheck(root string, cfg *config.FanConfig, check func(*Doctor)) []Check {
+	d := New(root, "")
+	d.config = cfg
+	check(d)
+	return d.checks
+}
+
+// compareChecks reports the difference between the checks got and want.
+func compareChecks(t *testing.T, got, want []Check) {
+	t.Helper()
+	if slices.Equal(got, want) {
+		return
+	}
+	t.Errorf("got %d check(s):", len(got))
+	for _, check := range got {
+		t.Errorf("  %+v", check)
+	}
+	t.Errorf("want %d:", len(want))
+	for _, check := range want {
+		t.Errorf("  %+v", check)
+	}
+}
+
+func TestRun(t *testing.T) {
+	configPath := writeFile(t, "fan.yaml", "version: 1\nmetrics:\n  enabled: true\n  endpoint: \"localhost:1\"\n")
+	checks := New(testRoot, configPath).Run(t.Context())
+
+	if len(checks) == 0 || checks[0].Group != "config" || checks[0].Status == StatusFail {
+		t.Fatalf("the configuration check failed: %+v", checks)
+	}
+	// The first check depends on whether the sensor file exists on this host
+	type result struct {
+		group, name string
+		status      Status
+	}
+	var got []result
+	for _, check := range checks[1:] {
+		got = append(got, result{check.Group, check.Name, check.Status})
+	}
+	want := []result{
+		{"service", "unit", StatusPass},
+		{"gpio", "chip", StatusPass},
+		{"pwm", "chip", StatusSkip},
+		{"boot", "overlay", StatusPass},
+		{"temperature", "file", StatusPass},
+		{"temperature", "hwmon", StatusPass},
+		{"network", "endpoints", StatusSkip},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("checks =\n%+v\nwant\n%+v", got, want)
+	}
+}
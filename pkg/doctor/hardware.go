@@ -0,0 +1,359 @@
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/warthog618/go-gpiocdev"
+)
+
+// bootConfigs are the locations of the Raspberry Pi boot configuration; the
+// first one found is used.
+var bootConfigs = []string{"/boot/firmware/config.txt", "/boot/config.txt"}
+
+// pwmOverlayPins lists the pin parameters of the overlays that route GPIOs
+// to a PWM controller, with their defaults.
+var pwmOverlayPins = map[string][]string{
+	"pwm":       {"pin=18"},
+	"pwm-2chan": {"pin=18", "pin2=19"},
+	"pwm-gpio":  {"gpio=4"},
+	"pwm-pio":   {"gpio=4"},
+}
+
+// checkGPIO checks the chip and line of software PWM.
+func (d *Doctor) checkGPIO() {
+	if d.config.PWM.Mode != "software" {
+		d.add("gpio", "chip", StatusSkip, fmt.Sprintf("pwm.mode is %s, the gpio settings are not used", d.config.PWM.Mode), "")
+		return
+	}
+
+	chip, pin := d.config.GPIO.ChipName, *d.config.GPIO.Pin
+	dev := d.path(filepath.Join("/dev", chip))
+	if _, err := os.Stat(dev); err != nil {
+		d.add("gpio", "chip", StatusFail, fmt.Sprintf("%s not found", dev),
+			fmt.Sprintf("Set gpio.chip_name to one of the chips listed by nanoctl info (found: %s)", d.names("/dev/gpiochip*")))
+		return
+	}
+	if !d.addAccess("gpio", "chip", dev, false) {
+		return
+	}
+	if !d.live() {
+		d.add("gpio", "chip", StatusPass, fmt.Sprintf("%s found", dev), "")
+		return
+	}
+
+	c, err := gpiocdev.NewChip(dev)
+	if err != nil {
+		d.add("gpio", "chip", StatusFail, fmt.Sprintf("%s: %v", dev, err), "Check that gpio.chip_name is a GPIO character device")
+		return
+	}
+	defer c.Close()
+	d.add("gpio", "chip", StatusPass, fmt.Sprintf("%s (%s) has %d lines", chip, c.Label, c.Lines()), "")
+
+	if pin >= c.Lines() {
+		d.add("gpio", "line", StatusFail, fmt.Sprintf("gpio.pin %d is out of range, %s has %d lines", pin, chip, c.Lines()),
+			fmt.Sprintf("Set gpio.pin to the fan's line, see nanoctl info --lines %s", chip))
+		return
+	}
+	info, err := c.LineInfo(pin)
+	if err != nil {
+		d.add("gpio", "line", StatusFail, fmt.Sprintf("line %d: %v", pin, err), "")
+		return
+	}
+	line := fmt.Sprintf("line %d", pin)
+	if info.Name != "" {
+		line += " (" + info.Name + ")"
+	}
+	switch {
+	case !info.Used:
+		d.add("gpio", "line", StatusPass, line+" is free", "")
+	case d.servicePID != 0 && info.Consumer == fmt.Sprintf("gpiocdev-%d", d.servicePID):
+		d.add("gpio", "line", StatusPass, line+" is in use by the fan daemon", "")
+	case info.Consumer == "":
+		d.add("gpio", "line", StatusFail, line+" is claimed by the kernel",
+			"Another function uses the pin, e.g. an overlay in config.txt; remove it or set gpio.pin to another line")
+	default:
+		d.add("gpio", "line", StatusFail, fmt.Sprintf("%s is in use by '%s'", line, info.Consumer),
+			"Stop the program using the line or set gpio.pin to another line")
+	}
+}
+
+// checkPWM checks the sysfs chip and channel of hardware PWM.
+func (d *Doctor) checkPWM() {
+	if d.config.PWM.Mode != "hardware" {
+		d.add("pwm", "chip", StatusSkip, fmt.Sprintf("pwm.mode is %s, no PWM chip is used", d.config.PWM.Mode), "")
+		return
+	}
+
+	chip, channel := d.config.PWM.Hardware.Chip, *d.config.PWM.Hardware.Channel
+	dir := d.path(filepath.Join("/sys/class/pwm", chip))
+	if _, err := os.Stat(dir); err != nil {
+		hint := "No PWM chips found; enable a PWM overlay in config.txt and reboot"
+		if found := d.names("/sys/class/pwm/pwmchip*"); found != "none" {
+			hint = "Set pwm.hardware.chip to one of: " + found
+		}
+		d.add("pwm", "chip", StatusFail, fmt.Sprintf("%s not found", dir), hint)
+		return
+	}
+	value, err := readValue(filepath.Join(dir, "npwm"))
+	if err != nil {
+		d.add("pwm", "chip", StatusFail, err.Error(), "")
+		return
+	}
+	channels, err := strconv.Atoi(value)
+	if err != nil {
+		d.add("pwm", "chip", StatusFail, fmt.Sprintf("%s/npwm: invalid channel count '%s'", dir, value), "")
+		return
+	}
+	if channel >= channels {
+		d.add("pwm", "chip", StatusFail, fmt.Sprintf("pwm.hardware.channel %d doesn't exist, %s has %d channel(s)", channel, chip, channels),
+			fmt.Sprintf("Set pwm.hardware.channel to the fan's channel, 0 to %d", channels-1))
+		return
+	}
+	d.add("pwm", "chip", StatusPass, fmt.Sprintf("%s has %d channel(s)", chip, channels), "")
+
+	channelDir := filepath.Join(dir, fmt.Sprintf("pwm%d", channel))
+	if _, err := os.Stat(channelDir); err != nil {
+		if d.addAccess("pwm", "channel", filepath.Join(dir, "export"), true) {
+			d.add("pwm", "channel", StatusPass, fmt.Sprintf("pwm%d is not exported yet, the daemon exports it when it starts", channel), "")
+		}
+		return
+	}
+	for _, name := range []string{"period", "duty_cycle", "enable"} {
+		if !d.addAccess("pwm", "channel", filepath.Join(channelDir, name), true) {
+			return
+		}
+	}
+	state := "disabled"
+	if enable, _ := readValue(filepath.Join(channelDir, "enable")); enable == "1" {
+		state = "enabled"
+	}
+	message := fmt.Sprintf("pwm%d is exported and %s", channel, state)
+	if period, err := readValue(filepath.Join(channelDir, "period")); err == nil && period != "0" {
+		message += fmt.Sprintf(", period %s ns", period)
+	}
+	d.add("pwm", "channel", StatusPass, message, "")
+}
+
+// checkBootConfig checks that config.txt routes the fan pin to the PWM
+// controller for hardware PWM, and doesn't for software PWM.
+func (d *Doctor) checkBootConfig() {
+	var path string
+	for _, name := range bootConfigs {
+		if _, err := os.Stat(d.path(name)); err == nil {
+			path = name
+			break
+		}
+	}
+	if path == "" {
+		d.add("boot", "overlay", StatusSkip, "config.txt not found, not a Raspberry Pi OS system", "")
+		return
+	}
+
+	overlays, err := readOverlays(d.path(path))
+	if err != nil {
+		d.add("boot", "overlay", StatusFail, err.Error(), "")
+		return
+	}
+	var pwm []overlay
+	for _, o := range overlays {
+		if o.pwmPins() != nil {
+			pwm = append(pwm, o)
+		}
+	}
+
+	if d.config.PWM.Mode == "hardware" {
+		if len(pwm) == 0 {
+			d.add("boot", "overlay", StatusFail, fmt.Sprintf("no PWM overlay in %s", path),
+				fmt.Sprintf("Add dtoverlay=pwm-2chan,pin=12,func=4,pin2=13,func2=4 to %s and reboot", path))
+			return
+		}
+		descriptions := make([]string, len(pwm))
+		for i, o := range pwm {
+			descriptions[i] = o.String()
+		}
+		d.add("boot", "overlay", StatusPass, fmt.Sprintf("%s: %s", path, strings.Join(descriptions, "; ")), "")
+		return
+	}
+
+	pin := *d.config.GPIO.Pin
+	for _, o := range pwm {
+		if slices.Contains(o.pwmPins(), pin) {
+			d.add("boot", "overlay", StatusFail, fmt.Sprintf("GPIO %d is routed to PWM by dtoverlay=%s (%s:%d)", pin, o.Name, path, o.Line),
+				"Set pwm.mode to hardware, or remove the overlay and reboot")
+			return
+		}
+	}
+	d.add("boot", "overlay", StatusPass, fmt.Sprintf("no PWM overlay in %s uses GPIO %d", path, pin), "")
+}
+
+// overlay is a dtoverlay line of config.txt with its parameters.
+type overlay struct {
+	Line   int
+	Name   string
+	Params map[string]string
+}
+
+// readOverlays returns the overlays loaded by config.txt. Parameters set
+// with dtparam lines after an overlay are added to it.
+func readOverlays(path string) ([]overlay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var overlays []overlay
+	current := -1
+	for i, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		params := strings.Split(value, ",")
+		switch strings.TrimSpace(key) {
+		case "dtoverlay":
+			if params[0] == "" {
+				current = -1 // Ends the parameters of the previous overlay
+				continue
+			}
+			overlays = append(overlays, overlay{Line: i + 1, Name: params[0], Params: map[string]string{}})
+			current = len(overlays) - 1
+			overlays[current].set(params[1:])
+		case "dtparam":
+			if current >= 0 {
+				overlays[current].set(params)
+			}
+		}
+	}
+	return overlays, nil
+}
+
+func (o overlay) set(params []string) {
+	for _, param := range params {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok {
+			value = "on"
+		}
+		o.Params[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+}
+
+// pwmPins returns the GPIOs a PWM overlay routes to a PWM controller, or nil
+// for other overlays.
+func (o overlay) pwmPins() []int {
+	var pins []int
+	for _, param := range pwmOverlayPins[o.Name] {
+		name, def, _ := strings.Cut(param, "=")
+		value, ok := o.Params[name]
+		if !ok {
+			value = def
+		}
+		if pin, err := strconv.Atoi(value); err == nil {
+			pins = append(pins, pin)
+		}
+	}
+	return pins
+}
+
+func (o overlay) String() string {
+	pins := make([]string, 0, 2)
+	for _, pin := range o.pwmPins() {
+		pins = append(pins, strconv.Itoa(pin))
+	}
+	return fmt.Sprintf("dtoverlay=%s (line %d) routes GPIO %s to PWM", o.Name, o.Line, strings.Join(pins, ", "))
+}
+
+// checkTemperature checks the sensor file of the file source.
+func (d *Doctor) checkTemperature() {
+	source := d.config.Temperature.Source
+	if source.Primary != "file" && source.Fallback != "file" {
+		d.add("temperature", "file", StatusSkip, "the file source is not used", "")
+		return
+	}
+
+	path := source.File.Path
+	hint := "Set temperature.source.file.path to a sensor file, found: " + d.thermalZones()
+	value, err := readValue(d.path(path))
+	if err != nil {
+		d.add("temperature", "file", StatusFail, err.Error(), hint)
+		return
+	}
+	milli, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		d.add("temperature", "file", StatusFail, fmt.Sprintf("%s doesn't contain a temperature: '%s'", path, value), hint)
+		return
+	}
+	celsius := float64(milli) / 1000
+	if celsius < -40 || celsius > 150 {
+		d.add("temperature", "file", StatusWarn, fmt.Sprintf("%s reads %.1f°C", path, celsius),
+			"The file must contain millidegrees Celsius, e.g. 52300 for 52.3°C")
+		return
+	}
+
+	message := fmt.Sprintf("%s reads %.1f°C", path, celsius)
+	if zone, err := readValue(d.path(filepath.Join(filepath.Dir(path), "type"))); err == nil {
+		message += " (" + zone + ")"
+	}
+	d.add("temperature", "file", StatusPass, message, "")
+}
+
+// thermalZones lists the sensor files of the thermal zones and their types.
+func (d *Doctor) thermalZones() string {
+	dirs, _ := filepath.Glob(d.path("/sys/class/thermal/thermal_zone*"))
+	var zones []string
+	for _, dir := range dirs {
+		zone := filepath.Join("/sys/class/thermal", filepath.Base(dir), "temp")
+		if kind, err := readValue(filepath.Join(dir, "type")); err == nil {
+			zone += " (" + kind + ")"
+		}
+		zones = append(zones, zone)
+	}
+	if len(zones) == 0 {
+		return "none"
+	}
+	return strings.Join(zones, ", ")
+}
+
+// checkHwmon lists the hwmon sensors, and warns if the kernel's fan driver
+// may fight over the PWM channel used by the daemon.
+func (d *Doctor) checkHwmon() {
+	dirs, _ := filepath.Glob(d.path("/sys/class/hwmon/hwmon*"))
+	var sensors []string
+	var pwmFan string
+	for _, dir := range dirs {
+		name, err := readValue(filepath.Join(dir, "name"))
+		if err != nil {
+			continue
+		}
+		sensor := name
+		if value, err := readValue(filepath.Join(dir, "temp1_input")); err == nil {
+			if milli, err := strconv.ParseInt(value, 10, 64); err == nil {
+				sensor += fmt.Sprintf(" %.1f°C", float64(milli)/1000)
+			}
+		}
+		if rpm, err := readValue(filepath.Join(dir, "fan1_input")); err == nil {
+			sensor += " " + rpm + " RPM"
+		}
+		sensors = append(sensors, sensor)
+		if name == "pwmfan" {
+			pwmFan = filepath.Base(dir)
+		}
+	}
+	if len(sensors) == 0 {
+		d.add("temperature", "hwmon", StatusSkip, "no hwmon sensors found", "")
+		return
+	}
+
+	message := "found " + strings.Join(sensors, ", ")
+	if pwmFan != "" && d.config != nil && d.config.PWM.Mode == "hardware" {
+		d.add("temperature", "hwmon", StatusWarn, fmt.Sprintf("the kernel pwm-fan driver (%s) also controls a fan; %s", pwmFan, message),
+			"If it drives the same fan, disable it in config.txt so it doesn't fight nanoctl over the duty cycle")
+		return
+	}
+	d.add("temperature", "hwmon", StatusPass, message, "")
+}
@@ -0,0 +1,253 @@
+package doctor
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestCheckGPIO(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []Check
+	}{
+		{"chip found", "", []Check{
+			{Group: "gpio", Name: "chip", Status: StatusPass, Message: "testdata/cm5/dev/gpiochip0 found"},
+		}},
+		{"chip missing", "gpio:\n  chip_name: gpiochip9\n", []Check{
+			{Group: "gpio", Name: "chip", Status: StatusFail, Message: "testdata/cm5/dev/gpiochip9 not found",
+				Hint: "Set gpio.chip_name to one of the chips listed by nanoctl info (found: gpiochip0, gpiochip4)"},
+		}},
+		{"hardware PWM", "pwm:\n  mode: hardware\n", []Check{
+			{Group: "gpio", Name: "chip", Status: StatusSkip, Message: "pwm.mode is hardware, the gpio settings are not used"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runCheck(testRoot, testConfig(t, tt.config), (*Doctor).checkGPIO)
+			compareChecks(t, got, tt.want)
+		})
+	}
+}
+
+func TestCheckPWM(t *testing.T) {
+	hardware := func(chip, channel string) string {
+		return "pwm:\n  mode: hardware\n  hardware:\n    chip: " + chip + "\n    channel: " + channel + "\n"
+	}
+	chipPass := Check{Group: "pwm", Name: "chip", Status: StatusPass, Message: "pwmchip0 has 2 channel(s)"}
+	empty := t.TempDir()
+
+	tests := []struct {
+		name   string
+		root   string
+		config string
+		want   []Check
+	}{
+		{"software PWM", testRoot, "", []Check{
+			{Group: "pwm", Name: "chip", Status: StatusSkip, Message: "pwm.mode is software, no PWM chip is used"},
+		}},
+		{"channel exported", testRoot, hardware("pwmchip0", "1"), []Check{
+			chipPass,
+			{Group: "pwm", Name: "channel", Status: StatusPass, Message: "pwm1 is exported and enabled, period 40000 ns"},
+		}},
+		{"channel not exported", testRoot, hardware("pwmchip0", "0"), []Check{
+			chipPass,
+			{Group: "pwm", Name: "channel", Status: StatusPass, Message: "pwm0 is not exported yet, the daemon exports it when it starts"},
+		}},
+		{"channel out of range", testRoot, hardware("pwmchip0", "2"), []Check{
+			{Group: "pwm", Name: "chip", Status: StatusFail, Message: "pwm.hardware.channel 2 doesn't exist, pwmchip0 has 2 channel(s)",
+				Hint: "Set pwm.hardware.channel to the fan's channel, 0 to 1"},
+		}},
+		{"invalid channel count", testRoot, hardware("pwmchip2", "0"), []Check{
+			{Group: "pwm", Name: "chip", Status: StatusFail, Message: "testdata/cm5/sys/class/pwm/pwmchip2/npwm: invalid channel count 'four'"},
+		}},
+		{"chip missing", testRoot, hardware("pwmchip9", "0"), []Check{
+			{Group: "pwm", Name: "chip", Status: StatusFail, Message: "testdata/cm5/sys/class/pwm/pwmchip9 not found",
+				Hint: "Set pwm.hardware.chip to one of: pwmchip0, pwmchip2"},
+		}},
+		{"no PWM chips", empty, hardware("pwmchip0", "0"), []Check{
+			{Group: "pwm", Name: "chip", Status: StatusFail, Message: empty + "/sys/class/pwm/pwmchip0 not found",
+				Hint: "No PWM chips found; enable a PWM overlay in config.txt and reboot"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runCheck(tt.root, testConfig(t, tt.config), (*Doctor).checkPWM)
+			compareChecks(t, got, tt.want)
+		})
+	}
+}
+
+func TestCheckBootConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		root   string
+		config string
+		want   []Check
+	}{
+		{"software PWM on a free pin", testRoot, "gpio:\n  pin: 13\n", []Check{
+			{Group: "boot", Name: "overlay", Status: StatusPass, Message: "no PWM overlay in /boot/firmware/config.txt uses GPIO 13"},
+		}},
+		{"software PWM on an overlay pin", testRoot, "gpio:\n  pin: 19\n", []Check{
+			{Group: "boot", Name: "overlay", Status: StatusFail,
+				Message: "GPIO 19 is routed to PWM by dtoverlay=pwm-2chan (/boot/firmware/config.txt:9)",
+				Hint:    "Set pwm.mode to hardware, or remove the overlay and reboot"},
+		}},
+		{"hardware PWM", testRoot, "pwm:\n  mode: hardware\n", []Check{
+			{Group: "boot", Name: "overlay", Status: StatusPass,
+				Message: "/boot/firmware/config.txt: dtoverlay=pwm-2chan (line 9) routes GPIO 18, 19 to PWM"},
+		}},
+		{"no config.txt", t.TempDir(), "", []Check{
+			{Group: "boot", Name: "overlay", Status: StatusSkip, Message: "config.txt not found, not a Raspberry Pi OS system"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runCheck(tt.root, testConfig(t, tt.config), (*Doctor).checkBootConfig)
+			compareChecks(t, got, tt.want)
+		})
+	}
+}
+
+func TestReadOverlays(t *testing.T) {
+	type params = map[string]string
+	tests := []struct {
+		name    string
+		content string
+		want    []overlay
+		pins    [][]int // pwmPins of each overlay
+	}{
+		{
+			name:    "defaults",
+			content: "dtoverlay=pwm\ndtoverlay=pwm-2chan\n",
+			want:    []overlay{{1, "pwm", params{}}, {2, "pwm-2chan", params{}}},
+			pins:    [][]int{{18}, {18, 19}},
+		},
+		{
+			name:    "inline parameters and comments",
+			content: "# Fan\ndtoverlay=pwm,pin=12,func=4 # on GPIO 12\n",
+			want:    []overlay{{2, "pwm", params{"pin": "12", "func": "4"}}},
+			pins:    [][]int{{12}},
+		},
+		{
+			name:    "dtparam continuation",
+			content: "dtoverlay=pwm-2chan,pin=12\ndtparam=pin2=13,func2=4\ndtparam=invert\n",
+			want:    []overlay{{1, "pwm-2chan", params{"pin": "12", "pin2": "13", "func2": "4", "invert": "on"}}},
+			pins:    [][]int{{12, 13}},
+		},
+		{
+			name:    "dtoverlay= ends the parameters",
+			content: "dtoverlay=pwm-2chan\ndtoverlay=\ndtparam=pin=12\n",
+			want:    []overlay{{1, "pwm-2chan", params{}}},
+			pins:    [][]int{{18, 19}},
+		},
+		{
+			name:    "dtparam before any overlay",
+			content: "dtparam=audio=on\ndtparam=gpio=5\ndtoverlay=pwm-gpio,gpio=13\n",
+			want:    []overlay{{3, "pwm-gpio", params{"gpio": "13"}}},
+			pins:    [][]int{{13}},
+		},
+		{
+			name:    "other overlays",
+			content: "[cm5]\ndtoverlay=vc4-kms-v3d\ndtoverlay=dwc2,dr_mode=host\n",
+			want:    []overlay{{2, "vc4-kms-v3d", params{}}, {3, "dwc2", params{"dr_mode": "host"}}},
+			pins:    [][]int{nil, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readOverlays(writeFile(t, "config.txt", tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("readOverlays =\n%+v\nwant\n%+v", got, tt.want)
+			}
+			for i, o := range got {
+				if pins := o.pwmPins(); !slices.Equal(pins, tt.pins[i]) {
+					t.Errorf("%s pwmPins = %v, want %v", o.Name, pins, tt.pins[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckTemperature(t *testing.T) {
+	hint := "Set temperature.source.file.path to a sensor file, found: " +
+		"/sys/class/thermal/thermal_zone0/temp (cpu-thermal), /sys/class/thermal/thermal_zone1/temp (rp1_adc)"
+	sensor := func(path string) string {
+		return "temperature:\n  source:\n    file:\n      path: " + path + "\n"
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		want   []Check
+	}{
+		{"default zone", "", []Check{
+			{Group: "temperature", Name: "file", Status: StatusPass, Message: "/sys/class/thermal/thermal_zone0/temp reads 52.3°C (cpu-thermal)"},
+		}},
+		{"out of range", sensor("/sys/class/thermal/thermal_zone1/temp"), []Check{
+			{Group: "temperature", Name: "file", Status: StatusWarn, Message: "/sys/class/thermal/thermal_zone1/temp reads 250.0°C",
+				Hint: "The file must contain millidegrees Celsius, e.g. 52300 for 52.3°C"},
+		}},
+		{"not a temperature", sensor("/sys/class/thermal/thermal_zone0/type"), []Check{
+			{Group: "temperature", Name: "file", Status: StatusFail,
+				Message: "/sys/class/thermal/thermal_zone0/type doesn't contain a temperature: 'cpu-thermal'", Hint: hint},
+		}},
+		{"missing", sensor("/sys/class/thermal/thermal_zone9/temp"), []Check{
+			{Group: "temperature", Name: "file", Status: StatusFail,
+				Message: "open testdata/cm5/sys/class/thermal/thermal_zone9/temp: no such file or directory", Hint: hint},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runCheck(testRoot, testConfig(t, tt.config), (*Doctor).checkTemperature)
+			compareChecks(t, got, tt.want)
+		})
+	}
+
+	t.Run("file source unused", func(t *testing.T) {
+		cfg := testConfig(t, "")
+		cfg.Temperature.Source.Primary, cfg.Temperature.Source.Fallback = "http", ""
+		got := runCheck(testRoot, cfg, (*Doctor).checkTemperature)
+		compareChecks(t, got, []Check{
+			{Group: "temperature", Name: "file", Status: StatusSkip, Message: "the file source is not used"},
+		})
+	})
+}
+
+func TestCheckHwmon(t *testing.T) {
+	sensors := "found cpu_thermal 51.0°C, pwmfan 2400 RPM"
+
+	tests := []struct {
+		name   string
+		root   string
+		config string
+		want   []Check
+	}{
+		{"software PWM", testRoot, "", []Check{
+			{Group: "temperature", Name: "hwmon", Status: StatusPass, Message: sensors},
+		}},
+		{"hardware PWM with pwm-fan", testRoot, "pwm:\n  mode: hardware\n", []Check{
+			{Group: "temperature", Name: "hwmon", Status: StatusWarn,
+				Message: "the kernel pwm-fan driver (hwmon1) also controls a fan; " + sensors,
+				Hint:    "If it drives the same fan, disable it in config.txt so it doesn't fight nanoctl over the duty cycle"},
+		}},
+		{"no sensors", t.TempDir(), "", []Check{
+			{Group: "temperature", Name: "hwmon", Status: StatusSkip, Message: "no hwmon sensors found"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runCheck(tt.root, testConfig(t, tt.config), (*Doctor).checkHwmon)
+			compareChecks(t, got, tt.want)
+		})
+	}
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		got := runCheck(testRoot, nil, (*Doctor).checkHwmon)
+		compareChecks(t, got, []Check{{Group: "temperature", Name: "hwmon", Status: StatusPass, Message: sensors}})
+	})
+}
@@ -0,0 +1,276 @@
+package doctor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/config"
+	"github.com/AlejandroPerez92/nanoctl/pkg/temperature"
+)
+
+// serviceName is the systemd unit of the fan daemon.
+const serviceName = "nanoctl-fan"
+
+// unitFiles are the locations of the unit file; the first one found is used.
+var unitFiles = []string{
+	"/etc/systemd/system/" + serviceName + ".service",
+	"/usr/lib/systemd/system/" + serviceName + ".service",
+	"/lib/systemd/system/" + serviceName + ".service",
+}
+
+// checkConfig checks fan.yaml and keeps the configuration for the other
+// checks, even if it has errors.
+func (d *Doctor) checkConfig() {
+	cfg, issues := config.Check(d.ConfigPath)
+	errs, warnings := 0, 0
+	for _, issue := range issues {
+		if issue.Severity == config.SeverityError {
+			errs++
+		} else {
+			warnings++
+		}
+	}
+
+	hint := "Run nanoctl config validate for details"
+	switch {
+	case cfg == nil:
+		message := d.ConfigPath + " can't be read"
+		if len(issues) > 0 {
+			message = issues[0].String()
+		}
+		if _, err := os.Stat(d.ConfigPath); errors.Is(err, fs.ErrNotExist) {
+			hint = "Create it with: sudo nanoctl install-service"
+		}
+		d.add("config", "file", StatusFail, message, hint)
+		return
+	case errs > 0:
+		d.add("config", "file", StatusFail, fmt.Sprintf("%s has %d error(s), the daemon won't start", d.ConfigPath, errs), hint)
+	case warnings > 0:
+		d.add("config", "file", StatusWarn, fmt.Sprintf("%s is valid with %d warning(s)", d.ConfigPath, warnings), hint)
+	default:
+		d.add("config", "file", StatusPass, d.ConfigPath+" is valid", "")
+	}
+	d.config = cfg
+}
+
+// checkService checks the unit file and, on this system, the state of the
+// daemon.
+func (d *Doctor) checkService(ctx context.Context) {
+	var unit string
+	for _, name := range unitFiles {
+		if _, err := os.Stat(d.path(name)); err == nil {
+			unit = name
+			break
+		}
+	}
+	if unit == "" {
+		d.add("service", "unit", StatusWarn, serviceName+".service is not installed", "Install it with: sudo nanoctl install-service")
+		return
+	}
+
+	binary, err := execStart(d.path(unit))
+	if err != nil {
+		d.add("service", "unit", StatusFail, err.Error(), "")
+		return
+	}
+	if _, err := os.Stat(d.path(binary)); err != nil {
+		d.add("service", "unit", StatusFail, fmt.Sprintf("%s runs %s, which doesn't exist", unit, binary),
+			"Reinstall the service with: sudo nanoctl install-service")
+		return
+	}
+	d.add("service", "unit", StatusPass, fmt.Sprintf("%s runs %s", unit, binary), "")
+
+	if !d.live() {
+		return
+	}
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		d.add("service", "state", StatusSkip, "systemctl not found", "")
+		return
+	}
+	active := systemctl(ctx, "is-active", serviceName)
+	enabled := systemctl(ctx, "is-enabled", serviceName)
+	switch active {
+	case "active":
+		d.servicePID, _ = strconv.Atoi(systemctl(ctx, "show", "--property", "MainPID", "--value", serviceName))
+		if enabled != "enabled" {
+			d.add("service", "state", StatusWarn, fmt.Sprintf("%s is running but %s, it won't start at boot", serviceName, enabled),
+				"Enable it with: sudo systemctl enable "+serviceName)
+			return
+		}
+		d.add("service", "state", StatusPass, fmt.Sprintf("%s is running (PID %d) and enabled", serviceName, d.servicePID), "")
+	case "failed":
+		d.add("service", "state", StatusFail, serviceName+" has failed", "See why with: journalctl -u "+serviceName+" -n 50")
+	default:
+		d.add("service", "state", StatusWarn, fmt.Sprintf("%s is %s", serviceName, active), "Start it with: sudo systemctl enable --now "+serviceName)
+	}
+}
+
+// execStart returns the program run by a unit file.
+func execStart(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "ExecStart=")
+		if !ok {
+			continue
+		}
+		// Prefixes such as '-' change how systemd runs the program
+		fields := strings.Fields(strings.TrimLeft(value, "-@:+!"))
+		if len(fields) > 0 {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s has no ExecStart", path)
+}
+
+// systemctl runs systemctl and returns its output. Errors are ignored, as
+// systemctl is-active exits with a non-zero status for inactive units.
+func systemctl(ctx context.Context, args ...string) string {
+	out, _ := exec.CommandContext(ctx, "systemctl", args...).Output()
+	return strings.TrimSpace(string(out))
+}
+
+// checkEndpoints checks the Prometheus temperature source and the metrics
+// endpoints. They are only checked on this system, as they connect to
+// remote hosts and bind local ports.
+func (d *Doctor) checkEndpoints(ctx context.Context) {
+	if !d.live() {
+		d.add("network", "endpoints", StatusSkip, "network checks only run against this system", "")
+		return
+	}
+
+	cfg := d.config
+	checked := false
+	if prom := cfg.Temperature.Source.Prometheus; prom != nil && cfg.Temperature.Source.Primary == "prometheus" {
+		d.checkPrometheusSource(prom)
+		checked = true
+	}
+	if cfg.Metrics.Enabled {
+		d.checkOTLP(ctx, cfg.Metrics.Endpoint)
+		checked = true
+	}
+	if cfg.Metrics.Prometheus.Enabled {
+		d.checkMetricsListener(ctx, cfg.Metrics.Prometheus.Listen, cfg.Metrics.Prometheus.Path)
+		checked = true
+	}
+	if !checked {
+		d.add("network", "endpoints", StatusSkip, "no Prometheus source or metrics endpoints are configured", "")
+	}
+}
+
+// checkPrometheusSource runs the temperature query.
+func (d *Doctor) checkPrometheusSource(prom *config.PrometheusConfig) {
+	promConfig := temperature.PrometheusConfig{
+		Host:    prom.Host,
+		Query:   prom.Query,
+		Timeout: prom.Timeout,
+		MaxAge:  prom.MaxAge,
+	}
+	if prom.Auth != nil {
+		promConfig.Auth = temperature.AuthConfig{
+			Username: prom.Auth.Username,
+			Password: prom.Auth.Password,
+			Token:    prom.Auth.Token,
+		}
+	}
+
+	hint := "Run nanoctl check-prometheus for details; meanwhile the fan uses temperature.source.fallback"
+	source, err := temperature.NewPrometheusSource(promConfig)
+	if err != nil {
+		d.add("network", "prometheus", StatusFail, err.Error(), hint)
+		return
+	}
+	defer source.Close()
+
+	reading, err := source.Query()
+	if err != nil {
+		d.add("network", "prometheus", StatusFail, fmt.Sprintf("%s: %v", prom.Host, err), hint)
+		return
+	}
+	d.add("network", "prometheus", StatusPass, fmt.Sprintf("%s returned %.1f°C", prom.Host, reading.Value), "")
+}
+
+// checkOTLP checks that the OTLP endpoint accepts connections. Credentials
+// and TLS are not checked.
+func (d *Doctor) checkOTLP(ctx context.Context, endpoint string) {
+	address := endpoint
+	if u, err := url.Parse(endpoint); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		address = u.Host
+		if u.Port() == "" {
+			address = net.JoinHostPort(u.Hostname(), map[string]string{"http": "80", "https": "443"}[u.Scheme])
+		}
+	}
+
+	dialer := net.Dialer{Timeout: d.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		d.add("network", "otlp", StatusFail, fmt.Sprintf("can't connect to %s: %v", endpoint, err),
+			"Check metrics.endpoint and that the collector is running and reachable from this host")
+		return
+	}
+	conn.Close()
+	d.add("network", "otlp", StatusPass, endpoint+" accepts connections", "")
+}
+
+// checkMetricsListener checks that the running daemon serves the metrics
+// endpoint, or that its port is free if it isn't running.
+func (d *Doctor) checkMetricsListener(ctx context.Context, listen, path string) {
+	if d.servicePID == 0 {
+		ln, err := net.Listen("tcp", listen)
+		if err != nil {
+			d.add("network", "metrics", StatusFail, fmt.Sprintf("can't listen on %s: %v", listen, err),
+				"Another program uses the port; set metrics.prometheus.listen to another address")
+			return
+		}
+		ln.Close()
+		d.add("network", "metrics", StatusPass, listen+" is free for the metrics endpoint", "")
+		return
+	}
+
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		d.add("network", "metrics", StatusFail, fmt.Sprintf("invalid metrics.prometheus.listen '%s': %v", listen, err), "")
+		return
+	}
+	if host == "" || net.ParseIP(host).IsUnspecified() {
+		host = "localhost"
+	}
+	endpoint := "http://" + net.JoinHostPort(host, port) + path
+
+	hint := "See the daemon logs with: journalctl -u " + serviceName
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		d.add("network", "metrics", StatusFail, err.Error(), "")
+		return
+	}
+	client := http.Client{Timeout: d.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		d.add("network", "metrics", StatusFail, err.Error(), hint)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		d.add("network", "metrics", StatusFail, fmt.Sprintf("%s returned %s", endpoint, resp.Status), hint)
+		return
+	}
+	d.add("network", "metrics", StatusPass, "the daemon serves metrics on "+endpoint, "")
+}
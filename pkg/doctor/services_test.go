@@ -0,0 +1,103 @@
+package doctor
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecStart(t *testing.T) {
+	tests := []struct {
+		name, unit string
+		want       string // Empty if an error is expected
+	}{
+		{"plain", "[Service]\nExecStart=/usr/bin/nanoctl fan\n", "/usr/bin/nanoctl"},
+		{"ignore failure", "ExecStart=-/usr/bin/nanoctl fan", "/usr/bin/nanoctl"},
+		{"argv0", "ExecStart=@/usr/bin/nanoctl nanoctl-fan fan", "/usr/bin/nanoctl"},
+		{"several prefixes", "ExecStart=+!/usr/local/bin/nanoctl fan", "/usr/local/bin/nanoctl"},
+		{"no env expansion", "ExecStart=:/opt/nanoctl fan", "/opt/nanoctl"},
+		{"indented", "  ExecStart=/usr/bin/nanoctl fan  ", "/usr/bin/nanoctl"},
+		{"empty ExecStart skipped", "ExecStart=\nExecStart=/usr/bin/nanoctl fan", "/usr/bin/nanoctl"},
+		{"missing", "[Service]\nType=simple\n", ""},
+		{"commented out", "#ExecStart=/usr/bin/nanoctl fan", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := execStart(writeFile(t, "nanoctl-fan.service", tt.unit))
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("execStart = %s, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("execStart = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckService(t *testing.T) {
+	// A unit running a program that isn't installed
+	missing := t.TempDir()
+	unitDir := filepath.Join(missing, "lib/systemd/system")
+	if err := os.MkdirAll(unitDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(unitDir, "nanoctl-fan.service"), []byte("ExecStart=/usr/local/bin/nanoctl fan\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		root string
+		want []Check
+	}{
+		{"installed", testRoot, []Check{
+			{Group: "service", Name: "unit", Status: StatusPass, Message: "/etc/systemd/system/nanoctl-fan.service runs /usr/bin/nanoctl"},
+		}},
+		{"program missing", missing, []Check{
+			{Group: "service", Name: "unit", Status: StatusFail,
+				Message: "/lib/systemd/system/nanoctl-fan.service runs /usr/local/bin/nanoctl, which doesn't exist",
+				Hint:    "Reinstall the service with: sudo nanoctl install-service"},
+		}},
+		{"not installed", t.TempDir(), []Check{
+			{Group: "service", Name: "unit", Status: StatusWarn, Message: "nanoctl-fan.service is not installed",
+				Hint: "Install it with: sudo nanoctl install-service"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runCheck(tt.root, nil, func(d *Doctor) { d.checkService(t.Context()) })
+			compareChecks(t, got, tt.want)
+		})
+	}
+}
+
+func TestCheckEndpointsSkipsNetwork(t *testing.T) {
+	// A port in use would fail the metrics listener check if it was run
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	cfg := testConfig(t, `
+temperature:
+  source:
+    primary: prometheus
+    prometheus:
+      host: "http://127.0.0.1:1"
+metrics:
+  enabled: true
+  endpoint: "127.0.0.1:1"
+  prometheus:
+    enabled: true
+    listen: "`+ln.Addr().String()+`"
+`)
+	got := runCheck(testRoot, cfg, func(d *Doctor) { d.checkEndpoints(t.Context()) })
+	compareChecks(t, got, []Check{
+		{Group: "network", Name: "endpoints", Status: StatusSkip, Message: "network checks only run against this system"},
+	})
+}
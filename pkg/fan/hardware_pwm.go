@@ -74,7 +74,8 @@ func (pwm *HardwarePWMController) Stop() {
 }
 
 // SetDutyCycle updates the duty cycle (0-100).
-func (pwm *HardwarePWMController) SetDutyCycle(dc float64) {
+// It returns an error if the new duty cycle could not be written to sysfs.
+func (pwm *HardwarePWMController) SetDutyCycle(dc float64) error {
 	pwm.mu.Lock()
 	defer pwm.mu.Unlock()
 	if dc < 0 {
@@ -85,8 +86,9 @@ func (pwm *HardwarePWMController) SetDutyCycle(dc float64) {
 	}
 	pwm.dutyCycle = dc
 	if pwm.running {
-		_ = pwm.writeDutyCycleLocked()
+		return pwm.writeDutyCycleLocked()
 	}
+	return nil
 }
 
 func (pwm *HardwarePWMController) exportChannel() error {
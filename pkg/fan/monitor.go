@@ -3,15 +3,19 @@ package fan
 import (
 	"context"
 	"fmt"
+	"github.com/AlejandroPerez92/nanoctl/pkg/logging"
+	"github.com/AlejandroPerez92/nanoctl/pkg/metrics"
 	"github.com/AlejandroPerez92/nanoctl/pkg/temperature"
-	"os"
+	"log/slog"
+	"math"
+	"sync"
 	"time"
-
-	"github.com/felixge/pidctrl"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/metric"
 )
 
+// emergencyAfter is the number of checks in a row without a usable
+// temperature after which the fan runs at full speed.
+const emergencyAfter = 3
+
 // MonitorConfig holds configuration for the fan monitor
 type MonitorConfig struct {
 	ChipName      string
@@ -21,6 +25,10 @@ type MonitorConfig struct {
 	Kp, Ki, Kd    float64
 	CheckInterval time.Duration
 	TempSource    temperature.Source
+	SourceName    string              // Name of TempSource, used when it is not a FailoverSource
+	Filter        *temperature.Filter // Optional: smoothing between the source and the PID
+	Metrics       *metrics.Instruments
+	Logger        *slog.Logger // Optional: defaults to slog.Default()
 }
 
 func periodNsFromFrequency(frequencyKHz float64) (int64, error) {
@@ -34,8 +42,118 @@ func frequencyHz(frequencyKHz float64) float64 {
 	return frequencyKHz * 1000.0
 }
 
+// State is a snapshot of the monitor's most recent control loop iteration.
+type State struct {
+	Temperature    float64 // Filtered temperature fed to the PID
+	RawTemperature float64 // Temperature as read from the source
+	DutyCycle      float64
+	Override       bool
+	ActiveSource   string
+	PID            PIDTerms
+	UpdatedAt      time.Time
+}
+
+// Monitor runs the fan control loop and allows the fan to be overridden
+// while it is running.
+type Monitor struct {
+	config MonitorConfig
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	override *float64
+	state    State
+	retired  []temperature.Source // Replaced sources, closed by Run once unused
+	reload   chan struct{}
+
+	// Only used by the Run goroutine
+	failedChecks int  // Checks in a row without a usable temperature
+	emergency    bool // The fan runs at full speed because of failedChecks
+}
+
+// NewMonitor creates a new fan monitor.
+func NewMonitor(config MonitorConfig) *Monitor {
+	return &Monitor{
+		config: config,
+		logger: logging.OrDefault(config.Logger),
+		reload: make(chan struct{}, 1),
+	}
+}
+
+// Reload applies the settings that can change while the monitor runs: the
+// target temperature, PID gains, check interval, filter and temperature
+// source. Other fields of config are ignored. The PID state is kept, so the
+// fan duty doesn't jump, and a replaced source is closed once unused.
+func (m *Monitor) Reload(config MonitorConfig) {
+	m.mu.Lock()
+	m.config.TargetTemp = config.TargetTemp
+	m.config.Kp, m.config.Ki, m.config.Kd = config.Kp, config.Ki, config.Kd
+	m.config.CheckInterval = config.CheckInterval
+	m.config.Filter = config.Filter
+	if config.TempSource != nil && config.TempSource != m.config.TempSource {
+		m.retired = append(m.retired, m.config.TempSource)
+		m.config.TempSource = config.TempSource
+		m.config.SourceName = config.SourceName
+	}
+	m.mu.Unlock()
+
+	select {
+	case m.reload <- struct{}{}:
+	default:
+	}
+}
+
+// currentConfig returns a copy of the configuration in use.
+func (m *Monitor) currentConfig() MonitorConfig {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config
+}
+
+// closeRetired closes the temperature sources replaced by Reload.
+func (m *Monitor) closeRetired() {
+	m.mu.Lock()
+	retired := m.retired
+	m.retired = nil
+	m.mu.Unlock()
+
+	for _, source := range retired {
+		if err := source.Close(); err != nil {
+			m.logger.Warn("Failed to close previous temperature source", "error", err)
+		}
+	}
+}
+
+// SetOverride forces the fan to the given duty cycle (0-100), bypassing the PID controller.
+func (m *Monitor) SetOverride(dc float64) {
+	dc = math.Max(0, math.Min(100, dc))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.override = &dc
+}
+
+// ClearOverride returns the fan to PID control.
+func (m *Monitor) ClearOverride() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.override = nil
+}
+
+// State returns the latest monitor state.
+func (m *Monitor) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
 // RunMonitor starts the fan control monitor
 func RunMonitor(ctx context.Context, config MonitorConfig) error {
+	return NewMonitor(config).Run(ctx)
+}
+
+// Run starts the fan control loop and blocks until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context) error {
+	config := m.currentConfig()
+
 	// Initialize PWM Controller
 	controller, err := newPWMController(config)
 	if err != nil {
@@ -47,61 +165,154 @@ func RunMonitor(ctx context.Context, config MonitorConfig) error {
 	defer controller.Stop()
 
 	// Initialize PID Controller
-	pid := pidctrl.NewPIDController(config.Kp, config.Ki, config.Kd)
-	pid.SetOutputLimits(0.0, 100.0)
-	pid.Set(config.TargetTemp)
-
-	// Initialize Metrics
-	meter := otel.Meter("nanoctl")
-	tempGauge, err := meter.Float64Gauge("nanoctl.temperature.celsius",
-		metric.WithDescription("Current CPU temperature"),
-		metric.WithUnit("Ce"),
-	)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create temperature gauge: %v\n", err)
-	}
+	pid := newPIDController(config.Kp, config.Ki, config.Kd, config.TargetTemp, 0.0, 100.0)
 
-	fanGauge, err := meter.Float64Gauge("nanoctl.fan.duty_cycle.percent",
-		metric.WithDescription("Current Fan PWM duty cycle"),
-		metric.WithUnit("%"),
-	)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create fan gauge: %v\n", err)
-	}
-
-	fmt.Printf("Starting Fan Monitor...\n")
-	fmt.Printf("Target Temp: %.1f°C\n", config.TargetTemp)
-	printPWMConfig(config)
+	m.logger.Info("Starting fan monitor", "target_celsius", config.TargetTemp, "check_interval", config.CheckInterval)
+	logPWMConfig(m.logger, config)
 
 	ticker := time.NewTicker(config.CheckInterval)
 	defer ticker.Stop()
+	defer m.closeRetired()
 
 	for {
 		select {
 		case <-ctx.Done():
-			fmt.Println("Monitor stopping...")
+			m.logger.Info("Fan monitor stopping")
 			return nil
 		case <-ticker.C:
-			// Use the configured temperature source
-			temp, err := config.TempSource.GetTemperature()
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error reading temp: %v\n", err)
-				continue
-			}
-
-			pid.SetPID(-config.Kp, -config.Ki, -config.Kd)
-
-			output := pid.Update(temp)
-
-			controller.SetDutyCycle(output)
-
-			// Record metrics
-			if tempGauge != nil {
-				tempGauge.Record(ctx, temp)
-			}
-			if fanGauge != nil {
-				fanGauge.Record(ctx, output)
-			}
+			m.tick(ctx, controller, pid)
+		case <-m.reload:
+			m.applyReload(pid, ticker)
 		}
 	}
 }
+
+// applyReload hands the settings changed by Reload to the PID controller
+// and the ticker, and closes the replaced temperature sources.
+func (m *Monitor) applyReload(pid *pidController, ticker *time.Ticker) {
+	config := m.currentConfig()
+	pid.SetGains(config.Kp, config.Ki, config.Kd)
+	pid.SetSetpoint(config.TargetTemp)
+	ticker.Reset(config.CheckInterval)
+	m.closeRetired()
+}
+
+// tick runs a single control loop iteration.
+func (m *Monitor) tick(ctx context.Context, controller pwmController, pid *pidController) {
+	config := m.currentConfig()
+	inst := config.Metrics
+	start := time.Now()
+	defer func() { inst.RecordLoopDuration(ctx, time.Since(start)) }()
+
+	// Use the configured temperature source
+	raw, err := config.TempSource.GetTemperature()
+	source := m.activeSource()
+	inst.SetActiveSource(ctx, source)
+	if err != nil {
+		// A FailoverSource reports its own read errors
+		if _, ok := config.TempSource.(*temperature.FailoverSource); !ok {
+			inst.RecordReadError(ctx, source)
+			m.logger.ErrorContext(ctx, "Error reading temperature", metrics.Event(metrics.EventReadError),
+				"source", source, "error", err)
+		}
+		m.noReading(ctx, controller, config)
+		return
+	}
+
+	temp := raw
+	if config.Filter != nil {
+		temp, err = config.Filter.Apply(raw, start)
+		if err != nil {
+			// Not a failed check: the filter accepts a new level after a
+			// few rejections, and a real step change mustn't cause an
+			// emergency. The fan keeps its duty cycle meanwhile.
+			inst.RecordRawTemperature(ctx, raw)
+			m.logger.WarnContext(ctx, "Temperature reading dropped by filter", metrics.Event(metrics.EventReadingDropped),
+				"source", source, "error", err)
+			return
+		}
+	}
+	inst.RecordTemperature(ctx, raw, temp)
+
+	if m.emergency {
+		m.logger.InfoContext(ctx, "Temperature readings resumed, returning the fan to PID control",
+			metrics.Event(metrics.EventEmergency), "action", "cleared", "source", source)
+	}
+	m.failedChecks, m.emergency = 0, false
+
+	terms := pid.Update(temp, start)
+	inst.RecordPID(ctx, metrics.PIDSample{
+		Setpoint: terms.Setpoint,
+		Error:    terms.Error,
+		P:        terms.P,
+		I:        terms.I,
+		D:        terms.D,
+		Raw:      terms.Raw,
+	})
+	output := terms.Output
+
+	// A manual override replaces the PID output but the PID keeps
+	// tracking the temperature so it resumes smoothly.
+	m.mu.Lock()
+	override := m.override
+	m.mu.Unlock()
+	if override != nil {
+		output = *override
+	}
+	inst.RecordOverride(ctx, override != nil)
+
+	if err := controller.SetDutyCycle(output); err != nil {
+		inst.RecordPWMWriteError(ctx, config.PWM.Mode)
+		m.logger.ErrorContext(ctx, "Error setting duty cycle", metrics.Event(metrics.EventPWMWriteError),
+			"mode", config.PWM.Mode, "error", err)
+	}
+	inst.RecordDutyCycle(ctx, output)
+
+	m.mu.Lock()
+	m.state = State{
+		Temperature:    temp,
+		RawTemperature: raw,
+		DutyCycle:      output,
+		Override:       override != nil,
+		ActiveSource:   source,
+		PID:            terms,
+		UpdatedAt:      time.Now(),
+	}
+	m.mu.Unlock()
+}
+
+// noReading counts a check without a usable temperature. After
+// emergencyAfter of them in a row, it runs the fan at full speed until a
+// reading is used again, so a failed sensor can't leave the fan slow. This
+// also overrides a manual override.
+func (m *Monitor) noReading(ctx context.Context, controller pwmController, config MonitorConfig) {
+	m.failedChecks++
+	if m.failedChecks < emergencyAfter {
+		return
+	}
+
+	if err := controller.SetDutyCycle(100); err != nil {
+		config.Metrics.RecordPWMWriteError(ctx, config.PWM.Mode)
+		m.logger.ErrorContext(ctx, "Error setting duty cycle", metrics.Event(metrics.EventPWMWriteError),
+			"mode", config.PWM.Mode, "error", err)
+	}
+	config.Metrics.RecordDutyCycle(ctx, 100)
+	m.mu.Lock()
+	m.state.DutyCycle = 100
+	m.mu.Unlock()
+
+	if !m.emergency {
+		m.emergency = true
+		m.logger.ErrorContext(ctx, "No usable temperature reading, running the fan at 100%",
+			metrics.Event(metrics.EventEmergency), "action", "full_speed", "failed_checks", m.failedChecks)
+	}
+}
+
+// activeSource returns the name of the temperature source in use.
+func (m *Monitor) activeSource() string {
+	config := m.currentConfig()
+	if failover, ok := config.TempSource.(*temperature.FailoverSource); ok {
+		return failover.Active()
+	}
+	return config.SourceName
+}
@@ -0,0 +1,155 @@
+package fan
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/temperature"
+)
+
+// errNoReading marks a failed read in a fakeSource sequence.
+var errNoReading = errors.New("sensor unavailable")
+
+// fakeSource returns readings in order, failing for NaN, and repeats the
+// last one when they run out.
+type fakeSource struct {
+	readings []float64
+	closed   bool
+}
+
+func (s *fakeSource) GetTemperature() (float64, error) {
+	reading := s.readings[0]
+	if len(s.readings) > 1 {
+		s.readings = s.readings[1:]
+	}
+	if math.IsNaN(reading) {
+		return 0, errNoReading
+	}
+	return reading, nil
+}
+
+func (s *fakeSource) Close() error {
+	s.closed = true
+	return nil
+}
+
+// fakeController records the duty cycles it is set to.
+type fakeController struct {
+	duty []float64
+}
+
+func (c *fakeController) Start() error { return nil }
+func (c *fakeController) Stop()        {}
+func (c *fakeController) SetDutyCycle(dc float64) error {
+	c.duty = append(c.duty, dc)
+	return nil
+}
+
+// newTestMonitor returns a monitor reading from source through filter, if
+// not nil, and targeting 50°C.
+func newTestMonitor(source temperature.Source, filter *temperature.Filter) *Monitor {
+	return NewMonitor(MonitorConfig{
+		TargetTemp: 50,
+		Kp:         5,
+		TempSource: source,
+		SourceName: "fake",
+		Filter:     filter,
+		Logger:     slog.New(slog.DiscardHandler),
+	})
+}
+
+func TestMonitorEmergency(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name      string
+		readings  []float64
+		maxRate   float64
+		emergency []bool // After each check
+	}{
+		{"readings", []float64{52, 53, 54}, 0, []bool{false, false, false}},
+		{"read errors", []float64{nan, nan, nan, nan}, 0, []bool{false, false, true, true}},
+		{"readings resume", []float64{nan, nan, nan, 52}, 0, []bool{false, false, true, false}},
+		{"errors between readings", []float64{nan, nan, 52, nan, nan}, 0, []bool{false, false, false, false, false}},
+		{"step change", []float64{50, 70, 70, 70, 70, 70}, 1, []bool{false, false, false, false, false, false}},
+		{"rejections don't reset errors", []float64{50, nan, nan, 70, nan}, 1, []bool{false, false, false, false, true}},
+		{"errors after rejections", []float64{50, 70, 70, nan, nan, nan}, 1, []bool{false, false, false, false, false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := temperature.NewFilter(temperature.FilterConfig{MaxRate: tt.maxRate})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := newTestMonitor(&fakeSource{readings: tt.readings}, filter)
+			controller := &fakeController{}
+			pid := newPIDController(5, 0, 0, 50, 0, 100)
+
+			for i, want := range tt.emergency {
+				m.tick(t.Context(), controller, pid)
+				if m.emergency != want {
+					t.Fatalf("check %d: emergency = %t, want %t", i+1, m.emergency, want)
+				}
+				if want && controller.duty[len(controller.duty)-1] != 100 {
+					t.Fatalf("check %d: duty cycle = %g during an emergency, want 100", i+1, controller.duty[len(controller.duty)-1])
+				}
+			}
+		})
+	}
+}
+
+func TestMonitorReload(t *testing.T) {
+	tests := []struct {
+		name       string
+		reload     func(c *MonitorConfig)
+		wantDuty   float64 // At 56°C, NaN if the reading is dropped
+		wantClosed bool    // The first source was closed
+	}{
+		{"unchanged", func(*MonitorConfig) {}, 30, false},
+		{"target", func(c *MonitorConfig) { c.TargetTemp = 46 }, 50, false},
+		{"gains", func(c *MonitorConfig) { c.Kp = 10 }, 60, false},
+		{"source", func(c *MonitorConfig) {
+			c.TempSource, c.SourceName = &fakeSource{readings: []float64{60}}, "replacement"
+		}, 50, true},
+		{"filter", func(c *MonitorConfig) {
+			// 56°C is 6°C/s from the last reading the filter saw
+			c.Filter, _ = temperature.NewFilter(temperature.FilterConfig{MaxRate: 1})
+			c.Filter.Apply(50, time.Now().Add(-time.Second))
+		}, math.NaN(), false},
+		{"restart only settings", func(c *MonitorConfig) { c.Pin, c.PWM.Mode = 18, "hardware" }, 30, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeSource{readings: []float64{50, 56}}
+			m := newTestMonitor(source, nil)
+			controller := &fakeController{}
+			pid := newPIDController(5, 0, 0, 50, 0, 100)
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+			m.tick(t.Context(), controller, pid)
+
+			config := m.currentConfig()
+			config.CheckInterval = time.Second
+			tt.reload(&config)
+			m.Reload(config)
+			<-m.reload
+			m.applyReload(pid, ticker)
+
+			m.tick(t.Context(), controller, pid)
+			switch {
+			case math.IsNaN(tt.wantDuty) && len(controller.duty) != 1:
+				t.Errorf("duty cycle set to %g, want the reading dropped", controller.duty[len(controller.duty)-1])
+			case !math.IsNaN(tt.wantDuty) && controller.duty[len(controller.duty)-1] != tt.wantDuty:
+				t.Errorf("duty cycle = %g, want %g", controller.duty[len(controller.duty)-1], tt.wantDuty)
+			}
+			if source.closed != tt.wantClosed {
+				t.Errorf("first source closed = %t, want %t", source.closed, tt.wantClosed)
+			}
+			if current := m.currentConfig(); current.Pin != 0 || current.PWM.Mode != "" {
+				t.Errorf("pin and PWM mode changed to %d and %q, want them kept until a restart", current.Pin, current.PWM.Mode)
+			}
+		})
+	}
+}
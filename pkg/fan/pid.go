@@ -0,0 +1,89 @@
+package fan
+
+import "time"
+
+// PIDTerms describes a single PID controller update.
+type PIDTerms struct {
+	Setpoint float64 // Target temperature
+	Error    float64 // Temperature minus setpoint; positive when too hot
+	P, I, D  float64 // Contribution of each term to the output
+	Raw      float64 // Unclamped output (P + I + D)
+	Output   float64 // Output clamped to the limits
+}
+
+// pidController is a reverse-acting PID controller: the output (fan duty)
+// rises when the measured value is above the setpoint. The integral is
+// clamped to the output limits to avoid wind-up.
+type pidController struct {
+	kp, ki, kd     float64
+	setpoint       float64
+	outMin, outMax float64
+
+	integral   float64
+	prevValue  float64
+	lastUpdate time.Time
+}
+
+func newPIDController(kp, ki, kd, setpoint, outMin, outMax float64) *pidController {
+	return &pidController{
+		kp:       kp,
+		ki:       ki,
+		kd:       kd,
+		setpoint: setpoint,
+		outMin:   outMin,
+		outMax:   outMax,
+	}
+}
+
+// SetGains changes the P, I and D gains.
+func (c *pidController) SetGains(kp, ki, kd float64) {
+	c.kp, c.ki, c.kd = kp, ki, kd
+}
+
+// SetSetpoint changes the target value.
+func (c *pidController) SetSetpoint(setpoint float64) {
+	c.setpoint = setpoint
+}
+
+// Update feeds a new measurement taken at now and returns the resulting terms.
+// The first update has no elapsed time, so only the proportional term acts.
+func (c *pidController) Update(value float64, now time.Time) PIDTerms {
+	var dt float64
+	if !c.lastUpdate.IsZero() {
+		dt = now.Sub(c.lastUpdate).Seconds()
+	}
+	c.lastUpdate = now
+
+	err := value - c.setpoint
+
+	c.integral = clamp(c.integral+c.ki*err*dt, c.outMin, c.outMax)
+
+	var d float64
+	if dt > 0 {
+		d = c.kd * (value - c.prevValue) / dt
+	}
+	c.prevValue = value
+
+	p := c.kp * err
+	raw := p + c.integral + d
+
+	return PIDTerms{
+		Setpoint: c.setpoint,
+		Error:    err,
+		P:        p,
+		I:        c.integral,
+		D:        d,
+		Raw:      raw,
+		Output:   clamp(raw, c.outMin, c.outMax),
+	}
+}
+
+func clamp(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -18,6 +18,7 @@ type PWMController struct {
 	running bool
 	mu      sync.Mutex
 	stop    chan struct{}
+	lastErr error // last GPIO write error from the PWM loop
 }
 
 // NewPWMController creates a new software PWM controller
@@ -67,22 +68,31 @@ func (pwm *PWMController) Start() error {
 				dc := pwm.dutyCycle
 				pwm.mu.Unlock()
 
+				var err error
 				if dc <= 0.0 {
-					_ = line.SetValue(0)
+					err = line.SetValue(0)
 					time.Sleep(period)
 				} else if dc >= 100.0 {
-					_ = line.SetValue(1)
+					err = line.SetValue(1)
 					time.Sleep(period)
 				} else {
 					// On time
 					onDuration := time.Duration(float64(period) * dc / 100.0)
-					_ = line.SetValue(1)
+					err = line.SetValue(1)
 					time.Sleep(onDuration)
 
 					// Off time
-					_ = line.SetValue(0)
+					if offErr := line.SetValue(0); offErr != nil {
+						err = offErr
+					}
 					time.Sleep(period - onDuration)
 				}
+
+				if err != nil {
+					pwm.mu.Lock()
+					pwm.lastErr = err
+					pwm.mu.Unlock()
+				}
 			}
 		}
 	}()
@@ -101,7 +111,9 @@ func (pwm *PWMController) Stop() {
 }
 
 // SetDutyCycle updates the duty cycle (0-100)
-func (pwm *PWMController) SetDutyCycle(dc float64) {
+// The PWM loop writes the GPIO line asynchronously; any write error it hit
+// since the previous call is returned here.
+func (pwm *PWMController) SetDutyCycle(dc float64) error {
 	pwm.mu.Lock()
 	defer pwm.mu.Unlock()
 	if dc < 0 {
@@ -111,4 +123,11 @@ func (pwm *PWMController) SetDutyCycle(dc float64) {
 		dc = 100
 	}
 	pwm.dutyCycle = dc
+
+	err := pwm.lastErr
+	pwm.lastErr = nil
+	if err != nil {
+		return fmt.Errorf("failed to write GPIO %d: %w", pwm.pin, err)
+	}
+	return nil
 }
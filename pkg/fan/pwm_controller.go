@@ -1,6 +1,9 @@
 package fan
 
-import "fmt"
+import (
+	"fmt"
+	"log/slog"
+)
 
 // PWMConfig defines PWM control configuration.
 type PWMConfig struct {
@@ -19,7 +22,7 @@ type HardwarePWMConfig struct {
 type pwmController interface {
 	Start() error
 	Stop()
-	SetDutyCycle(dc float64)
+	SetDutyCycle(dc float64) error
 }
 
 func newPWMController(config MonitorConfig) (pwmController, error) {
@@ -42,23 +45,34 @@ func newPWMController(config MonitorConfig) (pwmController, error) {
 	}
 }
 
-func printPWMConfig(config MonitorConfig) {
+func logPWMConfig(logger *slog.Logger, config MonitorConfig) {
 	switch config.PWM.Mode {
 	case "software":
-		fmt.Printf("PWM: software %.2fkHz on %s pin %d\n", config.PWM.FrequencyKHz, config.ChipName, config.Pin)
+		logger.Info("PWM configured",
+			"mode", "software",
+			"frequency_khz", config.PWM.FrequencyKHz,
+			"chip", config.ChipName,
+			"pin", config.Pin,
+		)
 	case "hardware":
 		periodNs, err := periodNsFromFrequency(config.PWM.FrequencyKHz)
 		if err != nil {
-			fmt.Printf("PWM: hardware %s pwm%d inverted=%t (invalid frequency: %v)\n", config.PWM.Hardware.Chip, config.PWM.Hardware.Channel, config.PWM.Hardware.Inverted, err)
+			logger.Warn("PWM configured with invalid frequency",
+				"mode", "hardware",
+				"chip", config.PWM.Hardware.Chip,
+				"channel", config.PWM.Hardware.Channel,
+				"inverted", config.PWM.Hardware.Inverted,
+				"error", err,
+			)
 			return
 		}
-		fmt.Printf(
-			"PWM: hardware %s pwm%d period %dns (%.2fkHz) inverted=%t\n",
-			config.PWM.Hardware.Chip,
-			config.PWM.Hardware.Channel,
-			periodNs,
-			config.PWM.FrequencyKHz,
-			config.PWM.Hardware.Inverted,
+		logger.Info("PWM configured",
+			"mode", "hardware",
+			"chip", config.PWM.Hardware.Chip,
+			"channel", config.PWM.Hardware.Channel,
+			"period_ns", periodNs,
+			"frequency_khz", config.PWM.FrequencyKHz,
+			"inverted", config.PWM.Hardware.Inverted,
 		)
 	}
 }
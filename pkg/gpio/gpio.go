@@ -2,8 +2,10 @@ package gpio
 
 import (
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/AlejandroPerez92/nanoctl/pkg/logging"
 	"github.com/warthog618/go-gpiocdev"
 )
 
@@ -22,12 +24,15 @@ const (
 // Controller handles GPIO operations for node control
 type Controller struct {
 	chipName string
+	logger   *slog.Logger
 }
 
-// NewController creates a new GPIO controller
-func NewController() *Controller {
+// NewController creates a new GPIO controller.
+// A nil logger uses slog.Default().
+func NewController(logger *slog.Logger) *Controller {
 	return &Controller{
 		chipName: GPIOChip,
+		logger:   logging.OrDefault(logger),
 	}
 }
 
@@ -59,14 +64,14 @@ func (c *Controller) PowerOn(slot int, boardType BoardType) error {
 		return fmt.Errorf("unsupported board type: %s (only cm5 is supported)", boardType)
 	}
 
-	fmt.Printf("Powering on slot %d (CM5)...\n", slot)
+	c.logger.Info("Powering on slot", "slot", slot, "board", boardType)
 
 	// Single short press to power on
 	if err := c.pulseGPIO(slot, 1*time.Second); err != nil {
 		return fmt.Errorf("failed to power on: %w", err)
 	}
 
-	fmt.Printf("Power on signal sent to slot %d\n", slot)
+	c.logger.Debug("Power on signal sent", "slot", slot)
 	return nil
 }
 
@@ -78,14 +83,14 @@ func (c *Controller) PowerOff(slot int, boardType BoardType) error {
 		return fmt.Errorf("unsupported board type: %s (only cm5 is supported)", boardType)
 	}
 
-	fmt.Printf("Powering off slot %d (CM5)...\n", slot)
+	c.logger.Info("Powering off slot", "slot", slot, "board", boardType)
 
 	// First short press
 	if err := c.pulseGPIO(slot, 1*time.Second); err != nil {
 		return fmt.Errorf("failed to send first power off signal: %w", err)
 	}
 
-	fmt.Printf("Power off signal sent to slot %d\n", slot)
+	c.logger.Debug("Power off signal sent", "slot", slot)
 	return nil
 }
 
@@ -96,14 +101,14 @@ func (c *Controller) ForceOff(slot int, boardType BoardType) error {
 		return fmt.Errorf("unsupported board type: %s (only cm5 is supported)", boardType)
 	}
 
-	fmt.Printf("Force powering off slot %d (CM5)...\n", slot)
+	c.logger.Info("Force powering off slot, holding for 8s", "slot", slot, "board", boardType)
 
 	// Hold GPIO low for 8 seconds
 	if err := c.pulseGPIO(slot, 8*time.Second); err != nil {
 		return fmt.Errorf("failed to force off: %w", err)
 	}
 
-	fmt.Printf("Force power off signal sent to slot %d\n", slot)
+	c.logger.Debug("Force power off signal sent", "slot", slot)
 	return nil
 }
 
@@ -114,21 +119,21 @@ func (c *Controller) Reset(slot int, boardType BoardType) error {
 		return fmt.Errorf("unsupported board type: %s (only cm5 is supported)", boardType)
 	}
 
-	fmt.Printf("Resetting slot %d (CM5)...\n", slot)
+	c.logger.Info("Resetting slot", "slot", slot, "board", boardType)
 
 	// Single short press for reset
 	if err := c.pulseGPIO(slot, 1*time.Second); err != nil {
 		return fmt.Errorf("failed to reset: %w", err)
 	}
 
-	fmt.Printf("Reset signal sent to slot %d\n", slot)
+	c.logger.Debug("Reset signal sent", "slot", slot)
 	return nil
 }
 
 // ResetSwitch performs a reset on the switch chip (GPIO 0 on gpiochip2)
 // This toggles the GPIO 0 low then high to reset the switch
 func (c *Controller) ResetSwitch() error {
-	fmt.Printf("Resetting switch chip (GPIO 0)...\n")
+	c.logger.Info("Resetting switch chip", "gpio", 0)
 
 	// Use a short pulse (100ms) to reset
 	// The original script was: 0=0 && 0=1
@@ -137,6 +142,6 @@ func (c *Controller) ResetSwitch() error {
 		return fmt.Errorf("failed to reset switch chip: %w", err)
 	}
 
-	fmt.Printf("Switch chip reset signal sent\n")
+	c.logger.Debug("Switch chip reset signal sent")
 	return nil
 }
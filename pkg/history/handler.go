@@ -0,0 +1,90 @@
+package history
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/metrics"
+)
+
+// eventHandler is a slog.Handler that stores records marked as events
+// (see metrics.Event) in the history. Other records are ignored.
+type eventHandler struct {
+	store  *Store
+	event  string
+	attrs  string
+	prefix string
+}
+
+// Handler returns a slog.Handler recording daemon events into the store.
+// Combine it with the output handler using logging.Tee.
+func (s *Store) Handler() slog.Handler {
+	return &eventHandler{store: s}
+}
+
+func (h *eventHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo
+}
+
+func (h *eventHandler) Handle(_ context.Context, r slog.Record) error {
+	event := h.event
+	var b strings.Builder
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == metrics.EventKey && h.prefix == "" {
+			event = a.Value.String()
+			return true
+		}
+		writeAttr(&b, h.prefix, a)
+		return true
+	})
+	if event == "" {
+		return nil
+	}
+
+	h.store.AddEvent(Event{
+		Time:    r.Time,
+		Level:   r.Level,
+		Name:    event,
+		Message: b.String(),
+	})
+	return nil
+}
+
+func (h *eventHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	var b strings.Builder
+	for _, a := range attrs {
+		if a.Key == metrics.EventKey && h.prefix == "" {
+			clone.event = a.Value.String()
+			continue
+		}
+		writeAttr(&b, h.prefix, a)
+	}
+	clone.attrs += b.String()
+	return &clone
+}
+
+func (h *eventHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix += name + "."
+	return &clone
+}
+
+// writeAttr appends " key=value"; the component attribute is left out to
+// keep the stored message short.
+func writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	if a.Key == "component" {
+		return
+	}
+	b.WriteString(" ")
+	b.WriteString(prefix)
+	b.WriteString(a.Key)
+	b.WriteString("=")
+	b.WriteString(a.Value.Resolve().String())
+}
@@ -0,0 +1,160 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// History is the content of a history file.
+type History struct {
+	Resolution time.Duration
+	Samples    []Sample // Oldest first
+	Events     []Event  // Oldest first
+}
+
+// Read loads the history file at path. It can be used while the daemon is
+// running; data not yet flushed by the daemon is not included.
+func Read(path string) (*History, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	h, err := readHeader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	samplesBuf := make([]byte, int(h.SampleCap)*sampleSize)
+	if _, err := file.ReadAt(samplesBuf, headerSize); err != nil {
+		return nil, fmt.Errorf("failed to read history samples: %w", err)
+	}
+	eventsBuf := make([]byte, int(h.EventCap)*eventSize)
+	if _, err := file.ReadAt(eventsBuf, headerSize+int64(h.SampleCap)*sampleSize); err != nil {
+		return nil, fmt.Errorf("failed to read history events: %w", err)
+	}
+
+	history := &History{Resolution: time.Duration(h.Resolution) * time.Second}
+
+	for _, i := range ringIndexes(h.SampleHead, h.SampleCount, h.SampleCap) {
+		buf := samplesBuf[i*sampleSize : (i+1)*sampleSize]
+		unix := int64(binary.LittleEndian.Uint64(buf[0:]))
+		if unix == 0 {
+			continue
+		}
+		history.Samples = append(history.Samples, Sample{
+			Time:           time.Unix(unix, 0),
+			Temperature:    float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[8:]))),
+			MaxTemperature: float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[12:]))),
+			DutyCycle:      float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[16:]))),
+			RPM:            float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[20:]))),
+		})
+	}
+
+	for _, i := range ringIndexes(h.EventHead, h.EventCount, h.EventCap) {
+		buf := eventsBuf[i*eventSize : (i+1)*eventSize]
+		unix := int64(binary.LittleEndian.Uint64(buf[0:]))
+		if unix == 0 {
+			continue
+		}
+		history.Events = append(history.Events, Event{
+			Time:    time.Unix(unix, 0),
+			Level:   slog.Level(int8(buf[8])),
+			Name:    cString(buf[9 : 9+eventNameSize]),
+			Message: cString(buf[9+eventNameSize:]),
+		})
+	}
+
+	// The clock may have been adjusted between writes; keep output ordered
+	sort.SliceStable(history.Samples, func(a, b int) bool { return history.Samples[a].Time.Before(history.Samples[b].Time) })
+	sort.SliceStable(history.Events, func(a, b int) bool { return history.Events[a].Time.Before(history.Events[b].Time) })
+
+	return history, nil
+}
+
+// Range returns the samples and events within [from, to).
+func (h *History) Range(from, to time.Time) *History {
+	out := &History{Resolution: h.Resolution}
+	for _, s := range h.Samples {
+		if !s.Time.Before(from) && s.Time.Before(to) {
+			out.Samples = append(out.Samples, s)
+		}
+	}
+	for _, e := range h.Events {
+		if !e.Time.Before(from) && e.Time.Before(to) {
+			out.Events = append(out.Events, e)
+		}
+	}
+	return out
+}
+
+// Downsample merges samples into buckets of the given step.
+func (h *History) Downsample(step time.Duration) []Sample {
+	if step <= h.Resolution {
+		return h.Samples
+	}
+
+	var out []Sample
+	var n, rpmN int
+	var cur Sample
+	flush := func() {
+		if n == 0 {
+			return
+		}
+		cur.Temperature /= float64(n)
+		cur.DutyCycle /= float64(n)
+		if rpmN > 0 {
+			cur.RPM /= float64(rpmN)
+		} else {
+			cur.RPM = math.NaN()
+		}
+		out = append(out, cur)
+	}
+
+	for _, s := range h.Samples {
+		start := s.Time.Truncate(step)
+		if n == 0 || !start.Equal(cur.Time) {
+			flush()
+			cur = Sample{Time: start, MaxTemperature: math.Inf(-1)}
+			n, rpmN = 0, 0
+		}
+		n++
+		cur.Temperature += s.Temperature
+		cur.MaxTemperature = math.Max(cur.MaxTemperature, s.MaxTemperature)
+		cur.DutyCycle += s.DutyCycle
+		if !math.IsNaN(s.RPM) {
+			rpmN++
+			cur.RPM += s.RPM
+		}
+	}
+	flush()
+	return out
+}
+
+// ringIndexes returns the occupied slots of a ring buffer, oldest first.
+// readHeader ensures head < capacity and count <= capacity.
+func ringIndexes(head, count, capacity uint32) []int {
+	h, n, c := int(head), int(count), int(capacity)
+	indexes := make([]int, 0, n)
+	start := (h + c - n) % c
+	for i := range n {
+		indexes = append(indexes, (start+i)%c)
+	}
+	return indexes
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	// Messages are truncated on write, possibly inside a multi-byte rune
+	return strings.ToValidUTF8(string(b), "")
+}
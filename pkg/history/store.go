@@ -0,0 +1,382 @@
+// Package history keeps a compact on-disk ring buffer of temperature, fan
+// duty and daemon events so that recent history is available without an
+// external metrics backend.
+//
+// Samples are aggregated in memory to a fixed resolution and written to disk
+// in batches every flush interval, keeping SD card writes to a minimum. The
+// file has a fixed size, so the oldest data is overwritten once it is full.
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/logging"
+	"github.com/AlejandroPerez92/nanoctl/pkg/metrics"
+)
+
+// DefaultPath is the default location of the history file.
+const DefaultPath = "/var/lib/nanoctl/history.bin"
+
+// repeatInterval is how long an event identical to one just recorded is
+// left out, for events that repeat every check while a fault lasts. A
+// failing sensor would otherwise evict every other event from the ring.
+const repeatInterval = 10 * time.Minute
+
+// repeatedEvents are the events limited by repeatInterval.
+var repeatedEvents = []string{metrics.EventReadError, metrics.EventPWMWriteError}
+
+// File layout: a fixed header followed by the sample ring and the event ring.
+const (
+	headerSize = 64
+	sampleSize = 24 // time int64, temp avg, temp max, duty avg, rpm (float32)
+	eventSize  = 160
+
+	eventNameSize    = 31
+	eventMessageSize = eventSize - 8 - 1 - eventNameSize
+)
+
+var magic = [8]byte{'N', 'C', 'H', 'I', 'S', 'T', 0, 1}
+
+// Config holds the history store settings.
+type Config struct {
+	Path          string
+	Resolution    time.Duration // Aggregation interval of one sample, e.g. 10s
+	Retention     time.Duration // How far back samples are kept, e.g. 7 days
+	FlushInterval time.Duration // How often pending samples are written to disk
+	EventCapacity int           // Number of events kept; defaults to 1000
+	Logger        *slog.Logger  // Optional: defaults to slog.Default()
+}
+
+// Sample is one aggregated history entry.
+type Sample struct {
+	Time           time.Time // Start of the aggregation interval
+	Temperature    float64   // Average temperature in °C
+	MaxTemperature float64   // Highest temperature in the interval
+	DutyCycle      float64   // Average fan duty cycle (0-100)
+	RPM            float64   // Average fan speed, NaN when not measured
+}
+
+// Event is a notable daemon event, e.g. a source failover.
+type Event struct {
+	Time    time.Time
+	Level   slog.Level
+	Name    string
+	Message string
+}
+
+// header is the on-disk file header.
+type header struct {
+	Magic       [8]byte
+	Resolution  uint32 // seconds
+	SampleCap   uint32
+	EventCap    uint32
+	SampleHead  uint32 // index of the next sample slot
+	SampleCount uint32
+	EventHead   uint32
+	EventCount  uint32
+	_           [28]byte
+}
+
+// size returns the size of a file with the capacities of h.
+func (h header) size() int64 {
+	return int64(headerSize) + int64(h.SampleCap)*sampleSize + int64(h.EventCap)*eventSize
+}
+
+// Store records samples and events into the history file.
+type Store struct {
+	config Config
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	file    *os.File
+	header  header
+	bucket  bucket
+	pending []Sample
+	events  []Event
+	repeats map[string]*repeat // By event name and message
+}
+
+// repeat tracks an event left out because it repeated within repeatInterval.
+type repeat struct {
+	last    time.Time // When it was last recorded
+	skipped int
+}
+
+// bucket accumulates readings for the current aggregation interval.
+type bucket struct {
+	start          time.Time
+	n, rpmN        int
+	temp, maxTemp  float64
+	duty, rpmTotal float64
+}
+
+// Open opens or creates the history file. An existing file with a different
+// resolution or capacity is started afresh.
+func Open(config Config) (*Store, error) {
+	if config.Path == "" {
+		config.Path = DefaultPath
+	}
+	if config.Resolution < time.Second {
+		return nil, fmt.Errorf("history resolution must be at least 1s, got %s", config.Resolution)
+	}
+	if config.Retention < config.Resolution {
+		return nil, fmt.Errorf("history retention (%s) must be at least the resolution (%s)", config.Retention, config.Resolution)
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Minute
+	}
+	if config.EventCapacity <= 0 {
+		config.EventCapacity = 1000
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	file, err := os.OpenFile(config.Path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+
+	s := &Store{
+		config:  config,
+		logger:  logging.OrDefault(config.Logger),
+		file:    file,
+		repeats: make(map[string]*repeat),
+	}
+
+	want := header{
+		Magic:      magic,
+		Resolution: uint32(config.Resolution / time.Second),
+		SampleCap:  uint32(config.Retention / config.Resolution),
+		EventCap:   uint32(config.EventCapacity),
+	}
+
+	existing, err := readHeader(file)
+	if err == nil && existing.Resolution == want.Resolution && existing.SampleCap == want.SampleCap && existing.EventCap == want.EventCap {
+		s.header = existing
+		return s, nil
+	}
+	if err == nil {
+		s.logger.Warn("History settings changed, starting a new history file", "path", config.Path)
+	}
+
+	// (Re)initialise the file at its full size so later writes never grow it
+	s.header = want
+	if err := file.Truncate(0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to reset history file: %w", err)
+	}
+	if err := file.Truncate(want.size()); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to size history file: %w", err)
+	}
+	if err := s.writeHeader(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add records a reading. Readings are averaged per resolution interval.
+// Pass math.NaN() for rpm when the fan speed is not measured.
+func (s *Store) Add(at time.Time, temperature, dutyCycle, rpm float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := at.Truncate(s.config.Resolution)
+	if !s.bucket.start.Equal(start) {
+		s.closeBucket()
+		s.bucket = bucket{start: start, maxTemp: math.Inf(-1)}
+	}
+
+	b := &s.bucket
+	b.n++
+	b.temp += temperature
+	b.maxTemp = math.Max(b.maxTemp, temperature)
+	b.duty += dutyCycle
+	if !math.IsNaN(rpm) {
+		b.rpmN++
+		b.rpmTotal += rpm
+	}
+}
+
+// closeBucket moves the current bucket to the pending samples.
+func (s *Store) closeBucket() {
+	b := s.bucket
+	if b.n == 0 {
+		return
+	}
+	rpm := math.NaN()
+	if b.rpmN > 0 {
+		rpm = b.rpmTotal / float64(b.rpmN)
+	}
+	s.pending = append(s.pending, Sample{
+		Time:           b.start,
+		Temperature:    b.temp / float64(b.n),
+		MaxTemperature: b.maxTemp,
+		DutyCycle:      b.duty / float64(b.n),
+		RPM:            rpm,
+	})
+	s.bucket = bucket{}
+}
+
+// AddEvent records a daemon event. Read and PWM write errors identical to
+// one recorded less than 10 minutes before are left out; the next one
+// recorded says how many were.
+func (s *Store) AddEvent(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.Contains(repeatedEvents, event.Name) {
+		for key, r := range s.repeats {
+			if event.Time.Sub(r.last) >= repeatInterval && r.skipped == 0 {
+				delete(s.repeats, key)
+			}
+		}
+		key := event.Name + "\x00" + event.Message
+		r := s.repeats[key]
+		if r != nil && event.Time.Sub(r.last) < repeatInterval {
+			r.skipped++
+			return
+		}
+		if r != nil {
+			event.Message += fmt.Sprintf(" repeated=%d", r.skipped)
+		}
+		s.repeats[key] = &repeat{last: event.Time}
+	}
+	s.events = append(s.events, event)
+}
+
+// FlushInterval returns how often Flush should be called.
+func (s *Store) FlushInterval() time.Duration {
+	return s.config.FlushInterval
+}
+
+// Flush writes pending samples and events to disk.
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+func (s *Store) flush() error {
+	if len(s.pending) == 0 && len(s.events) == 0 {
+		return nil
+	}
+
+	for _, sample := range s.pending {
+		if err := s.writeSample(sample); err != nil {
+			return err
+		}
+	}
+	for _, event := range s.events {
+		if err := s.writeEvent(event); err != nil {
+			return err
+		}
+	}
+	s.pending = s.pending[:0]
+	s.events = s.events[:0]
+
+	if err := s.writeHeader(); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close flushes the current interval and closes the file.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeBucket()
+	err := s.flush()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *Store) writeSample(sample Sample) error {
+	var buf [sampleSize]byte
+	binary.LittleEndian.PutUint64(buf[0:], uint64(sample.Time.Unix()))
+	binary.LittleEndian.PutUint32(buf[8:], math.Float32bits(float32(sample.Temperature)))
+	binary.LittleEndian.PutUint32(buf[12:], math.Float32bits(float32(sample.MaxTemperature)))
+	binary.LittleEndian.PutUint32(buf[16:], math.Float32bits(float32(sample.DutyCycle)))
+	binary.LittleEndian.PutUint32(buf[20:], math.Float32bits(float32(sample.RPM)))
+
+	h := &s.header
+	offset := int64(headerSize) + int64(h.SampleHead)*sampleSize
+	if _, err := s.file.WriteAt(buf[:], offset); err != nil {
+		return fmt.Errorf("failed to write history sample: %w", err)
+	}
+	h.SampleHead = (h.SampleHead + 1) % h.SampleCap
+	h.SampleCount = min(h.SampleCount+1, h.SampleCap)
+	return nil
+}
+
+func (s *Store) writeEvent(event Event) error {
+	var buf [eventSize]byte
+	binary.LittleEndian.PutUint64(buf[0:], uint64(event.Time.Unix()))
+	buf[8] = byte(int8(event.Level))
+	copy(buf[9:9+eventNameSize], event.Name)
+	copy(buf[9+eventNameSize:], event.Message)
+
+	h := &s.header
+	offset := int64(headerSize) + int64(h.SampleCap)*sampleSize + int64(h.EventHead)*eventSize
+	if _, err := s.file.WriteAt(buf[:], offset); err != nil {
+		return fmt.Errorf("failed to write history event: %w", err)
+	}
+	h.EventHead = (h.EventHead + 1) % h.EventCap
+	h.EventCount = min(h.EventCount+1, h.EventCap)
+	return nil
+}
+
+func (s *Store) writeHeader() error {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, s.header); err != nil {
+		return fmt.Errorf("failed to encode history header: %w", err)
+	}
+	if _, err := s.file.WriteAt(buf.Bytes(), 0); err != nil {
+		return fmt.Errorf("failed to write history header: %w", err)
+	}
+	return nil
+}
+
+// readHeader reads and validates the header of file, so that neither the
+// reader nor the store trust capacities or ring positions the file can't
+// back.
+func readHeader(file *os.File) (header, error) {
+	var h header
+	buf := make([]byte, headerSize)
+	if _, err := file.ReadAt(buf, 0); err != nil {
+		return h, fmt.Errorf("failed to read history header: %w", err)
+	}
+	if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &h); err != nil {
+		return h, fmt.Errorf("failed to decode history header: %w", err)
+	}
+	if h.Magic != magic {
+		return h, fmt.Errorf("not a nanoctl history file")
+	}
+	if h.SampleCap == 0 || h.EventCap == 0 || h.Resolution == 0 {
+		return h, fmt.Errorf("corrupt history header")
+	}
+	if h.SampleHead >= h.SampleCap || h.SampleCount > h.SampleCap || h.EventHead >= h.EventCap || h.EventCount > h.EventCap {
+		return h, fmt.Errorf("corrupt history header: ring position out of range")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return h, fmt.Errorf("failed to stat history file: %w", err)
+	}
+	if info.Size() < h.size() {
+		return h, fmt.Errorf("corrupt history header: capacity needs %d bytes, the file has %d", h.size(), info.Size())
+	}
+	return h, nil
+}
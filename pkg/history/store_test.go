@@ -0,0 +1,124 @@
+package history
+
+import (
+	"log/slog"
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/metrics"
+)
+
+func openTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "history.bin")
+	store, err := Open(Config{
+		Path:          path,
+		Resolution:    10 * time.Second,
+		Retention:     time.Hour,
+		EventCapacity: 10,
+		Logger:        slog.New(slog.DiscardHandler),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, path
+}
+
+func TestStoreSamples(t *testing.T) {
+	store, path := openTestStore(t)
+	start := time.Unix(1_700_000_000, 0)
+
+	// Two aggregation intervals, the first with a fan speed
+	store.Add(start, 50, 40, 1200)
+	store.Add(start.Add(5*time.Second), 54, 60, 1400)
+	store.Add(start.Add(10*time.Second), 48, 30, math.NaN())
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Samples) != 2 {
+		t.Fatalf("read %d samples, want 2", len(h.Samples))
+	}
+	first, second := h.Samples[0], h.Samples[1]
+	if !first.Time.Equal(start) || first.Temperature != 52 || first.MaxTemperature != 54 || first.DutyCycle != 50 || first.RPM != 1300 {
+		t.Errorf("first sample = %+v", first)
+	}
+	if second.Temperature != 48 || !math.IsNaN(second.RPM) {
+		t.Errorf("second sample = %+v, want 48°C and no RPM", second)
+	}
+}
+
+func TestStoreRepeatedEvents(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	readError := func(after time.Duration, message string) Event {
+		return Event{Time: start.Add(after), Level: slog.LevelError, Name: metrics.EventReadError, Message: message}
+	}
+	override := func(after time.Duration) Event {
+		return Event{Time: start.Add(after), Level: slog.LevelInfo, Name: metrics.EventOverride, Message: "Fan override set"}
+	}
+
+	tests := []struct {
+		name   string
+		events []Event
+		want   []string // Messages in the file
+	}{
+		{
+			name:   "repeated read errors",
+			events: []Event{readError(0, "timeout"), readError(5*time.Second, "timeout"), readError(10*time.Second, "timeout")},
+			want:   []string{"timeout"},
+		},
+		{
+			name: "after the interval",
+			events: []Event{
+				readError(0, "timeout"), readError(time.Minute, "timeout"), readError(2*time.Minute, "timeout"),
+				readError(repeatInterval+time.Minute, "timeout"),
+			},
+			want: []string{"timeout", "timeout repeated=2"},
+		},
+		{
+			name:   "nothing left out",
+			events: []Event{readError(0, "timeout"), readError(repeatInterval, "timeout")},
+			want:   []string{"timeout", "timeout"},
+		},
+		{
+			name:   "different errors",
+			events: []Event{readError(0, "timeout"), readError(time.Second, "connection refused")},
+			want:   []string{"timeout", "connection refused"},
+		},
+		{
+			name:   "other events",
+			events: []Event{override(0), override(time.Second)},
+			want:   []string{"Fan override set", "Fan override set"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, path := openTestStore(t)
+			for _, e := range tt.events {
+				store.AddEvent(e)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			h, err := Read(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range h.Events {
+				got = append(got, e.Message)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// journaldHandler writes "<priority>message key=value ..." lines. The
+// priority prefix is understood by journald (see sd-daemon(3)), which also
+// adds its own timestamp, so none is written.
+type journaldHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	level slog.Leveler
+
+	prefix string // group prefix for attribute keys, e.g. "mqtt."
+	attrs  string // preformatted attributes from WithAttrs
+}
+
+func newJournaldHandler(w io.Writer, level slog.Leveler) *journaldHandler {
+	return &journaldHandler{w: w, mu: &sync.Mutex{}, level: level}
+}
+
+// priority maps slog levels to syslog priorities.
+func priority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3 // err
+	case level >= slog.LevelWarn:
+		return 4 // warning
+	case level >= slog.LevelInfo:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
+
+func (h *journaldHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *journaldHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString("<")
+	b.WriteString(strconv.Itoa(priority(r.Level)))
+	b.WriteString(">")
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, h.prefix, a)
+		return true
+	})
+	b.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *journaldHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		appendAttr(&b, h.prefix, a)
+	}
+	clone := *h
+	clone.attrs += b.String()
+	return &clone
+}
+
+func (h *journaldHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix += name + "."
+	return &clone
+}
+
+// appendAttr writes " key=value", quoting values that contain spaces or quotes.
+func appendAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			appendAttr(b, prefix, ga)
+		}
+		return
+	}
+
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	b.WriteString(" ")
+	b.WriteString(prefix)
+	b.WriteString(a.Key)
+	b.WriteString("=")
+	b.WriteString(value)
+}
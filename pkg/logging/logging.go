@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Supported log formats.
+const (
+	FormatAuto     = "auto"     // journald when running under systemd, text otherwise
+	FormatText     = "text"     // slog key=value lines
+	FormatJSON     = "json"     // one JSON object per line
+	FormatJournald = "journald" // <priority> prefix, no timestamp; journald adds its own
+)
+
+// Config holds the logger settings.
+type Config struct {
+	Level  string    // "debug", "info", "warn" or "error"
+	Format string    // One of the Format constants
+	Writer io.Writer // Optional: defaults to os.Stderr
+}
+
+// ParseLevel parses a log level name.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level '%s' (expected debug, info, warn or error)", s)
+	}
+	return level, nil
+}
+
+// NewHandler creates the output handler for the given configuration.
+func NewHandler(config Config) (slog.Handler, error) {
+	level, err := ParseLevel(config.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	w := config.Writer
+	if w == nil {
+		w = os.Stderr
+	}
+
+	format := strings.ToLower(config.Format)
+	if format == "" || format == FormatAuto {
+		format = FormatText
+		// systemd sets JOURNAL_STREAM when stdout/stderr are connected to the journal
+		if os.Getenv("JOURNAL_STREAM") != "" {
+			format = FormatJournald
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case FormatText:
+		return slog.NewTextHandler(w, opts), nil
+	case FormatJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	case FormatJournald:
+		return newJournaldHandler(w, level), nil
+	default:
+		return nil, fmt.Errorf("invalid log format '%s' (expected text, json, journald or auto)", config.Format)
+	}
+}
+
+// Tee returns a handler that passes every record to all given handlers.
+func Tee(handlers ...slog.Handler) slog.Handler {
+	return teeHandler(handlers)
+}
+
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
+
+// OrDefault returns logger, or slog.Default() when logger is nil.
+func OrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
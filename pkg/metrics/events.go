@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+)
+
+// EventKey is the log attribute carrying the event name. Records with it are
+// exported with that OTLP event name.
+const EventKey = "event"
+
+// Names of the daemon events exported as OTLP logs.
+const (
+	EventSourceFailover = "temperature.source.failover"
+	EventReadError      = "temperature.read_error"
+	EventReadingDropped = "temperature.reading_dropped"
+	EventPWMWriteError  = "fan.pwm.write_error"
+	EventEmergency      = "fan.emergency"
+	EventOverride       = "fan.override"
+	EventPower          = "slot.power"
+	EventConfigReload   = "config.reload"
+)
+
+// Event returns the log attribute marking a record as the named event.
+func Event(name string) slog.Attr {
+	return slog.String(EventKey, name)
+}
+
+// logHandler is a slog.Handler that exports records through the global
+// OpenTelemetry logger provider, which is a no-op until logs are enabled.
+type logHandler struct {
+	level  slog.Leveler
+	logger otellog.Logger
+	attrs  []otellog.KeyValue
+	event  string
+	prefix string
+}
+
+// NewLogHandler returns a slog.Handler exporting records at or above level as
+// OTLP logs. Combine it with the output handler using logging.Tee.
+func NewLogHandler(level slog.Leveler) slog.Handler {
+	return &logHandler{level: level, logger: global.Logger("nanoctl")}
+}
+
+func (h *logHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	var record otellog.Record
+	record.SetTimestamp(r.Time)
+	if r.Time.IsZero() {
+		record.SetTimestamp(time.Now())
+	}
+	record.SetBody(otellog.StringValue(r.Message))
+	record.SetSeverity(severity(r.Level))
+	record.SetSeverityText(r.Level.String())
+	record.AddAttributes(h.attrs...)
+
+	event := h.event
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == EventKey && h.prefix == "" {
+			event = a.Value.String()
+			return true
+		}
+		record.AddAttributes(convertAttr(h.prefix, a))
+		return true
+	})
+	if event != "" {
+		record.SetEventName(event)
+	}
+
+	h.logger.Emit(ctx, record)
+	return nil
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]otellog.KeyValue(nil), h.attrs...)
+	for _, a := range attrs {
+		if a.Key == EventKey && h.prefix == "" {
+			clone.event = a.Value.String()
+			continue
+		}
+		clone.attrs = append(clone.attrs, convertAttr(h.prefix, a))
+	}
+	return &clone
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix += name + "."
+	return &clone
+}
+
+func severity(level slog.Level) otellog.Severity {
+	switch {
+	case level >= slog.LevelError:
+		return otellog.SeverityError
+	case level >= slog.LevelWarn:
+		return otellog.SeverityWarn
+	case level >= slog.LevelInfo:
+		return otellog.SeverityInfo
+	default:
+		return otellog.SeverityDebug
+	}
+}
+
+func convertAttr(prefix string, a slog.Attr) otellog.KeyValue {
+	return otellog.KeyValue{Key: prefix + a.Key, Value: convertValue(a.Value)}
+}
+
+func convertValue(v slog.Value) otellog.Value {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindBool:
+		return otellog.BoolValue(v.Bool())
+	case slog.KindInt64:
+		return otellog.Int64Value(v.Int64())
+	case slog.KindUint64:
+		return otellog.Int64Value(int64(v.Uint64()))
+	case slog.KindFloat64:
+		return otellog.Float64Value(v.Float64())
+	case slog.KindGroup:
+		group := v.Group()
+		kvs := make([]otellog.KeyValue, 0, len(group))
+		for _, a := range group {
+			kvs = append(kvs, convertAttr("", a))
+		}
+		return otellog.MapValue(kvs...)
+	default:
+		return otellog.StringValue(v.String())
+	}
+}
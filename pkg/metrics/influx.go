@@ -0,0 +1,212 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// influxExporter writes metrics in the InfluxDB line protocol, either to
+// the HTTP write API or as UDP datagrams (InfluxDB 1.x UDP listener,
+// Telegraf socket_listener).
+//
+// Each instrument becomes a measurement named after it with dots replaced
+// by underscores, e.g. nanoctl_temperature_celsius, with a "value" field.
+// Histograms have count, sum, min and max fields covering the last interval.
+type influxExporter struct {
+	config OutputConfig
+	tags   map[string]string
+
+	// HTTP
+	client   *http.Client
+	writeURL string
+
+	// UDP
+	udp *udpSender
+}
+
+func newInfluxExporter(config OutputConfig, tags map[string]string, logger *slog.Logger) (*influxExporter, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid InfluxDB URL: %w", err)
+	}
+
+	e := &influxExporter{config: config, tags: tags}
+
+	switch u.Scheme {
+	case "udp":
+		udp, err := newUDPSender(u.Host, "InfluxDB")
+		if err != nil {
+			return nil, err
+		}
+		e.udp = udp
+		return e, nil
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported InfluxDB URL scheme '%s', expected http, https or udp", u.Scheme)
+	}
+
+	switch {
+	case config.Bucket != "":
+		u = u.JoinPath("api/v2/write")
+		query := u.Query()
+		query.Set("bucket", config.Bucket)
+		if config.Org != "" {
+			query.Set("org", config.Org)
+		}
+		u.RawQuery = query.Encode()
+	case config.Database != "":
+		u = u.JoinPath("write")
+		query := u.Query()
+		query.Set("db", config.Database)
+		u.RawQuery = query.Encode()
+	}
+	e.writeURL = u.String()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.TLS != nil {
+		tlsConfig, err := newTLSConfig(*config.TLS, config.URL, logger)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	e.client = &http.Client{Transport: transport, Timeout: 10 * time.Second}
+
+	return e, nil
+}
+
+// Temporality reports histograms as deltas so min and max describe the last
+// interval; counters stay cumulative as InfluxDB queries expect.
+func (e *influxExporter) Temporality(kind metric.InstrumentKind) metricdata.Temporality {
+	if kind == metric.InstrumentKindHistogram {
+		return metricdata.DeltaTemporality
+	}
+	return metricdata.CumulativeTemporality
+}
+
+func (e *influxExporter) Aggregation(kind metric.InstrumentKind) metric.Aggregation {
+	return outputAggregation(kind)
+}
+
+func (e *influxExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	lines := e.encode(collectPoints(rm))
+	if len(lines) == 0 {
+		return nil
+	}
+	if e.udp != nil {
+		return e.udp.send(ctx, datagrams(lines))
+	}
+	return e.sendHTTP(ctx, lines)
+}
+
+func (e *influxExporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (e *influxExporter) Shutdown(context.Context) error {
+	if e.udp != nil {
+		return e.udp.close()
+	}
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// encode converts points to line protocol lines.
+func (e *influxExporter) encode(points []point) []string {
+	lines := make([]string, 0, len(points))
+	for _, p := range points {
+		var b strings.Builder
+		b.WriteString(escapeInflux(strings.ReplaceAll(e.config.Prefix+p.name, ".", "_"), ", "))
+
+		// Data point attributes win over the global tags
+		tags := maps.Clone(e.tags)
+		for _, kv := range p.attrs {
+			tags[string(kv.Key)] = kv.Value.Emit()
+		}
+		for _, kv := range sortedTags(tags) {
+			// InfluxDB rejects empty tag values
+			if value := kv.Value.AsString(); value != "" {
+				fmt.Fprintf(&b, ",%s=%s", escapeInflux(string(kv.Key), ",= "), escapeInflux(value, ",= "))
+			}
+		}
+
+		b.WriteByte(' ')
+		if p.kind == kindHistogram {
+			fmt.Fprintf(&b, "count=%di,sum=%s", p.count, influxFloat(p.sum))
+			if p.hasMin {
+				fmt.Fprintf(&b, ",min=%s,max=%s", influxFloat(p.min), influxFloat(p.max))
+			}
+		} else if p.isInt {
+			fmt.Fprintf(&b, "value=%di", int64(p.value))
+		} else {
+			fmt.Fprintf(&b, "value=%s", influxFloat(p.value))
+		}
+
+		fmt.Fprintf(&b, " %d", p.time.UnixNano())
+		lines = append(lines, b.String())
+	}
+	return lines
+}
+
+func (e *influxExporter) sendHTTP(ctx context.Context, lines []string) error {
+	body := strings.Join(lines, "\n") + "\n"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.writeURL, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create InfluxDB request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	for name, value := range e.config.Headers {
+		req.Header.Set(name, value)
+	}
+	if e.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+e.config.Token)
+	} else if e.config.Username != "" {
+		req.SetBasicAuth(e.config.Username, e.config.Password)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send metrics to InfluxDB: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("InfluxDB write failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// escapeInflux backslash-escapes the given special characters. Newlines
+// can't be escaped in line protocol and are replaced with spaces.
+func escapeInflux(s, special string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if !strings.ContainsAny(s, special) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func influxFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestInfluxEncode(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		tags   map[string]string
+		point  point
+		want   string
+	}{
+		{
+			name:  "gauge",
+			point: point{name: "nanoctl.temperature.celsius", value: 45.5, time: testTime},
+			want:  "nanoctl_temperature_celsius value=45.5 1700000000000000123",
+		},
+		{
+			name:  "negative gauge",
+			point: point{name: "nanoctl.pid.error", value: -2.5, time: testTime},
+			want:  "nanoctl_pid_error value=-2.5 1700000000000000123",
+		},
+		{
+			name:  "integer",
+			point: point{name: "nanoctl.temperature.read_errors", kind: kindCounter, value: 3, isInt: true, time: testTime},
+			want:  "nanoctl_temperature_read_errors value=3i 1700000000000000123",
+		},
+		{
+			name:  "histogram",
+			point: point{name: "nanoctl.control_loop.duration", kind: kindHistogram, count: 2, sum: 0.5, min: 0.1, max: 0.4, hasMin: true, time: testTime},
+			want:  "nanoctl_control_loop_duration count=2i,sum=0.5,min=0.1,max=0.4 1700000000000000123",
+		},
+		{
+			name:  "empty histogram",
+			point: point{name: "nanoctl.control_loop.duration", kind: kindHistogram, time: testTime},
+			want:  "nanoctl_control_loop_duration count=0i,sum=0 1700000000000000123",
+		},
+		{
+			name:   "tags sorted, attributes win",
+			prefix: "cm5.",
+			tags:   map[string]string{"source": "global", "host": "node1", "rack": "a"},
+			point: point{name: "nanoctl.temperature.source.active", value: 1, time: testTime,
+				attrs: []attribute.KeyValue{attribute.String("source", "file")}},
+			want: "cm5_nanoctl_temperature_source_active,host=node1,rack=a,source=file value=1 1700000000000000123",
+		},
+		{
+			name:   "escaping",
+			prefix: "my rack,",
+			tags:   map[string]string{"lo cation": "row 1,seat=2", "empty": "", "multi": "line\nbreak"},
+			point:  point{name: "temp", value: 1, time: testTime},
+			want:   `my\ rack\,temp,lo\ cation=row\ 1\,seat\=2,multi=line\ break value=1 1700000000000000123`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags := tt.tags
+			if tags == nil {
+				tags = map[string]string{}
+			}
+			e := &influxExporter{config: OutputConfig{Prefix: tt.prefix}, tags: tags}
+			lines := e.encode([]point{tt.point})
+			if len(lines) != 1 || lines[0] != tt.want {
+				t.Errorf("encode =\n%q\nwant\n%q", lines, tt.want)
+			}
+		})
+	}
+}
+
+// influxRequest is a write request received by the test server.
+type influxRequest struct {
+	path, query   string
+	authorization string
+	custom        string
+	contentType   string
+	body          string
+}
+
+func TestInfluxHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var got influxRequest
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		got = influxRequest{
+			path:          r.URL.Path,
+			query:         r.URL.RawQuery,
+			authorization: r.Header.Get("Authorization"),
+			custom:        r.Header.Get("X-Custom"),
+			contentType:   r.Header.Get("Content-Type"),
+			body:          string(body),
+		}
+		w.WriteHeader(status)
+		if status >= 300 {
+			io.WriteString(w, `{"code":"unauthorized","message":"bad token"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	wantBody := strings.Join([]string{
+		"nanoctl_temperature_celsius,host=node1 value=45.5 1700000000000000123",
+		"nanoctl_pid_error,host=node1 value=-2.5 1700000000000000123",
+		"nanoctl_temperature_read_errors,host=node1,source=prometheus value=3i 1700000000000000123",
+		"nanoctl_control_loop_duration,host=node1 count=2i,sum=0.5,min=0.1,max=0.4 1700000000000000123",
+	}, "\n") + "\n"
+
+	tests := []struct {
+		name   string
+		config OutputConfig
+		want   influxRequest
+	}{
+		{
+			name:   "v2",
+			config: OutputConfig{Org: "home", Bucket: "nanoctl", Token: "secret"},
+			want:   influxRequest{path: "/api/v2/write", query: "bucket=nanoctl&org=home", authorization: "Token secret"},
+		},
+		{
+			name:   "v1",
+			config: OutputConfig{Database: "nanoctl", Username: "user", Password: "pass"},
+			want:   influxRequest{path: "/write", query: "db=nanoctl", authorization: "Basic dXNlcjpwYXNz"},
+		},
+		{
+			name:   "full URL",
+			config: OutputConfig{URL: "/custom/write?precision=ns", Headers: map[string]string{"X-Custom": "yes"}},
+			want:   influxRequest{path: "/custom/write", query: "precision=ns", custom: "yes"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Type = OutputInflux
+			tt.config.URL = server.URL + tt.config.URL
+			e, err := newInfluxExporter(tt.config, map[string]string{"host": "node1"}, slog.Default())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Shutdown(t.Context())
+
+			if err := e.Export(t.Context(), testMetrics()); err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			tt.want.contentType = "text/plain; charset=utf-8"
+			tt.want.body = wantBody
+			if got != tt.want {
+				t.Errorf("request =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		mu.Lock()
+		status = http.StatusUnauthorized
+		mu.Unlock()
+
+		e, err := newInfluxExporter(OutputConfig{Type: OutputInflux, URL: server.URL, Bucket: "nanoctl"}, map[string]string{}, slog.Default())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = e.Export(t.Context(), testMetrics())
+		if err == nil || !strings.Contains(err.Error(), "401") || !strings.Contains(err.Error(), "bad token") {
+			t.Errorf("err = %v, want the status and the response body", err)
+		}
+	})
+}
+
+func TestInfluxHTTPTimeout(t *testing.T) {
+	// A server that doesn't answer until the test ends
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(done) })
+
+	e, err := newInfluxExporter(OutputConfig{Type: OutputInflux, URL: server.URL, Bucket: "nanoctl"}, map[string]string{}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Shutdown(t.Context())
+
+	// The reader bounds each export by the export timeout through ctx
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := e.Export(ctx, testMetrics()); err == nil {
+		t.Error("Export to a server that doesn't answer succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Export took %s, want it to stop at the deadline", elapsed)
+	}
+}
+
+func TestInfluxUDP(t *testing.T) {
+	addr, receive := listenUDP(t)
+	e, err := newInfluxExporter(OutputConfig{Type: OutputInflux, URL: "udp://" + addr}, map[string]string{}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Shutdown(t.Context())
+
+	if err := e.Export(t.Context(), testMetrics()); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	want := "nanoctl_temperature_celsius value=45.5 1700000000000000123\n" +
+		"nanoctl_pid_error value=-2.5 1700000000000000123\n" +
+		"nanoctl_temperature_read_errors,source=prometheus value=3i 1700000000000000123\n" +
+		"nanoctl_control_loop_duration count=2i,sum=0.5,min=0.1,max=0.4 1700000000000000123\n"
+	if got := receive(); got != want {
+		t.Errorf("datagram =\n%s\nwant\n%s", got, want)
+	}
+
+	// Larger exports are split on line boundaries
+	if err := e.Export(t.Context(), gauges(100)); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	var lines int
+	for lines < 100 {
+		packet := receive()
+		if len(packet) > maxDatagramSize || !strings.HasSuffix(packet, "\n") {
+			t.Fatalf("invalid packet of %d bytes", len(packet))
+		}
+		lines += strings.Count(packet, "\n")
+	}
+	if lines != 100 {
+		t.Errorf("received %d lines, want 100", lines)
+	}
+}
+
+func TestInfluxInvalidURL(t *testing.T) {
+	for _, url := range []string{"tcp://influx:8086", "udp://influx"} {
+		if _, err := newInfluxExporter(OutputConfig{Type: OutputInflux, URL: url}, nil, slog.Default()); err == nil {
+			t.Errorf("%s was accepted", url)
+		}
+	}
+}
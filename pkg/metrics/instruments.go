@@ -0,0 +1,257 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/logging"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Attribute keys shared by all nanoctl instruments.
+const (
+	AttrSource = attribute.Key("source") // Temperature source name, e.g. "prometheus"
+	AttrTerm   = attribute.Key("term")   // PID term: "p", "i" or "d"
+	AttrMode   = attribute.Key("mode")   // PWM mode: "software" or "hardware"
+	AttrFrom   = attribute.Key("from")   // Source switched away from
+	AttrTo     = attribute.Key("to")     // Source switched to
+)
+
+// Instruments holds every metric recorded by the fan controller so names,
+// units and attributes stay consistent in one place.
+//
+// All methods are safe to call on a nil *Instruments, which records nothing.
+type Instruments struct {
+	temperature    metric.Float64Gauge
+	rawTemperature metric.Float64Gauge
+	dutyCycle      metric.Float64Gauge
+	setpoint       metric.Float64Gauge
+	pidError       metric.Float64Gauge
+	pidTerm        metric.Float64Gauge
+	pidRawOutput   metric.Float64Gauge
+	loopDuration   metric.Float64Histogram
+	readErrors     metric.Int64Counter
+	activeSource   metric.Int64Gauge
+	failovers      metric.Int64Counter
+	override       metric.Int64Gauge
+	pwmWriteErrors metric.Int64Counter
+
+	mu         sync.Mutex
+	lastSource string
+}
+
+// NewInstruments creates the nanoctl instruments on the global meter provider.
+// Instruments that fail to register are logged and skipped.
+func NewInstruments(logger *slog.Logger) *Instruments {
+	logger = logging.OrDefault(logger)
+	meter := otel.Meter("nanoctl")
+	inst := &Instruments{}
+
+	report := func(name string, err error) {
+		if err != nil {
+			logger.Error("Failed to create instrument", "instrument", name, "error", err)
+		}
+	}
+
+	var err error
+	inst.temperature, err = meter.Float64Gauge("nanoctl.temperature.celsius",
+		metric.WithDescription("Current (filtered) temperature used by the controller"),
+		metric.WithUnit("Ce"),
+	)
+	report("temperature", err)
+
+	inst.rawTemperature, err = meter.Float64Gauge("nanoctl.temperature.raw.celsius",
+		metric.WithDescription("Unfiltered temperature as read from the source"),
+		metric.WithUnit("Ce"),
+	)
+	report("raw temperature", err)
+
+	inst.dutyCycle, err = meter.Float64Gauge("nanoctl.fan.duty_cycle.percent",
+		metric.WithDescription("Current Fan PWM duty cycle"),
+		metric.WithUnit("%"),
+	)
+	report("duty cycle", err)
+
+	inst.setpoint, err = meter.Float64Gauge("nanoctl.pid.setpoint.celsius",
+		metric.WithDescription("Target temperature of the PID controller"),
+		metric.WithUnit("Ce"),
+	)
+	report("setpoint", err)
+
+	inst.pidError, err = meter.Float64Gauge("nanoctl.pid.error.celsius",
+		metric.WithDescription("Temperature minus setpoint; positive when too hot"),
+		metric.WithUnit("Ce"),
+	)
+	report("PID error", err)
+
+	inst.pidTerm, err = meter.Float64Gauge("nanoctl.pid.contribution.percent",
+		metric.WithDescription("Contribution of each PID term to the output"),
+		metric.WithUnit("%"),
+	)
+	report("PID contribution", err)
+
+	inst.pidRawOutput, err = meter.Float64Gauge("nanoctl.pid.output.raw.percent",
+		metric.WithDescription("PID output before clamping to 0-100%"),
+		metric.WithUnit("%"),
+	)
+	report("PID raw output", err)
+
+	inst.loopDuration, err = meter.Float64Histogram("nanoctl.control_loop.duration.seconds",
+		metric.WithDescription("Duration of one control loop iteration"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5),
+	)
+	report("loop duration", err)
+
+	inst.readErrors, err = meter.Int64Counter("nanoctl.temperature.read_errors",
+		metric.WithDescription("Failed temperature reads by source"),
+	)
+	report("read errors", err)
+
+	inst.activeSource, err = meter.Int64Gauge("nanoctl.temperature.source.active",
+		metric.WithDescription("1 for the temperature source currently in use, 0 otherwise"),
+	)
+	report("active source", err)
+
+	inst.failovers, err = meter.Int64Counter("nanoctl.temperature.source.failovers",
+		metric.WithDescription("Switches between temperature sources"),
+	)
+	report("failovers", err)
+
+	inst.override, err = meter.Int64Gauge("nanoctl.fan.override.active",
+		metric.WithDescription("1 while the fan duty cycle is manually overridden"),
+	)
+	report("override", err)
+
+	inst.pwmWriteErrors, err = meter.Int64Counter("nanoctl.fan.pwm.write_errors",
+		metric.WithDescription("Failed writes to the PWM output"),
+	)
+	report("PWM write errors", err)
+
+	return inst
+}
+
+// RecordTemperature records the raw and filtered temperature.
+func (i *Instruments) RecordTemperature(ctx context.Context, raw, filtered float64) {
+	if i == nil {
+		return
+	}
+	if i.rawTemperature != nil {
+		i.rawTemperature.Record(ctx, raw)
+	}
+	if i.temperature != nil {
+		i.temperature.Record(ctx, filtered)
+	}
+}
+
+// RecordRawTemperature records a raw reading that did not make it through the filter.
+func (i *Instruments) RecordRawTemperature(ctx context.Context, raw float64) {
+	if i == nil || i.rawTemperature == nil {
+		return
+	}
+	i.rawTemperature.Record(ctx, raw)
+}
+
+// PIDSample carries the values of one PID update.
+type PIDSample struct {
+	Setpoint, Error float64
+	P, I, D         float64
+	Raw             float64
+}
+
+// RecordPID records the setpoint, error, term contributions and raw output.
+func (i *Instruments) RecordPID(ctx context.Context, s PIDSample) {
+	if i == nil {
+		return
+	}
+	if i.setpoint != nil {
+		i.setpoint.Record(ctx, s.Setpoint)
+	}
+	if i.pidError != nil {
+		i.pidError.Record(ctx, s.Error)
+	}
+	if i.pidTerm != nil {
+		i.pidTerm.Record(ctx, s.P, metric.WithAttributes(AttrTerm.String("p")))
+		i.pidTerm.Record(ctx, s.I, metric.WithAttributes(AttrTerm.String("i")))
+		i.pidTerm.Record(ctx, s.D, metric.WithAttributes(AttrTerm.String("d")))
+	}
+	if i.pidRawOutput != nil {
+		i.pidRawOutput.Record(ctx, s.Raw)
+	}
+}
+
+// RecordDutyCycle records the duty cycle applied to the fan.
+func (i *Instruments) RecordDutyCycle(ctx context.Context, dc float64) {
+	if i == nil || i.dutyCycle == nil {
+		return
+	}
+	i.dutyCycle.Record(ctx, dc)
+}
+
+// RecordLoopDuration records how long a control loop iteration took.
+func (i *Instruments) RecordLoopDuration(ctx context.Context, d time.Duration) {
+	if i == nil || i.loopDuration == nil {
+		return
+	}
+	i.loopDuration.Record(ctx, d.Seconds())
+}
+
+// RecordReadError counts a failed read from the named source.
+func (i *Instruments) RecordReadError(ctx context.Context, source string) {
+	if i == nil || i.readErrors == nil {
+		return
+	}
+	i.readErrors.Add(ctx, 1, metric.WithAttributes(AttrSource.String(source)))
+}
+
+// SetActiveSource marks the named source as the one in use.
+func (i *Instruments) SetActiveSource(ctx context.Context, source string) {
+	if i == nil || i.activeSource == nil {
+		return
+	}
+	i.mu.Lock()
+	previous := i.lastSource
+	i.lastSource = source
+	i.mu.Unlock()
+
+	if previous != "" && previous != source {
+		i.activeSource.Record(ctx, 0, metric.WithAttributes(AttrSource.String(previous)))
+	}
+	i.activeSource.Record(ctx, 1, metric.WithAttributes(AttrSource.String(source)))
+}
+
+// RecordFailover counts a switch between temperature sources and updates the active source.
+func (i *Instruments) RecordFailover(ctx context.Context, from, to string) {
+	if i == nil {
+		return
+	}
+	if i.failovers != nil {
+		i.failovers.Add(ctx, 1, metric.WithAttributes(AttrFrom.String(from), AttrTo.String(to)))
+	}
+	i.SetActiveSource(ctx, to)
+}
+
+// RecordOverride records whether the fan duty cycle is manually overridden.
+func (i *Instruments) RecordOverride(ctx context.Context, active bool) {
+	if i == nil || i.override == nil {
+		return
+	}
+	var v int64
+	if active {
+		v = 1
+	}
+	i.override.Record(ctx, v)
+}
+
+// RecordPWMWriteError counts a failed write to the PWM output.
+func (i *Instruments) RecordPWMWriteError(ctx context.Context, mode string) {
+	if i == nil || i.pwmWriteErrors == nil {
+		return
+	}
+	i.pwmWriteErrors.Add(ctx, 1, metric.WithAttributes(AttrMode.String(mode)))
+}
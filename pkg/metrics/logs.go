@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"google.golang.org/grpc/credentials"
+)
+
+// setupLoggerProvider sets the global OpenTelemetry logger provider, exporting
+// to the same OTLP endpoint, credentials and TLS settings as the metrics.
+func setupLoggerProvider(ctx context.Context, config OTLPConfig, res *resource.Resource, logger *slog.Logger) (func(context.Context) error, error) {
+	exporter, err := newOTLPLogExporter(ctx, config, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdklog.NewLoggerProvider(
+		sdklog.WithResource(res),
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+	)
+
+	global.SetLoggerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newOTLPLogExporter(ctx context.Context, config OTLPConfig, logger *slog.Logger) (sdklog.Exporter, error) {
+	if strings.HasPrefix(config.Endpoint, "http://") || strings.HasPrefix(config.Endpoint, "https://") {
+		return newOTLPHTTPLogExporter(ctx, config, logger)
+	}
+	return newOTLPGRPCLogExporter(ctx, config, logger)
+}
+
+func newOTLPHTTPLogExporter(ctx context.Context, config OTLPConfig, logger *slog.Logger) (sdklog.Exporter, error) {
+	endpoint, err := logsEndpointURL(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []otlploghttp.Option
+	opts = append(opts, otlploghttp.WithEndpointURL(endpoint))
+
+	if config.Insecure {
+		opts = append(opts, otlploghttp.WithInsecure())
+	}
+
+	if config.TLS != nil {
+		tlsConfig, err := newTLSConfig(*config.TLS, config.Endpoint, logger)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlploghttp.WithTLSClientConfig(tlsConfig))
+	}
+
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlploghttp.WithHeaders(config.Headers))
+	}
+
+	exporter, err := otlploghttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP HTTP log exporter: %w", err)
+	}
+
+	return exporter, nil
+}
+
+func newOTLPGRPCLogExporter(ctx context.Context, config OTLPConfig, logger *slog.Logger) (sdklog.Exporter, error) {
+	var opts []otlploggrpc.Option
+	opts = append(opts, otlploggrpc.WithEndpoint(config.Endpoint))
+
+	if config.Insecure {
+		opts = append(opts, otlploggrpc.WithInsecure())
+	}
+
+	if config.TLS != nil {
+		tlsConfig, err := newTLSConfig(*config.TLS, config.Endpoint, logger)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlploggrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	}
+
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlploggrpc.WithHeaders(config.Headers))
+	}
+
+	exporter, err := otlploggrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP gRPC log exporter: %w", err)
+	}
+
+	return exporter, nil
+}
+
+// logsEndpointURL derives the OTLP/HTTP logs URL from the metrics endpoint,
+// e.g. http://collector:4318/v1/metrics becomes http://collector:4318/v1/logs.
+func logsEndpointURL(metricsEndpoint string) (string, error) {
+	u, err := url.Parse(metricsEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid metrics endpoint %s: %w", metricsEndpoint, err)
+	}
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/v1/metrics") + "/v1/logs"
+	return u.String(), nil
+}
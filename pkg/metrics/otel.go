@@ -2,39 +2,155 @@ package metrics
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
+	"github.com/AlejandroPerez92/nanoctl/pkg/logging"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"google.golang.org/grpc/credentials"
 )
 
-// InitOTLP initializes the OpenTelemetry OTLP metric exporter.
+// Config selects the metric exporters. Several exporters can be active at
+// the same time; they all observe the same instruments.
+type Config struct {
+	OTLP       *OTLPConfig       // Optional: push to an OTLP collector
+	Prometheus *PrometheusConfig // Optional: serve a Prometheus /metrics endpoint
+	Outputs    []OutputConfig    // Optional: InfluxDB and StatsD outputs
+	Resource   ResourceConfig
+	Logger     *slog.Logger // Optional: defaults to slog.Default()
+}
+
+// ResourceConfig describes the resource attached to every exported metric.
+type ResourceConfig struct {
+	Attributes map[string]string // Extra attributes, e.g. cluster or location
+	Detectors  []string          // Resource detectors: "host", "os", "process", "container"
+}
+
+// OTLPConfig holds the OTLP push exporter settings.
+type OTLPConfig struct {
+	Endpoint string
+	Insecure bool
+	Interval time.Duration
+	Headers  map[string]string
+	TLS      *TLSConfig // Optional: CA bundle, client certificate and server name
+	Logs     bool       // Also export daemon events as OTLP logs to the same endpoint
+}
+
+// Init initializes the global OpenTelemetry meter provider with the
+// configured exporters.
 // It returns a shutdown function that should be called when the application exits.
-func InitOTLP(ctx context.Context, endpoint string, insecure bool, interval time.Duration, headers map[string]string) (func(context.Context) error, error) {
+// If it fails, the exporters and listeners it already started are stopped.
+func Init(ctx context.Context, config Config) (func(context.Context) error, error) {
+	logger := logging.OrDefault(config.Logger)
+	var readers []metric.Reader
+	var closers []func(context.Context) error
+
+	fail := func(err error) (func(context.Context) error, error) {
+		// Readers that aren't registered with a provider only shut down their exporter
+		for _, reader := range readers {
+			_ = reader.Shutdown(ctx)
+		}
+		for _, closer := range closers {
+			_ = closer(ctx)
+		}
+		return nil, err
+	}
+
+	// Metrics and logs share the resource so the backend can correlate them
+	res, err := newResource(ctx, config.Resource, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.OTLP != nil {
+		exporter, err := newOTLPExporter(ctx, *config.OTLP, logger)
+		if err != nil {
+			return fail(err)
+		}
+		readers = append(readers, metric.NewPeriodicReader(exporter, metric.WithInterval(config.OTLP.Interval)))
+	}
+
+	if config.Prometheus != nil {
+		reader, shutdown, err := newPrometheusReader(*config.Prometheus, logger)
+		if err != nil {
+			return fail(err)
+		}
+		readers = append(readers, reader)
+		closers = append(closers, shutdown)
+	}
+
+	tags := outputTags(res, config.Resource)
+	for i, output := range config.Outputs {
+		exporter, err := newOutputExporter(output, tags, logger)
+		if err != nil {
+			return fail(fmt.Errorf("metrics output %d (%s): %w", i+1, output.Type, err))
+		}
+		readers = append(readers, metric.NewPeriodicReader(exporter, metric.WithInterval(output.Interval)))
+	}
+
+	if len(readers) == 0 {
+		return nil, fmt.Errorf("no metric exporter configured")
+	}
+
+	if config.OTLP != nil && config.OTLP.Logs {
+		shutdown, err := setupLoggerProvider(ctx, *config.OTLP, res, logger)
+		if err != nil {
+			return fail(err)
+		}
+		closers = append(closers, shutdown)
+	}
+
+	opts := make([]metric.Option, 0, len(readers))
+	for _, reader := range readers {
+		opts = append(opts, metric.WithReader(reader))
+	}
+	shutdown := setupProvider(res, opts...)
+
+	return func(ctx context.Context) error {
+		errs := []error{shutdown(ctx)}
+		for _, closer := range closers {
+			errs = append(errs, closer(ctx))
+		}
+		return errors.Join(errs...)
+	}, nil
+}
+
+func newOTLPExporter(ctx context.Context, config OTLPConfig, logger *slog.Logger) (metric.Exporter, error) {
 	// Determine protocol based on endpoint prefix or explicit configuration
 	// If endpoint starts with http:// or https://, use HTTP exporter
-	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
-		return initOTLPHTTP(ctx, endpoint, insecure, interval, headers)
+	if strings.HasPrefix(config.Endpoint, "http://") || strings.HasPrefix(config.Endpoint, "https://") {
+		return newOTLPHTTPExporter(ctx, config, logger)
 	}
-	return initOTLPGRPC(ctx, endpoint, insecure, interval, headers)
+	return newOTLPGRPCExporter(ctx, config, logger)
 }
 
-func initOTLPHTTP(ctx context.Context, endpoint string, insecure bool, interval time.Duration, headers map[string]string) (func(context.Context) error, error) {
+func newOTLPHTTPExporter(ctx context.Context, config OTLPConfig, logger *slog.Logger) (metric.Exporter, error) {
 	var opts []otlpmetrichttp.Option
-	opts = append(opts, otlpmetrichttp.WithEndpointURL(endpoint))
+	opts = append(opts, otlpmetrichttp.WithEndpointURL(config.Endpoint))
 
-	if insecure {
+	if config.Insecure {
 		opts = append(opts, otlpmetrichttp.WithInsecure())
 	}
 
-	if len(headers) > 0 {
-		opts = append(opts, otlpmetrichttp.WithHeaders(headers))
+	if config.TLS != nil {
+		tlsConfig, err := newTLSConfig(*config.TLS, config.Endpoint, logger)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(tlsConfig))
+	}
+
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlpmetrichttp.WithHeaders(config.Headers))
 	}
 
 	exporter, err := otlpmetrichttp.New(ctx, opts...)
@@ -42,19 +158,27 @@ func initOTLPHTTP(ctx context.Context, endpoint string, insecure bool, interval
 		return nil, fmt.Errorf("failed to create OTLP HTTP metric exporter: %w", err)
 	}
 
-	return setupProvider(ctx, exporter, interval)
+	return exporter, nil
 }
 
-func initOTLPGRPC(ctx context.Context, endpoint string, insecure bool, interval time.Duration, headers map[string]string) (func(context.Context) error, error) {
+func newOTLPGRPCExporter(ctx context.Context, config OTLPConfig, logger *slog.Logger) (metric.Exporter, error) {
 	var opts []otlpmetricgrpc.Option
-	opts = append(opts, otlpmetricgrpc.WithEndpoint(endpoint))
+	opts = append(opts, otlpmetricgrpc.WithEndpoint(config.Endpoint))
 
-	if insecure {
+	if config.Insecure {
 		opts = append(opts, otlpmetricgrpc.WithInsecure())
 	}
 
-	if len(headers) > 0 {
-		opts = append(opts, otlpmetricgrpc.WithHeaders(headers))
+	if config.TLS != nil {
+		tlsConfig, err := newTLSConfig(*config.TLS, config.Endpoint, logger)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	}
+
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(config.Headers))
 	}
 
 	exporter, err := otlpmetricgrpc.New(ctx, opts...)
@@ -62,26 +186,59 @@ func initOTLPGRPC(ctx context.Context, endpoint string, insecure bool, interval
 		return nil, fmt.Errorf("failed to create OTLP gRPC metric exporter: %w", err)
 	}
 
-	return setupProvider(ctx, exporter, interval)
+	return exporter, nil
 }
 
-func setupProvider(ctx context.Context, exporter metric.Exporter, interval time.Duration) (func(context.Context) error, error) {
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName("nanoctl"),
-		),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create resource: %w", err)
-	}
-
-	reader := metric.NewPeriodicReader(exporter, metric.WithInterval(interval))
+func setupProvider(res *resource.Resource, opts ...metric.Option) func(context.Context) error {
 	provider := metric.NewMeterProvider(
-		metric.WithResource(res),
-		metric.WithReader(reader),
+		append([]metric.Option{metric.WithResource(res)}, opts...)...,
 	)
 
 	otel.SetMeterProvider(provider)
 
-	return provider.Shutdown, nil
+	return provider.Shutdown
+}
+
+// newResource builds the nanoctl resource. Detected attributes are applied
+// first, then OTEL_RESOURCE_ATTRIBUTES, then the configured attributes, so
+// configuration always wins.
+func newResource(ctx context.Context, config ResourceConfig, logger *slog.Logger) (*resource.Resource, error) {
+	resOpts := []resource.Option{
+		resource.WithAttributes(semconv.ServiceName("nanoctl")),
+	}
+
+	for _, detector := range config.Detectors {
+		switch detector {
+		case "host":
+			resOpts = append(resOpts, resource.WithHost())
+		case "os":
+			resOpts = append(resOpts, resource.WithOS())
+		case "process":
+			resOpts = append(resOpts, resource.WithProcess())
+		case "container":
+			resOpts = append(resOpts, resource.WithContainer())
+		default:
+			return nil, fmt.Errorf("unknown resource detector: %s", detector)
+		}
+	}
+
+	resOpts = append(resOpts, resource.WithFromEnv())
+
+	if len(config.Attributes) > 0 {
+		attrs := make([]attribute.KeyValue, 0, len(config.Attributes))
+		for key, value := range config.Attributes {
+			attrs = append(attrs, attribute.String(key, value))
+		}
+		resOpts = append(resOpts, resource.WithAttributes(attrs...))
+	}
+
+	res, err := resource.New(ctx, resOpts...)
+	if err != nil {
+		// Partial detector failures still return a usable resource
+		if res == nil {
+			return nil, fmt.Errorf("failed to create resource: %w", err)
+		}
+		logger.Warn("Resource detection incomplete", "error", err)
+	}
+	return res, nil
 }
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestInitStopsExportersOnError(t *testing.T) {
+	addr := freeAddr(t)
+	statsd, _ := listenUDP(t)
+
+	_, err := Init(t.Context(), Config{
+		Prometheus: &PrometheusConfig{Listen: addr},
+		Outputs: []OutputConfig{
+			{Type: OutputStatsD, URL: statsd, Interval: time.Minute},
+			{Type: "graphite", URL: "udp://graphite:2003", Interval: time.Minute},
+		},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err == nil {
+		t.Fatal("Init accepted an unsupported output")
+	}
+
+	// The Prometheus listener started before the failing output is closed
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("the Prometheus listener was left open: %v", err)
+	}
+	listener.Close()
+}
+
+func TestInitAcceptsUnresolvableUDPOutput(t *testing.T) {
+	shutdown, err := Init(t.Context(), Config{
+		Outputs: []OutputConfig{{Type: OutputDogStatsD, URL: "udp://nanoctl.invalid:8125", Interval: time.Minute}},
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatalf("Init failed on a UDP output that doesn't resolve yet: %v", err)
+	}
+	// The final export fails to resolve
+	_ = shutdown(t.Context())
+}
+
+// freeAddr returns a local TCP address nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
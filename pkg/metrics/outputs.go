@@ -0,0 +1,282 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// Output types supported in addition to OTLP and Prometheus.
+const (
+	OutputInflux    = "influx"    // InfluxDB line protocol over HTTP or UDP
+	OutputStatsD    = "statsd"    // Plain StatsD over UDP
+	OutputDogStatsD = "dogstatsd" // StatsD with DogStatsD tags over UDP
+)
+
+// OutputConfig holds the settings of an InfluxDB or StatsD output.
+type OutputConfig struct {
+	Type     string            // OutputInflux, OutputStatsD or OutputDogStatsD
+	URL      string            // e.g. "http://influxdb:8086", "udp://telegraf:8089" or "udp://localhost:8125"
+	Interval time.Duration     // How often metrics are sent
+	Prefix   string            // Optional: prepended to metric names
+	Tags     map[string]string // Optional: extra tags added to every metric
+
+	// InfluxDB over HTTP. With Bucket the v2 API is used, with Database the
+	// v1 API; with neither, URL is used as the complete write URL.
+	Org      string
+	Bucket   string
+	Database string
+	Token    string // Sent as "Authorization: Token ..."
+	Username string // Basic auth, e.g. for the v1 API
+	Password string
+	Headers  map[string]string
+	TLS      *TLSConfig
+}
+
+// maxDatagramSize keeps UDP packets below a typical Ethernet MTU so they
+// are not fragmented (or dropped) on the way.
+const maxDatagramSize = 1432
+
+// newOutputExporter creates the exporter for an InfluxDB or StatsD output.
+// Tags are added to every metric together with the output's own tags.
+func newOutputExporter(config OutputConfig, tags map[string]string, logger *slog.Logger) (metric.Exporter, error) {
+	merged := maps.Clone(tags)
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	maps.Copy(merged, config.Tags)
+
+	switch config.Type {
+	case OutputInflux:
+		return newInfluxExporter(config, merged, logger)
+	case OutputStatsD, OutputDogStatsD:
+		return newStatsDExporter(config, merged)
+	default:
+		return nil, fmt.Errorf("unsupported metrics output type: %s", config.Type)
+	}
+}
+
+// outputTags returns the resource attributes that identify this node as
+// tags: the host name and the configured resource attributes.
+func outputTags(res *resource.Resource, config ResourceConfig) map[string]string {
+	tags := make(map[string]string, len(config.Attributes)+1)
+	if host, ok := res.Set().Value(semconv.HostNameKey); ok && host.AsString() != "" {
+		tags["host"] = host.AsString()
+	}
+	maps.Copy(tags, config.Attributes)
+	return tags
+}
+
+// pointKind is the flattened kind of a data point sent to an output.
+type pointKind int
+
+const (
+	kindGauge pointKind = iota
+	kindCounter
+	kindHistogram
+)
+
+// point is a single data point flattened from the OTel metric data model,
+// which the InfluxDB and StatsD encoders work from.
+type point struct {
+	name  string
+	kind  pointKind
+	attrs []attribute.KeyValue // Sorted by key
+	time  time.Time
+
+	value float64
+	isInt bool
+
+	// Histograms only
+	count    uint64
+	sum      float64
+	min, max float64
+	hasMin   bool
+}
+
+// collectPoints flattens the supported aggregations of rm into points.
+// Non-monotonic sums are reported as gauges.
+func collectPoints(rm *metricdata.ResourceMetrics) []point {
+	var points []point
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[float64]:
+				points = appendValues(points, m.Name, kindGauge, data.DataPoints, false)
+			case metricdata.Gauge[int64]:
+				points = appendValues(points, m.Name, kindGauge, data.DataPoints, true)
+			case metricdata.Sum[float64]:
+				points = appendValues(points, m.Name, sumKind(data.IsMonotonic), data.DataPoints, false)
+			case metricdata.Sum[int64]:
+				points = appendValues(points, m.Name, sumKind(data.IsMonotonic), data.DataPoints, true)
+			case metricdata.Histogram[float64]:
+				points = appendHistograms(points, m.Name, data.DataPoints)
+			case metricdata.Histogram[int64]:
+				points = appendHistograms(points, m.Name, data.DataPoints)
+			}
+		}
+	}
+	return points
+}
+
+func sumKind(monotonic bool) pointKind {
+	if monotonic {
+		return kindCounter
+	}
+	return kindGauge
+}
+
+func appendValues[N int64 | float64](points []point, name string, kind pointKind, dps []metricdata.DataPoint[N], isInt bool) []point {
+	for _, dp := range dps {
+		v := float64(dp.Value)
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		points = append(points, point{
+			name:  name,
+			kind:  kind,
+			attrs: sortedAttrs(dp.Attributes),
+			time:  dp.Time,
+			value: v,
+			isInt: isInt,
+		})
+	}
+	return points
+}
+
+func appendHistograms[N int64 | float64](points []point, name string, dps []metricdata.HistogramDataPoint[N]) []point {
+	for _, dp := range dps {
+		p := point{
+			name:  name,
+			kind:  kindHistogram,
+			attrs: sortedAttrs(dp.Attributes),
+			time:  dp.Time,
+			count: dp.Count,
+			sum:   float64(dp.Sum),
+		}
+		if minimum, ok := dp.Min.Value(); ok {
+			p.min, p.hasMin = float64(minimum), true
+		}
+		if maximum, ok := dp.Max.Value(); ok {
+			p.max = float64(maximum)
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+func sortedAttrs(set attribute.Set) []attribute.KeyValue {
+	attrs := set.ToSlice()
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
+}
+
+// sortedTags returns tags as key/value pairs sorted by key.
+func sortedTags(tags map[string]string) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(tags))
+	for key, value := range tags {
+		attrs = append(attrs, attribute.String(key, value))
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
+}
+
+// histogramAggregation drops the histogram buckets, which neither InfluxDB
+// nor StatsD outputs send; count, sum, min and max are kept.
+var histogramAggregation = metric.AggregationExplicitBucketHistogram{Boundaries: []float64{}}
+
+// outputAggregation selects the aggregations used by the outputs.
+func outputAggregation(kind metric.InstrumentKind) metric.Aggregation {
+	if kind == metric.InstrumentKindHistogram {
+		return histogramAggregation
+	}
+	return metric.DefaultAggregationSelector(kind)
+}
+
+// datagrams splits newline-terminated lines into UDP payloads of at most
+// maxDatagramSize bytes. A single longer line is sent on its own.
+func datagrams(lines []string) [][]byte {
+	var packets [][]byte
+	var current []byte
+	for _, line := range lines {
+		if len(current) > 0 && len(current)+len(line)+1 > maxDatagramSize {
+			packets = append(packets, current)
+			current = nil
+		}
+		current = append(current, line...)
+		current = append(current, '\n')
+	}
+	if len(current) > 0 {
+		packets = append(packets, current)
+	}
+	return packets
+}
+
+// udpSender sends datagrams to a UDP output. The address is resolved on the
+// first send and again after a failed one, so a name that doesn't resolve at
+// startup, or moves, doesn't disable the output.
+type udpSender struct {
+	address string
+	target  string // Output name for errors, e.g. "StatsD"
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// newUDPSender checks that address is a host:port without resolving it.
+func newUDPSender(address, target string) (*udpSender, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid %s address: %w", target, err)
+	}
+	return &udpSender{address: address, target: target}, nil
+}
+
+// send writes the packets, honouring the context deadline.
+func (s *udpSender) send(ctx context.Context, packets [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		conn, err := net.Dial("udp", s.address)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s address: %w", s.target, err)
+		}
+		s.conn = conn
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
+		return fmt.Errorf("failed to set %s write deadline: %w", s.target, err)
+	}
+	for _, packet := range packets {
+		if _, err := s.conn.Write(packet); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return fmt.Errorf("failed to send metrics to %s: %w", s.target, err)
+		}
+	}
+	return nil
+}
+
+func (s *udpSender) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
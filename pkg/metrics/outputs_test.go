@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var testTime = time.Unix(1700000000, 123)
+
+// testMetrics returns one metric of each kind the outputs handle.
+func testMetrics() *metricdata.ResourceMetrics {
+	return &metricdata.ResourceMetrics{ScopeMetrics: []metricdata.ScopeMetrics{{Metrics: []metricdata.Metrics{
+		{Name: "nanoctl.temperature.celsius", Data: metricdata.Gauge[float64]{DataPoints: []metricdata.DataPoint[float64]{
+			{Time: testTime, Value: 45.5},
+		}}},
+		{Name: "nanoctl.pid.error", Data: metricdata.Gauge[float64]{DataPoints: []metricdata.DataPoint[float64]{
+			{Time: testTime, Value: -2.5},
+		}}},
+		{Name: "nanoctl.temperature.read_errors", Data: metricdata.Sum[int64]{IsMonotonic: true, DataPoints: []metricdata.DataPoint[int64]{
+			{Time: testTime, Value: 3, Attributes: attribute.NewSet(attribute.String("source", "prometheus"))},
+		}}},
+		{Name: "nanoctl.control_loop.duration", Data: metricdata.Histogram[float64]{DataPoints: []metricdata.HistogramDataPoint[float64]{
+			{Time: testTime, Count: 2, Sum: 0.5, Min: metricdata.NewExtrema(0.1), Max: metricdata.NewExtrema(0.4)},
+		}}},
+	}}}}
+}
+
+// gauges returns a gauge with n data points, each with a distinct source.
+func gauges(n int) *metricdata.ResourceMetrics {
+	dps := make([]metricdata.DataPoint[float64], n)
+	for i := range dps {
+		dps[i] = metricdata.DataPoint[float64]{
+			Time:       testTime,
+			Value:      float64(i),
+			Attributes: attribute.NewSet(attribute.String("source", fmt.Sprintf("source-%03d", i))),
+		}
+	}
+	return &metricdata.ResourceMetrics{ScopeMetrics: []metricdata.ScopeMetrics{{Metrics: []metricdata.Metrics{
+		{Name: "nanoctl.temperature.celsius", Data: metricdata.Gauge[float64]{DataPoints: dps}},
+	}}}}
+}
+
+func TestCollectPointsSkipsNonFinite(t *testing.T) {
+	rm := &metricdata.ResourceMetrics{ScopeMetrics: []metricdata.ScopeMetrics{{Metrics: []metricdata.Metrics{
+		{Name: "nanoctl.fan.rpm", Data: metricdata.Gauge[float64]{DataPoints: []metricdata.DataPoint[float64]{
+			{Value: math.NaN()}, {Value: 1200},
+		}}},
+	}}}}
+	points := collectPoints(rm)
+	if len(points) != 1 || points[0].value != 1200 {
+		t.Errorf("points = %+v, want only the 1200 reading", points)
+	}
+}
+
+func TestDatagrams(t *testing.T) {
+	line := strings.Repeat("x", 99) // 100 bytes with the newline
+	long := strings.Repeat("y", maxDatagramSize+10)
+
+	tests := []struct {
+		name  string
+		lines []string
+		sizes []int
+	}{
+		{"empty", nil, nil},
+		{"one", []string{line}, []int{100}},
+		{"full packet", slices.Repeat([]string{line}, 14), []int{1400}},
+		{"split", slices.Repeat([]string{line}, 30), []int{1400, 1400, 200}},
+		{"long line alone", []string{line, long, line}, []int{100, maxDatagramSize + 11, 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packets := datagrams(tt.lines)
+			var sizes []int
+			var joined strings.Builder
+			for _, p := range packets {
+				sizes = append(sizes, len(p))
+				joined.Write(p)
+			}
+			if fmt.Sprint(sizes) != fmt.Sprint(tt.sizes) {
+				t.Errorf("packet sizes = %v, want %v", sizes, tt.sizes)
+			}
+			// Lines are never split across packets
+			for _, p := range packets {
+				if p[len(p)-1] != '\n' {
+					t.Errorf("packet doesn't end with a complete line: %q", p[len(p)-10:])
+				}
+			}
+			if want := strings.Join(tt.lines, "\n"); len(tt.lines) > 0 && joined.String() != want+"\n" {
+				t.Error("the packets don't add up to the lines")
+			}
+		})
+	}
+}
+
+func TestUDPSenderResolvesLazily(t *testing.T) {
+	// .invalid never resolves (RFC 2606)
+	sender, err := newUDPSender("nanoctl.invalid:8125", "StatsD")
+	if err != nil {
+		t.Fatalf("an unresolvable host failed at creation: %v", err)
+	}
+	if err := sender.send(t.Context(), [][]byte{[]byte("a:1|c\n")}); err == nil || !strings.Contains(err.Error(), "resolve") {
+		t.Errorf("send to an unresolvable host: err = %v", err)
+	}
+	if err := sender.close(); err != nil {
+		t.Errorf("close without a connection: %v", err)
+	}
+
+	if _, err := newUDPSender("localhost", "StatsD"); err == nil {
+		t.Error("an address without a port was accepted")
+	}
+}
+
+// listenUDP starts a UDP listener and returns its address and a function
+// returning the next datagram received.
+func listenUDP(t *testing.T) (string, func() string) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn.LocalAddr().String(), func() string {
+		t.Helper()
+		buf := make([]byte, 64<<10)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("no datagram received: %v", err)
+		}
+		return string(buf[:n])
+	}
+}
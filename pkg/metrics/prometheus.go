@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+// PrometheusConfig holds the Prometheus pull endpoint settings.
+type PrometheusConfig struct {
+	Listen string // e.g. ":9101"
+	Path   string // e.g. "/metrics"
+}
+
+// newPrometheusReader creates a reader backed by the OpenTelemetry
+// Prometheus exporter and starts an HTTP listener serving it.
+// Instrument names are translated to Prometheus conventions, e.g.
+// nanoctl.temperature.celsius becomes nanoctl_temperature_celsius.
+func newPrometheusReader(config PrometheusConfig, logger *slog.Logger) (metric.Reader, func(context.Context) error, error) {
+	if config.Path == "" {
+		config.Path = "/metrics"
+	}
+
+	registry := prometheus.NewRegistry()
+	exporter, err := otelprom.New(
+		otelprom.WithRegisterer(registry),
+		// Instrument names already carry their unit (e.g. _celsius, _percent)
+		otelprom.WithoutUnits(),
+		otelprom.WithoutScopeInfo(),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create Prometheus exporter: %w", err)
+	}
+
+	// Listen synchronously so configuration errors (e.g. port in use) are reported at startup
+	listener, err := net.Listen("tcp", config.Listen)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen on %s: %w", config.Listen, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(config.Path, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Prometheus metrics endpoint stopped", "error", err)
+		}
+	}()
+
+	shutdown := func(ctx context.Context) error {
+		err := server.Shutdown(ctx)
+		// Shutdown only closes the listener once Serve has started
+		listener.Close()
+		return err
+	}
+	return exporter, shutdown, nil
+}
@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// statsdExporter sends metrics to a StatsD or DogStatsD server over UDP.
+//
+// Gauges are sent as gauges, counters as the increment since the last
+// interval and histograms as .count and .sum counters plus .min and .max
+// gauges. DogStatsD receives attributes and tags as "|#key:value" tags;
+// plain StatsD has no tags, so attribute values are appended to the
+// metric name (e.g. nanoctl.temperature.read_errors.prometheus) and the
+// global tags are dropped.
+type statsdExporter struct {
+	config OutputConfig
+	tags   map[string]string
+	udp    *udpSender
+}
+
+func newStatsDExporter(config OutputConfig, tags map[string]string) (*statsdExporter, error) {
+	address := config.URL
+	if strings.Contains(address, "://") {
+		u, err := url.Parse(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid StatsD URL: %w", err)
+		}
+		if u.Scheme != "udp" {
+			return nil, fmt.Errorf("unsupported StatsD URL scheme '%s', expected udp", u.Scheme)
+		}
+		address = u.Host
+	}
+
+	udp, err := newUDPSender(address, "StatsD")
+	if err != nil {
+		return nil, err
+	}
+
+	return &statsdExporter{config: config, tags: tags, udp: udp}, nil
+}
+
+// Temporality reports counters and histograms as deltas, which is what
+// StatsD counters expect.
+func (e *statsdExporter) Temporality(kind metric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case metric.InstrumentKindCounter, metric.InstrumentKindObservableCounter, metric.InstrumentKindHistogram:
+		return metricdata.DeltaTemporality
+	default:
+		return metricdata.CumulativeTemporality
+	}
+}
+
+func (e *statsdExporter) Aggregation(kind metric.InstrumentKind) metric.Aggregation {
+	return outputAggregation(kind)
+}
+
+func (e *statsdExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	lines := e.encode(collectPoints(rm))
+	if len(lines) == 0 {
+		return nil
+	}
+	return e.udp.send(ctx, datagrams(lines))
+}
+
+func (e *statsdExporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (e *statsdExporter) Shutdown(context.Context) error {
+	return e.udp.close()
+}
+
+func (e *statsdExporter) dogStatsD() bool {
+	return e.config.Type == OutputDogStatsD
+}
+
+// encode converts points to StatsD lines.
+func (e *statsdExporter) encode(points []point) []string {
+	var lines []string
+	for _, p := range points {
+		name := statsdSanitize(e.config.Prefix + p.name)
+		suffix := ""
+		if e.dogStatsD() {
+			suffix = e.tagSuffix(p.attrs)
+		} else {
+			for _, kv := range p.attrs {
+				name += "." + statsdSanitize(kv.Value.Emit())
+			}
+		}
+
+		switch p.kind {
+		case kindGauge:
+			lines = e.appendGauge(lines, name, p.value, suffix)
+		case kindCounter:
+			lines = append(lines, fmt.Sprintf("%s:%s|c%s", name, statsdFloat(p.value), suffix))
+		case kindHistogram:
+			lines = append(lines,
+				fmt.Sprintf("%s.count:%d|c%s", name, p.count, suffix),
+				fmt.Sprintf("%s.sum:%s|c%s", name, statsdFloat(p.sum), suffix),
+			)
+			if p.hasMin {
+				lines = e.appendGauge(lines, name+".min", p.min, suffix)
+				lines = e.appendGauge(lines, name+".max", p.max, suffix)
+			}
+		}
+	}
+	return lines
+}
+
+// appendGauge appends a gauge line. Plain StatsD treats a leading sign as a
+// relative change, so negative values are sent as a reset to 0 followed by
+// the (relative) negative value.
+func (e *statsdExporter) appendGauge(lines []string, name string, value float64, suffix string) []string {
+	if value < 0 && !e.dogStatsD() {
+		lines = append(lines, fmt.Sprintf("%s:0|g%s", name, suffix))
+	}
+	return append(lines, fmt.Sprintf("%s:%s|g%s", name, statsdFloat(value), suffix))
+}
+
+// tagSuffix builds the DogStatsD tag suffix. Attributes win over the
+// global tags with the same key.
+func (e *statsdExporter) tagSuffix(attrs []attribute.KeyValue) string {
+	tags := maps.Clone(e.tags)
+	for _, kv := range attrs {
+		tags[string(kv.Key)] = kv.Value.Emit()
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(tags))
+	for _, kv := range sortedTags(tags) {
+		parts = append(parts, statsdSanitize(string(kv.Key))+":"+statsdTag(kv.Value.AsString()))
+	}
+	return "|#" + strings.Join(parts, ",")
+}
+
+// statsdSanitize replaces characters with a meaning in the StatsD protocol.
+var statsdSanitize = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_").Replace
+
+// statsdTag replaces characters that would end a DogStatsD tag.
+var statsdTag = strings.NewReplacer("|", "_", ",", "_", "\n", "_").Replace
+
+func statsdFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
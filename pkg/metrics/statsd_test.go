@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestStatsDEncode(t *testing.T) {
+	source := []attribute.KeyValue{attribute.String("source", "prometheus")}
+	tags := map[string]string{"host": "node1", "source": "global"}
+
+	tests := []struct {
+		name   string
+		typ    string
+		prefix string
+		point  point
+		want   []string
+	}{
+		{
+			name:  "gauge",
+			typ:   OutputStatsD,
+			point: point{name: "nanoctl.temperature.celsius", value: 45.5},
+			want:  []string{"nanoctl.temperature.celsius:45.5|g"},
+		},
+		{
+			name:  "negative gauge",
+			typ:   OutputStatsD,
+			point: point{name: "nanoctl.pid.error", value: -2.5},
+			want:  []string{"nanoctl.pid.error:0|g", "nanoctl.pid.error:-2.5|g"},
+		},
+		{
+			name:  "counter with attribute",
+			typ:   OutputStatsD,
+			point: point{name: "nanoctl.temperature.read_errors", kind: kindCounter, value: 3, attrs: source},
+			want:  []string{"nanoctl.temperature.read_errors.prometheus:3|c"},
+		},
+		{
+			name:   "histogram",
+			typ:    OutputStatsD,
+			prefix: "cm5.",
+			point:  point{name: "nanoctl.control_loop.duration", kind: kindHistogram, count: 2, sum: 0.5, min: 0.1, max: 0.4, hasMin: true},
+			want: []string{
+				"cm5.nanoctl.control_loop.duration.count:2|c",
+				"cm5.nanoctl.control_loop.duration.sum:0.5|c",
+				"cm5.nanoctl.control_loop.duration.min:0.1|g",
+				"cm5.nanoctl.control_loop.duration.max:0.4|g",
+			},
+		},
+		{
+			name:  "empty histogram",
+			typ:   OutputStatsD,
+			point: point{name: "nanoctl.control_loop.duration", kind: kindHistogram},
+			want:  []string{"nanoctl.control_loop.duration.count:0|c", "nanoctl.control_loop.duration.sum:0|c"},
+		},
+		{
+			name:  "sanitized",
+			typ:   OutputStatsD,
+			point: point{name: "temp:x|y", value: 1, attrs: []attribute.KeyValue{attribute.String("source", "http://a b@c#d,e")}},
+			want:  []string{"temp_x_y.http_//a_b_c_d_e:1|g"},
+		},
+		{
+			name:  "dogstatsd tags",
+			typ:   OutputDogStatsD,
+			point: point{name: "nanoctl.temperature.read_errors", kind: kindCounter, value: 3, attrs: source},
+			want:  []string{"nanoctl.temperature.read_errors:3|c|#host:node1,source:prometheus"},
+		},
+		{
+			name:  "dogstatsd negative gauge",
+			typ:   OutputDogStatsD,
+			point: point{name: "nanoctl.pid.error", value: -2.5},
+			want:  []string{"nanoctl.pid.error:-2.5|g|#host:node1,source:global"},
+		},
+		{
+			name:  "dogstatsd tag escaping",
+			typ:   OutputDogStatsD,
+			point: point{name: "temp", value: 1, attrs: []attribute.KeyValue{attribute.String("lo:c", "a|b,c\nd:e")}},
+			want:  []string{"temp:1|g|#host:node1,lo_c:a_b_c_d:e,source:global"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &statsdExporter{config: OutputConfig{Type: tt.typ, Prefix: tt.prefix}, tags: tags}
+			got := e.encode([]point{tt.point})
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("encode =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestStatsDUDP(t *testing.T) {
+	tests := []struct {
+		typ  string
+		url  func(addr string) string
+		want string
+	}{
+		{OutputStatsD, func(addr string) string { return addr }, "" +
+			"nanoctl.temperature.celsius:45.5|g\n" +
+			"nanoctl.pid.error:0|g\n" +
+			"nanoctl.pid.error:-2.5|g\n" +
+			"nanoctl.temperature.read_errors.prometheus:3|c\n" +
+			"nanoctl.control_loop.duration.count:2|c\n" +
+			"nanoctl.control_loop.duration.sum:0.5|c\n" +
+			"nanoctl.control_loop.duration.min:0.1|g\n" +
+			"nanoctl.control_loop.duration.max:0.4|g\n"},
+		{OutputDogStatsD, func(addr string) string { return "udp://" + addr }, "" +
+			"nanoctl.temperature.celsius:45.5|g|#host:node1\n" +
+			"nanoctl.pid.error:-2.5|g|#host:node1\n" +
+			"nanoctl.temperature.read_errors:3|c|#host:node1,source:prometheus\n" +
+			"nanoctl.control_loop.duration.count:2|c|#host:node1\n" +
+			"nanoctl.control_loop.duration.sum:0.5|c|#host:node1\n" +
+			"nanoctl.control_loop.duration.min:0.1|g|#host:node1\n" +
+			"nanoctl.control_loop.duration.max:0.4|g|#host:node1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			addr, receive := listenUDP(t)
+			e, err := newStatsDExporter(OutputConfig{Type: tt.typ, URL: tt.url(addr)}, map[string]string{"host": "node1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Shutdown(t.Context())
+
+			if err := e.Export(t.Context(), testMetrics()); err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			if got := receive(); got != tt.want {
+				t.Errorf("datagram =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsDSplitsDatagrams(t *testing.T) {
+	addr, receive := listenUDP(t)
+	e, err := newStatsDExporter(OutputConfig{Type: OutputDogStatsD, URL: addr}, map[string]string{"host": "node1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Shutdown(t.Context())
+
+	if err := e.Export(t.Context(), gauges(200)); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	var packets, lines int
+	for lines < 200 {
+		packet := receive()
+		if len(packet) > maxDatagramSize || !strings.HasSuffix(packet, "\n") {
+			t.Fatalf("invalid packet of %d bytes", len(packet))
+		}
+		packets++
+		lines += strings.Count(packet, "\n")
+	}
+	if lines != 200 || packets < 2 {
+		t.Errorf("received %d lines in %d packets, want 200 lines in several packets", lines, packets)
+	}
+}
+
+func TestStatsDInvalidURL(t *testing.T) {
+	for _, url := range []string{"tcp://statsd:8125", "udp://statsd", "statsd"} {
+		if _, err := newStatsDExporter(OutputConfig{Type: OutputStatsD, URL: url}, nil); err == nil {
+			t.Errorf("%s was accepted", url)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig holds the TLS client settings of the OTLP exporter.
+type TLSConfig struct {
+	CAFile             string // Optional: PEM bundle used to verify the collector
+	CertFile           string // Optional: client certificate (mTLS)
+	KeyFile            string // Optional: client key (mTLS)
+	ServerName         string // Optional: overrides the name used for verification
+	InsecureSkipVerify bool   // Optional: disables server certificate verification
+}
+
+// newTLSConfig builds a tls.Config for endpoint, a URL or host:port, whose
+// CA bundle and client certificate are reloaded when the files change. Files
+// are checked on every handshake, so rotated certificates are picked up on
+// the next (re)connection.
+func newTLSConfig(config TLSConfig, endpoint string, logger *slog.Logger) (*tls.Config, error) {
+	serverName := config.ServerName
+	if serverName == "" {
+		serverName = endpointHost(endpoint)
+	}
+	r := &certReloader{config: config, serverName: serverName, logger: logger}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CertFile != "" {
+		tlsConfig.GetClientCertificate = r.clientCertificate
+	}
+
+	// A static RootCAs pool can't be swapped after the handshake config is
+	// cloned by the exporters, so verification against the reloadable pool
+	// is done in VerifyConnection instead.
+	if config.CAFile != "" && !config.InsecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = r.verifyConnection
+	}
+
+	return tlsConfig, nil
+}
+
+// endpointHost returns the host name or IP address of endpoint, a URL or
+// host:port.
+func endpointHost(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	if host, _, err := net.SplitHostPort(endpoint); err == nil {
+		return host
+	}
+	return endpoint
+}
+
+// certReloader keeps the CA pool and client certificate in sync with the files on disk.
+type certReloader struct {
+	config     TLSConfig
+	serverName string // Name the server certificate must be valid for
+	logger     *slog.Logger
+
+	mu       sync.Mutex
+	modTimes map[string]time.Time
+	pool     *x509.CertPool
+	cert     *tls.Certificate
+}
+
+// load (re)reads the CA bundle and client key pair.
+func (r *certReloader) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, path := range []string{r.config.CAFile, r.config.CertFile, r.config.KeyFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		modTimes[path] = info.ModTime()
+	}
+
+	var pool *x509.CertPool
+	if r.config.CAFile != "" {
+		pem, err := os.ReadFile(r.config.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in CA file %s", r.config.CAFile)
+		}
+	}
+
+	var cert *tls.Certificate
+	if r.config.CertFile != "" || r.config.KeyFile != "" {
+		c, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cert = &c
+	}
+
+	r.mu.Lock()
+	r.modTimes, r.pool, r.cert = modTimes, pool, cert
+	r.mu.Unlock()
+	return nil
+}
+
+// reloadIfChanged reloads the files when any modification time changed.
+// On failure the previous certificates stay in use.
+func (r *certReloader) reloadIfChanged() {
+	r.mu.Lock()
+	changed := false
+	for path, modTime := range r.modTimes {
+		info, err := os.Stat(path)
+		if err == nil && !info.ModTime().Equal(modTime) {
+			changed = true
+			break
+		}
+	}
+	r.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if err := r.load(); err != nil {
+		r.logger.Error("Failed to reload metrics TLS certificates, keeping previous ones", "error", err)
+		return
+	}
+	r.logger.Info("Reloaded metrics TLS certificates")
+}
+
+func (r *certReloader) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.reloadIfChanged()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, nil
+}
+
+func (r *certReloader) verifyConnection(cs tls.ConnectionState) error {
+	r.reloadIfChanged()
+	r.mu.Lock()
+	pool := r.pool
+	r.mu.Unlock()
+
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("collector presented no certificate")
+	}
+
+	// cs.ServerName is the SNI, which is empty for IP addresses
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       r.serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA is a certificate authority issuing server certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string // PEM file holding cert
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "nanoctl test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, file: file}
+}
+
+// issue returns a server certificate for the given DNS names and IP addresses.
+func (ca *testCA) issue(t *testing.T, names []string, ips []net.IP) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "collector"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     names,
+		IPAddresses:  ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestTLSConfigVerifiesServerName(t *testing.T) {
+	ca := newTestCA(t)
+	localhost := []net.IP{net.IPv4(127, 0, 0, 1)}
+
+	tests := []struct {
+		name       string
+		cert       tls.Certificate
+		serverName string
+		wantErr    bool
+	}{
+		{"IP SAN", ca.issue(t, nil, localhost), "", false},
+		{"wrong IP SAN", ca.issue(t, nil, []net.IP{net.IPv4(10, 0, 0, 1)}), "", true},
+		{"DNS SAN only", ca.issue(t, []string{"collector.example"}, nil), "", true},
+		{"server_name", ca.issue(t, []string{"collector.example"}, nil), "collector.example", false},
+		{"wrong server_name", ca.issue(t, nil, localhost), "collector.example", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			server.TLS = &tls.Config{Certificates: []tls.Certificate{tt.cert}}
+			server.Config.ErrorLog = log.New(io.Discard, "", 0)
+			server.StartTLS()
+			defer server.Close()
+
+			tlsConfig, err := newTLSConfig(TLSConfig{CAFile: ca.file, ServerName: tt.serverName}, server.URL, slog.New(slog.DiscardHandler))
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GET %s error = %v, want error: %t", server.URL, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEndpointHost(t *testing.T) {
+	tests := []struct{ endpoint, want string }{
+		{"https://collector.example:4318/v1/metrics", "collector.example"},
+		{"https://10.0.0.5:4318", "10.0.0.5"},
+		{"collector.example:4317", "collector.example"},
+		{"10.0.0.5:4317", "10.0.0.5"},
+		{"[::1]:4317", "::1"},
+		{"collector.example", "collector.example"},
+	}
+	for _, tt := range tests {
+		if got := endpointHost(tt.endpoint); got != tt.want {
+			t.Errorf("endpointHost(%q) = %q, want %q", tt.endpoint, got, tt.want)
+		}
+	}
+}
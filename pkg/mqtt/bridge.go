@@ -0,0 +1,216 @@
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/fan"
+	"github.com/AlejandroPerez92/nanoctl/pkg/gpio"
+	"github.com/AlejandroPerez92/nanoctl/pkg/logging"
+	"github.com/AlejandroPerez92/nanoctl/pkg/metrics"
+)
+
+const (
+	payloadOn   = "ON"
+	payloadOff  = "OFF"
+	payloadAuto = "auto"
+)
+
+// FanController is the part of fan.Monitor the bridge needs.
+type FanController interface {
+	State() fan.State
+	SetOverride(dc float64)
+	ClearOverride()
+}
+
+// PowerController is the part of gpio.Controller the bridge needs.
+type PowerController interface {
+	PowerOn(slot int, boardType gpio.BoardType) error
+	PowerOff(slot int, boardType gpio.BoardType) error
+}
+
+// BridgeConfig holds configuration for the state/command bridge.
+type BridgeConfig struct {
+	PublishInterval time.Duration
+	Slots           []int
+	Discovery       DiscoveryConfig
+	Logger          *slog.Logger // Optional: defaults to slog.Default()
+}
+
+// Bridge publishes fan and slot state and routes commands received over
+// MQTT to the fan monitor and the GPIO controller.
+//
+// Topics (relative to the client prefix):
+//
+//	status                  online/offline (retained)
+//	temperature             current temperature in °C
+//	fan/duty                current duty cycle in %
+//	fan/override            "auto" or the forced duty cycle
+//	fan/override/set        command: 0-100 or "auto"
+//	slot/<n>/power          last commanded power state: ON/OFF (retained)
+//	slot/<n>/power/set      command: ON or OFF
+type Bridge struct {
+	client *Client
+	fan    FanController
+	power  PowerController
+	config BridgeConfig
+	logger *slog.Logger
+
+	// powerMu serialises GPIO operations; each one holds a line for a second or more.
+	powerMu sync.Mutex
+
+	// overrides carries the override state to acknowledge on fan/override.
+	// Run publishes it: waiting for a publish inside a command handler
+	// would block the MQTT router. Only the latest state is kept.
+	overrides chan string
+}
+
+// NewBridge creates a new MQTT bridge.
+func NewBridge(client *Client, fan FanController, power PowerController, config BridgeConfig) *Bridge {
+	if config.PublishInterval <= 0 {
+		config.PublishInterval = 10 * time.Second
+	}
+	return &Bridge{
+		client:    client,
+		fan:       fan,
+		power:     power,
+		config:    config,
+		logger:    logging.OrDefault(config.Logger).With("component", "mqtt"),
+		overrides: make(chan string, 1),
+	}
+}
+
+// Run subscribes to command topics, publishes discovery payloads and then
+// publishes state every PublishInterval until ctx is cancelled.
+func (b *Bridge) Run(ctx context.Context) error {
+	if err := b.client.Subscribe(b.client.Topic("fan", "override", "set"), b.handleOverride); err != nil {
+		return fmt.Errorf("failed to subscribe to fan override commands: %w", err)
+	}
+	for _, slot := range b.config.Slots {
+		slot := slot
+		topic := b.client.Topic("slot", strconv.Itoa(slot), "power", "set")
+		if err := b.client.Subscribe(topic, func(payload []byte) { b.handlePower(ctx, slot, payload) }); err != nil {
+			return fmt.Errorf("failed to subscribe to slot %d power commands: %w", slot, err)
+		}
+	}
+
+	if b.config.Discovery.Enabled {
+		if err := b.publishDiscovery(ctx); err != nil {
+			b.logger.Error("Failed to publish discovery", "error", err)
+		}
+	}
+
+	ticker := time.NewTicker(b.config.PublishInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := b.publishState(ctx); err != nil {
+				b.logger.Error("Failed to publish state", "error", err)
+			}
+		case override := <-b.overrides:
+			if err := b.client.Publish(ctx, b.client.Topic("fan", "override"), true, override); err != nil {
+				b.logger.Error("Failed to publish fan override", "error", err)
+			}
+		}
+	}
+}
+
+func (b *Bridge) publishState(ctx context.Context) error {
+	state := b.fan.State()
+	if state.UpdatedAt.IsZero() {
+		// No control loop iteration yet
+		return nil
+	}
+
+	if err := b.client.Publish(ctx, b.client.Topic("temperature"), false, formatFloat(state.Temperature)); err != nil {
+		return err
+	}
+	if err := b.client.Publish(ctx, b.client.Topic("fan", "duty"), false, formatFloat(state.DutyCycle)); err != nil {
+		return err
+	}
+	override := payloadAuto
+	if state.Override {
+		override = formatFloat(state.DutyCycle)
+	}
+	return b.client.Publish(ctx, b.client.Topic("fan", "override"), true, override)
+}
+
+func (b *Bridge) handleOverride(payload []byte) {
+	value := strings.TrimSpace(string(payload))
+	if strings.EqualFold(value, payloadAuto) || value == "" {
+		b.fan.ClearOverride()
+		b.logger.Info("Fan override cleared", metrics.Event(metrics.EventOverride), "origin", "mqtt")
+		b.ackOverride(payloadAuto)
+		return
+	}
+
+	dc, err := strconv.ParseFloat(value, 64)
+	if err != nil || dc < 0 || dc > 100 {
+		b.logger.Warn("Ignoring invalid fan override (expected 0-100 or 'auto')", "value", value)
+		return
+	}
+	b.fan.SetOverride(dc)
+	b.logger.Info("Fan override set", metrics.Event(metrics.EventOverride), "origin", "mqtt", "duty_cycle", dc)
+	b.ackOverride(formatFloat(dc))
+}
+
+// ackOverride queues state for Run to publish on fan/override, replacing
+// a state that hasn't been published yet.
+func (b *Bridge) ackOverride(state string) {
+	for {
+		select {
+		case b.overrides <- state:
+			return
+		default:
+		}
+		select {
+		case <-b.overrides:
+		default:
+		}
+	}
+}
+
+func (b *Bridge) handlePower(ctx context.Context, slot int, payload []byte) {
+	command := strings.ToUpper(strings.TrimSpace(string(payload)))
+	if command != payloadOn && command != payloadOff {
+		b.logger.Warn("Ignoring invalid power command (expected ON or OFF)", "command", command, "slot", slot)
+		return
+	}
+
+	// GPIO pulses block for a second or more; don't stall the MQTT router.
+	go func() {
+		b.powerMu.Lock()
+		defer b.powerMu.Unlock()
+
+		var err error
+		if command == payloadOn {
+			err = b.power.PowerOn(slot, gpio.BoardCM5)
+		} else {
+			err = b.power.PowerOff(slot, gpio.BoardCM5)
+		}
+		logger := b.logger.With(metrics.Event(metrics.EventPower), "origin", "mqtt", "slot", slot, "action", strings.ToLower(command))
+		if err != nil {
+			logger.Error("Power operation failed", "error", err)
+			return
+		}
+		logger.Info("Power operation sent")
+
+		topic := b.client.Topic("slot", strconv.Itoa(slot), "power")
+		if err := b.client.Publish(ctx, topic, true, command); err != nil {
+			b.logger.Error("Failed to publish slot state", "slot", slot, "error", err)
+		}
+	}()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
@@ -0,0 +1,193 @@
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/fan"
+	"github.com/AlejandroPerez92/nanoctl/pkg/gpio"
+)
+
+// fakeFan records the overrides set by the bridge.
+type fakeFan struct {
+	mu    sync.Mutex
+	state fan.State
+}
+
+func (f *fakeFan) State() fan.State {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+func (f *fakeFan) SetOverride(dc float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state.Override, f.state.DutyCycle = true, dc
+}
+
+func (f *fakeFan) ClearOverride() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state.Override = false
+}
+
+// fakePower records the power commands sent by the bridge.
+type fakePower struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+func (p *fakePower) PowerOn(slot int, _ gpio.BoardType) error {
+	p.record("on", slot)
+	return nil
+}
+
+func (p *fakePower) PowerOff(slot int, _ gpio.BoardType) error {
+	p.record("off", slot)
+	return nil
+}
+
+func (p *fakePower) record(command string, slot int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.commands = append(p.commands, fmt.Sprintf("%s %d", command, slot))
+}
+
+func (p *fakePower) sent() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.commands...)
+}
+
+// runBridge starts a bridge for slots 1 and 3 and waits until it has
+// subscribed to its command topics.
+func runBridge(t *testing.T, broker *testBroker, fan *fakeFan, power *fakePower, discovery DiscoveryConfig) *Bridge {
+	t.Helper()
+	client := newTestClient(t, broker.url())
+	bridge := NewBridge(client, fan, power, BridgeConfig{
+		PublishInterval: 20 * time.Millisecond,
+		Slots:           []int{1, 3},
+		Discovery:       discovery,
+		Logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- bridge.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+
+	for _, topic := range []string{"nanoctl/test/fan/override/set", "nanoctl/test/slot/1/power/set", "nanoctl/test/slot/3/power/set"} {
+		waitFor(t, "a subscription to "+topic, func() bool {
+			return len(broker.Topics.Subscribers(topic).Subscriptions) > 0
+		})
+	}
+	return bridge
+}
+
+func TestBridgeOverrideCommands(t *testing.T) {
+	broker := newTestBroker(t, "")
+	fan := &fakeFan{}
+	runBridge(t, broker, fan, &fakePower{}, DiscoveryConfig{})
+
+	tests := []struct {
+		payload  string
+		override bool
+		duty     float64
+		state    string // Published on fan/override
+	}{
+		{"42", true, 42, "42.00"},
+		{" 100 ", true, 100, "100.00"},
+		{"auto", false, 100, payloadAuto},
+		{"0", true, 0, "0.00"},
+		{"AUTO", false, 0, payloadAuto},
+		{"55.5", true, 55.5, "55.50"},
+		{"", false, 55.5, payloadAuto},
+	}
+	for _, tt := range tests {
+		broker.publish(t, "nanoctl/test/fan/override/set", tt.payload)
+		if msg := broker.waitMessage(t, "nanoctl/test/fan/override", tt.state); !msg.retained {
+			t.Errorf("%q: override state is not retained", tt.payload)
+		}
+		if state := fan.State(); state.Override != tt.override || state.DutyCycle != tt.duty {
+			t.Errorf("%q: override = %v at %.2f%%, want %v at %.2f%%", tt.payload, state.Override, state.DutyCycle, tt.override, tt.duty)
+		}
+	}
+}
+
+func TestBridgeIgnoresInvalidOverride(t *testing.T) {
+	broker := newTestBroker(t, "")
+	fan := &fakeFan{}
+	runBridge(t, broker, fan, &fakePower{}, DiscoveryConfig{})
+
+	for _, payload := range []string{"-1", "101", "fast", "NaN"} {
+		broker.publish(t, "nanoctl/test/fan/override/set", payload)
+	}
+	// Commands are handled in order, so once this one is applied the invalid ones were ignored
+	broker.publish(t, "nanoctl/test/fan/override/set", "30")
+	broker.waitMessage(t, "nanoctl/test/fan/override", "30.00")
+
+	if state := fan.State(); state.DutyCycle != 30 {
+		t.Errorf("duty cycle = %.2f, want 30", state.DutyCycle)
+	}
+}
+
+func TestBridgePowerCommands(t *testing.T) {
+	broker := newTestBroker(t, "")
+	power := &fakePower{}
+	runBridge(t, broker, &fakeFan{}, power, DiscoveryConfig{})
+
+	broker.publish(t, "nanoctl/test/slot/1/power/set", "on")
+	if msg := broker.waitMessage(t, "nanoctl/test/slot/1/power", payloadOn); !msg.retained {
+		t.Error("slot power state is not retained")
+	}
+	broker.publish(t, "nanoctl/test/slot/3/power/set", "OFF")
+	broker.waitMessage(t, "nanoctl/test/slot/3/power", payloadOff)
+
+	// Invalid commands and slots that aren't exposed are ignored
+	broker.publish(t, "nanoctl/test/slot/1/power/set", "reboot")
+	broker.publish(t, "nanoctl/test/slot/2/power/set", "ON")
+	broker.publish(t, "nanoctl/test/slot/1/power/set", "OFF")
+	broker.waitMessage(t, "nanoctl/test/slot/1/power", payloadOff)
+
+	want := []string{"on 1", "off 3", "off 1"}
+	if got := power.sent(); !slices.Equal(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+	if _, ok := broker.lastMessage("nanoctl/test/slot/2/power"); ok {
+		t.Error("published a state for slot 2, which isn't exposed")
+	}
+}
+
+func TestBridgePublishesState(t *testing.T) {
+	broker := newTestBroker(t, "")
+	fan := &fakeFan{state: fan.State{Temperature: 48.25, DutyCycle: 37.5, UpdatedAt: time.Now()}}
+	runBridge(t, broker, fan, &fakePower{}, DiscoveryConfig{})
+
+	if msg := broker.waitMessage(t, "nanoctl/test/temperature", "48.25"); msg.retained {
+		t.Error("temperature is retained")
+	}
+	broker.waitMessage(t, "nanoctl/test/fan/duty", "37.50")
+	broker.waitMessage(t, "nanoctl/test/fan/override", payloadAuto)
+}
+
+func TestBridgeWaitsForFirstIteration(t *testing.T) {
+	broker := newTestBroker(t, "")
+	runBridge(t, broker, &fakeFan{}, &fakePower{}, DiscoveryConfig{})
+
+	time.Sleep(100 * time.Millisecond)
+	if msg, ok := broker.lastMessage("nanoctl/test/temperature"); ok {
+		t.Errorf("published temperature %q before the first control loop iteration", msg.payload)
+	}
+}
@@ -0,0 +1,151 @@
+package mqtt
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// testBroker is an embedded MQTT broker that records the messages it routes.
+type testBroker struct {
+	*mochi.Server
+	addr string
+
+	mu       sync.Mutex
+	messages []message
+}
+
+// message is a message seen by the broker.
+type message struct {
+	topic    string
+	payload  string
+	retained bool
+}
+
+// newTestBroker starts a broker on addr, or on a free port if addr is empty.
+// It is closed when the test ends.
+func newTestBroker(t *testing.T, addr string) *testBroker {
+	t.Helper()
+	if addr == "" {
+		addr = freeAddr(t)
+	}
+
+	server := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddListener(listeners.NewTCP(listeners.Config{ID: "tcp", Address: addr})); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &testBroker{Server: server, addr: addr}
+	err := server.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.messages = append(b.messages, message{topic: pk.TopicName, payload: string(pk.Payload), retained: pk.FixedHeader.Retain})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return b
+}
+
+// url returns the address clients connect to.
+func (b *testBroker) url() string {
+	return "tcp://" + b.addr
+}
+
+// publish sends payload to topic as if another client had published it.
+func (b *testBroker) publish(t *testing.T, topic, payload string) {
+	t.Helper()
+	if err := b.Publish(topic, []byte(payload), false, 1); err != nil {
+		t.Fatalf("publishing to %s: %v", topic, err)
+	}
+}
+
+// waitMessage waits for the last message on topic with the given payload and
+// returns it.
+func (b *testBroker) waitMessage(t *testing.T, topic, payload string) message {
+	t.Helper()
+	var last message
+	waitFor(t, topic+" = "+payload, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i := len(b.messages) - 1; i >= 0; i-- {
+			if b.messages[i].topic == topic {
+				last = b.messages[i]
+				return last.payload == payload
+			}
+		}
+		return false
+	})
+	return last
+}
+
+// lastMessage returns the last message on topic, if any.
+func (b *testBroker) lastMessage(topic string) (message, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := len(b.messages) - 1; i >= 0; i-- {
+		if b.messages[i].topic == topic {
+			return b.messages[i], true
+		}
+	}
+	return message{}, false
+}
+
+// newTestClient connects a client with the topic prefix "nanoctl/test".
+func newTestClient(t *testing.T, broker string) *Client {
+	t.Helper()
+	client, err := NewClient(Config{
+		Broker:         broker,
+		ClientID:       t.Name(),
+		TopicPrefix:    "nanoctl/test",
+		ConnectTimeout: 2 * time.Second,
+		RetryInterval:  50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// freeAddr returns a local address nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// waitFor polls cond until it holds, failing the test after 5 seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,189 @@
+// Package mqtt integrates nanoctl with an MQTT broker. It provides a client
+// usable as a temperature.Subscriber, a bridge that publishes fan and slot
+// state and routes commands, and Home Assistant discovery payloads.
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	payloadOnline  = "online"
+	payloadOffline = "offline"
+)
+
+// Config holds the broker connection settings.
+type Config struct {
+	Broker         string // e.g. "tcp://localhost:1883"
+	ClientID       string
+	Username       string
+	Password       string
+	TopicPrefix    string // e.g. "nanoctl/node1"
+	ConnectTimeout time.Duration
+	RetryInterval  time.Duration // Between connection attempts while the broker is unreachable
+	Timeout        time.Duration // How long Publish, Subscribe and Unsubscribe wait for the broker
+}
+
+// Client is a thin wrapper around the paho client that keeps track of
+// subscriptions so they are restored after a reconnect.
+type Client struct {
+	client  paho.Client
+	prefix  string
+	timeout time.Duration
+
+	mu   sync.Mutex
+	subs map[string]func([]byte)
+}
+
+// NewClient connects to the broker, waiting up to ConnectTimeout for the
+// first connection. If the broker can't be reached by then, the client keeps
+// retrying in the background; use Connected to tell. The availability topic
+// (<prefix>/status) is set to "online" on connect and to "offline" through
+// the last will when the connection is lost.
+func NewClient(config Config) (*Client, error) {
+	if config.Broker == "" {
+		return nil, fmt.Errorf("mqtt broker is required")
+	}
+	if config.ConnectTimeout == 0 {
+		config.ConnectTimeout = 10 * time.Second
+	}
+	if config.RetryInterval == 0 {
+		config.RetryInterval = 10 * time.Second
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	c := &Client{
+		prefix:  strings.TrimSuffix(config.TopicPrefix, "/"),
+		timeout: config.Timeout,
+		subs:    make(map[string]func([]byte)),
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(config.ClientID).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(config.RetryInterval).
+		SetConnectTimeout(config.ConnectTimeout).
+		SetWill(c.Topic("status"), payloadOffline, 1, true).
+		SetOnConnectHandler(c.onConnect)
+	if config.Username != "" {
+		opts.SetUsername(config.Username)
+		opts.SetPassword(config.Password)
+	}
+
+	c.client = paho.NewClient(opts)
+	// With ConnectRetry the token only completes once connected
+	c.client.Connect().WaitTimeout(config.ConnectTimeout)
+	return c, nil
+}
+
+// Connected reports whether the client is currently connected to the broker.
+func (c *Client) Connected() bool {
+	return c.client.IsConnectionOpen()
+}
+
+// onConnect announces availability and restores subscriptions.
+func (c *Client) onConnect(client paho.Client) {
+	client.Publish(c.Topic("status"), 1, true, payloadOnline)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for topic, handler := range c.subs {
+		client.Subscribe(topic, 1, wrapHandler(handler))
+	}
+}
+
+// Topic joins parts onto the configured topic prefix.
+func (c *Client) Topic(parts ...string) string {
+	if c.prefix == "" {
+		return strings.Join(parts, "/")
+	}
+	return c.prefix + "/" + strings.Join(parts, "/")
+}
+
+// Subscribe registers handler for messages on topic. While disconnected,
+// the subscription is made on the next connect. It implements
+// temperature.Subscriber.
+func (c *Client) Subscribe(topic string, handler func(payload []byte)) error {
+	c.mu.Lock()
+	c.subs[topic] = handler
+	c.mu.Unlock()
+	if !c.Connected() {
+		return nil
+	}
+
+	return c.wait(context.Background(), c.client.Subscribe(topic, 1, wrapHandler(handler)))
+}
+
+// Unsubscribe removes the subscription to topic. It implements temperature.Subscriber.
+func (c *Client) Unsubscribe(topic string) error {
+	c.mu.Lock()
+	delete(c.subs, topic)
+	c.mu.Unlock()
+	if !c.Connected() {
+		return nil
+	}
+
+	return c.wait(context.Background(), c.client.Unsubscribe(topic))
+}
+
+// Publish sends payload to topic and waits until the broker has it, up to
+// the configured Timeout or until ctx is done. While disconnected, the
+// message is sent when the client reconnects, also after Publish gave up.
+// Strings and byte slices are sent as is, anything else is encoded as JSON.
+func (c *Client) Publish(ctx context.Context, topic string, retained bool, payload any) error {
+	var data []byte
+	switch p := payload.(type) {
+	case string:
+		data = []byte(p)
+	case []byte:
+		data = p
+	default:
+		var err error
+		if data, err = json.Marshal(p); err != nil {
+			return fmt.Errorf("failed to encode payload for %s: %w", topic, err)
+		}
+	}
+
+	return c.wait(ctx, c.client.Publish(topic, 1, retained, data))
+}
+
+// wait waits for token to complete, up to the configured Timeout or until
+// ctx is done.
+func (c *Client) wait(ctx context.Context, token paho.Token) error {
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return fmt.Errorf("no response from the broker within %s", c.timeout)
+	}
+}
+
+// Close marks the client offline and disconnects from the broker.
+func (c *Client) Close() {
+	if c.Connected() {
+		_ = c.Publish(context.Background(), c.Topic("status"), true, payloadOffline)
+	}
+	c.client.Disconnect(250)
+}
+
+func wrapHandler(handler func([]byte)) paho.MessageHandler {
+	return func(_ paho.Client, msg paho.Message) {
+		handler(msg.Payload())
+	}
+}
@@ -0,0 +1,152 @@
+package mqtt
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClientAvailability(t *testing.T) {
+	broker := newTestBroker(t, "")
+	client := newTestClient(t, broker.url())
+
+	if !client.Connected() {
+		t.Fatal("client is not connected")
+	}
+	if msg := broker.waitMessage(t, "nanoctl/test/status", payloadOnline); !msg.retained {
+		t.Error("online status is not retained")
+	}
+
+	client.Close()
+	if msg := broker.waitMessage(t, "nanoctl/test/status", payloadOffline); !msg.retained {
+		t.Error("offline status is not retained")
+	}
+}
+
+func TestClientConnectRetry(t *testing.T) {
+	addr := freeAddr(t)
+	start := time.Now()
+	client, err := NewClient(Config{
+		Broker:         "tcp://" + addr,
+		ClientID:       t.Name(),
+		TopicPrefix:    "nanoctl/test",
+		ConnectTimeout: 100 * time.Millisecond,
+		RetryInterval:  50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewClient with the broker down: %v", err)
+	}
+	t.Cleanup(client.Close)
+	if client.Connected() {
+		t.Fatal("client reports connected with the broker down")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("NewClient blocked for %s", elapsed)
+	}
+
+	// Subscriptions made while disconnected are made on connect
+	received := make(chan string, 1)
+	if err := client.Subscribe("sensors/cpu", func(payload []byte) { received <- string(payload) }); err != nil {
+		t.Fatalf("Subscribe while disconnected: %v", err)
+	}
+
+	broker := newTestBroker(t, addr)
+	waitFor(t, "the client to connect", client.Connected)
+	broker.waitMessage(t, "nanoctl/test/status", payloadOnline)
+
+	// The subscription is restored asynchronously after the connect
+	waitFor(t, "the subscription", func() bool {
+		broker.publish(t, "sensors/cpu", "42")
+		select {
+		case payload := <-received:
+			if payload != "42" {
+				t.Errorf("received %q, want 42", payload)
+			}
+			return true
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	})
+}
+
+func TestClientPublish(t *testing.T) {
+	broker := newTestBroker(t, "")
+	client := newTestClient(t, broker.url())
+
+	tests := []struct {
+		name     string
+		payload  any
+		retained bool
+		want     string
+	}{
+		{"string", "auto", false, "auto"},
+		{"bytes", []byte("ON"), true, "ON"},
+		{"json", map[string]float64{"temperature": 45.5}, false, `{"temperature":45.5}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := client.Topic("publish", tt.name)
+			if err := client.Publish(t.Context(), topic, tt.retained, tt.payload); err != nil {
+				t.Fatal(err)
+			}
+			if msg := broker.waitMessage(t, topic, tt.want); msg.retained != tt.retained {
+				t.Errorf("retained = %v, want %v", msg.retained, tt.retained)
+			}
+		})
+	}
+}
+
+func TestClientPublishTimeout(t *testing.T) {
+	client, err := NewClient(Config{
+		Broker:         "tcp://" + freeAddr(t),
+		ClientID:       t.Name(),
+		TopicPrefix:    "nanoctl/test",
+		ConnectTimeout: 100 * time.Millisecond,
+		RetryInterval:  50 * time.Millisecond,
+		Timeout:        200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+
+	cancelled, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"timeout", t.Context()},
+		{"cancelled", cancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The broker is down, so the publish never completes
+			start := time.Now()
+			if err := client.Publish(tt.ctx, client.Topic("fan", "duty"), false, "50"); err == nil {
+				t.Error("Publish with the broker down succeeded")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Publish blocked for %s, want at most the timeout", elapsed)
+			}
+		})
+	}
+}
+
+func TestClientTopic(t *testing.T) {
+	tests := []struct {
+		prefix string
+		parts  []string
+		want   string
+	}{
+		{"nanoctl/node1", []string{"fan", "duty"}, "nanoctl/node1/fan/duty"},
+		{"", []string{"fan", "override", "set"}, "fan/override/set"},
+	}
+	for _, tt := range tests {
+		c := &Client{prefix: tt.prefix}
+		if got := c.Topic(tt.parts...); got != tt.want {
+			t.Errorf("Topic(%q) with prefix %q = %q, want %q", tt.parts, tt.prefix, got, tt.want)
+		}
+	}
+}
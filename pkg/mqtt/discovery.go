@@ -0,0 +1,141 @@
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// DiscoveryConfig holds Home Assistant MQTT discovery settings.
+type DiscoveryConfig struct {
+	Enabled bool
+	Prefix  string // Home Assistant discovery prefix, usually "homeassistant"
+	NodeID  string // Unique node identifier, e.g. the hostname
+	Version string // Reported as the device software version
+}
+
+// discoveryDevice groups all entities under one Home Assistant device.
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+}
+
+// discoveryPayload is the subset of Home Assistant discovery fields used by nanoctl.
+type discoveryPayload struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	ObjectID          string          `json:"object_id,omitempty"`
+	Device            discoveryDevice `json:"device"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	StateTopic        string          `json:"state_topic,omitempty"`
+	CommandTopic      string          `json:"command_topic,omitempty"`
+	DeviceClass       string          `json:"device_class,omitempty"`
+	StateClass        string          `json:"state_class,omitempty"`
+	UnitOfMeasurement string          `json:"unit_of_measurement,omitempty"`
+	Icon              string          `json:"icon,omitempty"`
+	Min               *float64        `json:"min,omitempty"`
+	Max               *float64        `json:"max,omitempty"`
+	Step              float64         `json:"step,omitempty"`
+	Mode              string          `json:"mode,omitempty"`
+	PayloadOn         string          `json:"payload_on,omitempty"`
+	PayloadOff        string          `json:"payload_off,omitempty"`
+	PayloadPress      string          `json:"payload_press,omitempty"`
+}
+
+// discoveryEntity pairs an entity's component type with its payload.
+type discoveryEntity struct {
+	component string // sensor, number, button, switch
+	objectID  string
+	payload   discoveryPayload
+}
+
+// discoveryEntities builds the Home Assistant entities exposed by the bridge.
+func (b *Bridge) discoveryEntities() []discoveryEntity {
+	node := b.config.Discovery.NodeID
+	device := discoveryDevice{
+		Identifiers:  []string{"nanoctl_" + node},
+		Name:         "NanoCtl " + node,
+		Manufacturer: "NanoCtl",
+		Model:        "Nano Cluster",
+		SWVersion:    b.config.Discovery.Version,
+	}
+	availability := b.client.Topic("status")
+
+	entity := func(component, objectID, name string) discoveryEntity {
+		return discoveryEntity{
+			component: component,
+			objectID:  objectID,
+			payload: discoveryPayload{
+				Name:              name,
+				UniqueID:          "nanoctl_" + node + "_" + objectID,
+				ObjectID:          "nanoctl_" + node + "_" + objectID,
+				Device:            device,
+				AvailabilityTopic: availability,
+			},
+		}
+	}
+
+	temp := entity("sensor", "temperature", "Temperature")
+	temp.payload.StateTopic = b.client.Topic("temperature")
+	temp.payload.DeviceClass = "temperature"
+	temp.payload.StateClass = "measurement"
+	temp.payload.UnitOfMeasurement = "°C"
+
+	duty := entity("sensor", "fan_duty", "Fan duty cycle")
+	duty.payload.StateTopic = b.client.Topic("fan", "duty")
+	duty.payload.StateClass = "measurement"
+	duty.payload.UnitOfMeasurement = "%"
+	duty.payload.Icon = "mdi:fan"
+
+	minDuty, maxDuty := 0.0, 100.0
+	override := entity("number", "fan_override", "Fan override")
+	// The slider follows the actual duty cycle; "auto" is set through the button below.
+	override.payload.StateTopic = b.client.Topic("fan", "duty")
+	override.payload.CommandTopic = b.client.Topic("fan", "override", "set")
+	override.payload.UnitOfMeasurement = "%"
+	override.payload.Min = &minDuty
+	override.payload.Max = &maxDuty
+	override.payload.Step = 1
+	override.payload.Mode = "slider"
+	override.payload.Icon = "mdi:fan-alert"
+
+	auto := entity("button", "fan_auto", "Fan automatic control")
+	auto.payload.CommandTopic = b.client.Topic("fan", "override", "set")
+	auto.payload.PayloadPress = payloadAuto
+	auto.payload.Icon = "mdi:fan-auto"
+
+	entities := []discoveryEntity{temp, duty, override, auto}
+
+	for _, slot := range b.config.Slots {
+		s := strconv.Itoa(slot)
+		power := entity("switch", "slot_"+s+"_power", fmt.Sprintf("Slot %d power", slot))
+		power.payload.StateTopic = b.client.Topic("slot", s, "power")
+		power.payload.CommandTopic = b.client.Topic("slot", s, "power", "set")
+		power.payload.PayloadOn = payloadOn
+		power.payload.PayloadOff = payloadOff
+		power.payload.Icon = "mdi:server"
+		entities = append(entities, power)
+	}
+
+	return entities
+}
+
+// publishDiscovery publishes retained discovery payloads to
+// <prefix>/<component>/nanoctl_<node>/<object_id>/config.
+func (b *Bridge) publishDiscovery(ctx context.Context) error {
+	prefix := b.config.Discovery.Prefix
+	if prefix == "" {
+		prefix = "homeassistant"
+	}
+
+	for _, e := range b.discoveryEntities() {
+		topic := fmt.Sprintf("%s/%s/nanoctl_%s/%s/config", prefix, e.component, b.config.Discovery.NodeID, e.objectID)
+		if err := b.client.Publish(ctx, topic, true, e.payload); err != nil {
+			return fmt.Errorf("failed to publish discovery for %s: %w", e.objectID, err)
+		}
+	}
+	return nil
+}
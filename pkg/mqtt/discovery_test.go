@@ -0,0 +1,125 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/fan"
+)
+
+func TestDiscoveryPayloads(t *testing.T) {
+	broker := newTestBroker(t, "")
+	runBridge(t, broker, &fakeFan{}, &fakePower{}, DiscoveryConfig{Enabled: true, NodeID: "node1", Version: "1.2.3"})
+
+	tests := []struct {
+		topic string
+		want  discoveryPayload
+	}{
+		{"homeassistant/sensor/nanoctl_node1/temperature/config", discoveryPayload{
+			Name:              "Temperature",
+			UniqueID:          "nanoctl_node1_temperature",
+			StateTopic:        "nanoctl/test/temperature",
+			DeviceClass:       "temperature",
+			StateClass:        "measurement",
+			UnitOfMeasurement: "°C",
+		}},
+		{"homeassistant/sensor/nanoctl_node1/fan_duty/config", discoveryPayload{
+			Name:              "Fan duty cycle",
+			UniqueID:          "nanoctl_node1_fan_duty",
+			StateTopic:        "nanoctl/test/fan/duty",
+			StateClass:        "measurement",
+			UnitOfMeasurement: "%",
+		}},
+		{"homeassistant/number/nanoctl_node1/fan_override/config", discoveryPayload{
+			Name:              "Fan override",
+			UniqueID:          "nanoctl_node1_fan_override",
+			StateTopic:        "nanoctl/test/fan/duty",
+			CommandTopic:      "nanoctl/test/fan/override/set",
+			UnitOfMeasurement: "%",
+		}},
+		{"homeassistant/button/nanoctl_node1/fan_auto/config", discoveryPayload{
+			Name:         "Fan automatic control",
+			UniqueID:     "nanoctl_node1_fan_auto",
+			CommandTopic: "nanoctl/test/fan/override/set",
+			PayloadPress: payloadAuto,
+		}},
+		{"homeassistant/switch/nanoctl_node1/slot_1_power/config", discoveryPayload{
+			Name:         "Slot 1 power",
+			UniqueID:     "nanoctl_node1_slot_1_power",
+			StateTopic:   "nanoctl/test/slot/1/power",
+			CommandTopic: "nanoctl/test/slot/1/power/set",
+			PayloadOn:    payloadOn,
+			PayloadOff:   payloadOff,
+		}},
+		{"homeassistant/switch/nanoctl_node1/slot_3_power/config", discoveryPayload{
+			Name:         "Slot 3 power",
+			UniqueID:     "nanoctl_node1_slot_3_power",
+			StateTopic:   "nanoctl/test/slot/3/power",
+			CommandTopic: "nanoctl/test/slot/3/power/set",
+			PayloadOn:    payloadOn,
+			PayloadOff:   payloadOff,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want.UniqueID, func(t *testing.T) {
+			var msg message
+			waitFor(t, tt.topic, func() bool {
+				var ok bool
+				msg, ok = broker.lastMessage(tt.topic)
+				return ok
+			})
+			if !msg.retained {
+				t.Error("discovery payload is not retained")
+			}
+
+			var got discoveryPayload
+			if err := json.Unmarshal([]byte(msg.payload), &got); err != nil {
+				t.Fatalf("invalid payload %s: %v", msg.payload, err)
+			}
+			if got.AvailabilityTopic != "nanoctl/test/status" {
+				t.Errorf("availability_topic = %q", got.AvailabilityTopic)
+			}
+			if got.ObjectID != tt.want.UniqueID {
+				t.Errorf("object_id = %q, want %q", got.ObjectID, tt.want.UniqueID)
+			}
+			if got.Device.Identifiers[0] != "nanoctl_node1" || got.Device.SWVersion != "1.2.3" {
+				t.Errorf("device = %+v", got.Device)
+			}
+
+			got.AvailabilityTopic, got.ObjectID, got.Device = "", "", discoveryDevice{}
+			got.Icon, got.Min, got.Max, got.Step, got.Mode = "", nil, nil, 0, ""
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("payload = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoveryOverrideRange(t *testing.T) {
+	bridge := &Bridge{client: &Client{prefix: "nanoctl/test"}, config: BridgeConfig{Discovery: DiscoveryConfig{NodeID: "node1"}}}
+	for _, e := range bridge.discoveryEntities() {
+		if e.objectID != "fan_override" {
+			continue
+		}
+		p := e.payload
+		if p.Min == nil || *p.Min != 0 || p.Max == nil || *p.Max != 100 || p.Step != 1 || p.Mode != "slider" {
+			t.Errorf("fan override range = %v-%v step %v mode %q, want a 0-100 slider", p.Min, p.Max, p.Step, p.Mode)
+		}
+		return
+	}
+	t.Error("no fan override entity")
+}
+
+func TestDiscoveryDisabled(t *testing.T) {
+	broker := newTestBroker(t, "")
+	fan := &fakeFan{state: fan.State{Temperature: 45, UpdatedAt: time.Now()}}
+	runBridge(t, broker, fan, &fakePower{}, DiscoveryConfig{NodeID: "node1"})
+
+	// Discovery is published before the first state
+	broker.waitMessage(t, "nanoctl/test/temperature", "45.00")
+	if _, ok := broker.lastMessage("homeassistant/sensor/nanoctl_node1/temperature/config"); ok {
+		t.Error("discovery payload published while discovery is disabled")
+	}
+}
@@ -0,0 +1,77 @@
+package mqtt
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlejandroPerez92/nanoctl/pkg/temperature"
+)
+
+// The MQTT temperature source is tested here, with the client it runs on.
+func TestMQTTSource(t *testing.T) {
+	broker := newTestBroker(t, "")
+	client := newTestClient(t, broker.url())
+
+	tests := []struct {
+		name     string
+		config   temperature.MQTTConfig
+		payloads []string // The last one is the reading
+		want     float64
+		err      string
+	}{
+		{name: "number", payloads: []string{"45.5"}, want: 45.5},
+		{name: "quoted", payloads: []string{`"45.5"`}, want: 45.5},
+		{name: "millicelsius", config: temperature.MQTTConfig{Unit: "millicelsius"}, payloads: []string{"52300\n"}, want: 52.3},
+		{name: "selector", config: temperature.MQTTConfig{Selector: "$.sensors[1].value"},
+			payloads: []string{`{"sensors":[{"value":30},{"value":"61.5"}]}`}, want: 61.5},
+		{name: "latest", payloads: []string{"40", "41", "42"}, want: 42},
+		{name: "invalid", payloads: []string{"hot"}, err: "invalid message"},
+		{name: "not finite", payloads: []string{"NaN"}, err: "invalid message"},
+		{name: "selector miss", config: temperature.MQTTConfig{Selector: "temp"}, payloads: []string{`{"cpu":50}`}, err: "selector did not match"},
+		{name: "invalid keeps last", payloads: []string{"50", "hot"}, want: 50},
+		{name: "stale", config: temperature.MQTTConfig{MaxAge: "1ms"}, payloads: []string{"50"}, err: "stale"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Topic = "sensors/" + strings.ReplaceAll(tt.name, " ", "_")
+			source, err := temperature.NewMQTTSource(client, tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer source.Close()
+
+			if _, err := source.GetTemperature(); err == nil || !strings.Contains(err.Error(), "no message received") {
+				t.Errorf("before the first message: err = %v", err)
+			}
+
+			// Messages are handled in order, so the payloads were handled once the marker arrives
+			marker := make(chan struct{})
+			if err := client.Subscribe(tt.config.Topic+"/marker", func([]byte) { close(marker) }); err != nil {
+				t.Fatal(err)
+			}
+			for _, payload := range tt.payloads {
+				broker.publish(t, tt.config.Topic, payload)
+			}
+			broker.publish(t, tt.config.Topic+"/marker", "")
+			select {
+			case <-marker:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the messages")
+			}
+			time.Sleep(5 * time.Millisecond) // Let the 1ms max age pass
+
+			got, err := source.GetTemperature()
+			switch {
+			case tt.err != "":
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err = %v, want %q", err, tt.err)
+				}
+			case err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case got != tt.want:
+				t.Errorf("temperature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package temperature
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// commandWaitDelay bounds how long a plugin's output is read after it exits
+// or is killed, for children that keep stdout open.
+const commandWaitDelay = 100 * time.Millisecond
+
+// CommandSource implements the Source interface by running an external
+// executable. This allows custom sensors to be plugged in without
+// recompiling nanoctl.
+//
+// In one-shot mode the command is executed on every GetTemperature call and
+// the last non-empty line it prints is parsed. In stream mode the command is
+// started once and is expected to print one reading per line; the latest
+// reading is returned.
+type CommandSource struct {
+	path    string
+	args    []string
+	timeout time.Duration
+	unit    Unit
+	stream  bool
+
+	mu       sync.Mutex
+	proc     *commandProcess
+	last     float64
+	lastTime time.Time
+}
+
+// commandProcess tracks a single run of a streaming plugin.
+type commandProcess struct {
+	cmd    *exec.Cmd
+	ready  chan struct{} // closed when the first reading arrives
+	exited chan struct{} // closed when the process exits
+	err    error         // exit error, valid after exited is closed
+}
+
+// NewCommandSource creates a new command-based temperature source.
+func NewCommandSource(config CommandConfig) (*CommandSource, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("command path is required")
+	}
+
+	if config.Timeout == "" {
+		config.Timeout = "5s"
+	}
+	timeout, err := time.ParseDuration(config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout: %w", err)
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("timeout must be positive, got %s", config.Timeout)
+	}
+
+	unit, err := ParseUnit(config.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CommandSource{
+		path:    config.Path,
+		args:    config.Args,
+		timeout: timeout,
+		unit:    unit,
+		stream:  config.Stream,
+	}, nil
+}
+
+// GetTemperature returns the temperature reported by the plugin.
+func (c *CommandSource) GetTemperature() (float64, error) {
+	if c.stream {
+		return c.streamTemperature()
+	}
+	return c.runOnce()
+}
+
+func (c *CommandSource) runOnce() (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	out, err := c.command(ctx).Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return 0, fmt.Errorf("command %s timed out after %s", c.path, c.timeout)
+	}
+	// A plugin that exited but left a child holding stdout open is read
+	// like any other
+	if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return 0, fmt.Errorf("command %s failed: %w: %s", c.path, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return 0, fmt.Errorf("command %s failed: %w", c.path, err)
+	}
+
+	return parseReading(lastLine(string(out)), c.unit)
+}
+
+func (c *CommandSource) streamTemperature() (float64, error) {
+	c.mu.Lock()
+	if c.proc == nil {
+		proc, err := c.startLocked()
+		if err != nil {
+			c.mu.Unlock()
+			return 0, err
+		}
+		c.proc = proc
+	}
+	proc := c.proc
+	c.mu.Unlock()
+
+	// Wait for the first reading of a freshly started plugin.
+	select {
+	case <-proc.ready:
+	case <-proc.exited:
+	case <-time.After(c.timeout):
+		return 0, fmt.Errorf("no reading from %s within %s", c.path, c.timeout)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-proc.exited:
+		// Drop the dead process so the next call restarts it.
+		if c.proc == proc {
+			c.proc = nil
+		}
+		if proc.err != nil {
+			return 0, fmt.Errorf("command %s exited: %w", c.path, proc.err)
+		}
+		return 0, fmt.Errorf("command %s exited", c.path)
+	default:
+	}
+
+	if age := time.Since(c.lastTime); age > c.timeout {
+		return 0, fmt.Errorf("last reading from %s is stale (%s old)", c.path, age.Round(time.Millisecond))
+	}
+	return c.last, nil
+}
+
+// command returns the plugin command. It runs in its own process group so
+// that the children it starts, e.g. from a shell script, are killed with it
+// when ctx is done.
+func (c *CommandSource) command(ctx context.Context) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, c.path, c.args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return killGroup(cmd.Process) }
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
+
+// killGroup kills the process group led by p.
+func killGroup(p *os.Process) error {
+	if err := syscall.Kill(-p.Pid, syscall.SIGKILL); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *CommandSource) startLocked() (*commandProcess, error) {
+	cmd := c.command(context.Background())
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start command %s: %w", c.path, err)
+	}
+
+	proc := &commandProcess{
+		cmd:    cmd,
+		ready:  make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+
+	go func() {
+		var once sync.Once
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			temp, err := parseReading(scanner.Text(), c.unit)
+			if err != nil {
+				// Ignore lines that are not readings (e.g. banners)
+				continue
+			}
+			c.mu.Lock()
+			c.last = temp
+			c.lastTime = time.Now()
+			c.mu.Unlock()
+			once.Do(func() { close(proc.ready) })
+		}
+		proc.err = cmd.Wait()
+		close(proc.exited)
+	}()
+
+	return proc, nil
+}
+
+// Close implements the Source interface. It stops a running stream plugin.
+func (c *CommandSource) Close() error {
+	c.mu.Lock()
+	proc := c.proc
+	c.proc = nil
+	c.mu.Unlock()
+
+	if proc == nil {
+		return nil
+	}
+	if err := killGroup(proc.cmd.Process); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("failed to stop command %s: %w", c.path, err)
+	}
+	<-proc.exited
+	return nil
+}
+
+// lastLine returns the last non-empty line of s.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
@@ -0,0 +1,86 @@
+package temperature
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeScript writes a shell script with body and returns its path.
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plugin.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCommandSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		unit    string
+		want    float64
+		wantErr string // Empty if a reading is expected
+	}{
+		{"celsius", "echo 52.5\n", "", 52.5, ""},
+		{"millicelsius", "echo 52500\n", "millicelsius", 52.5, ""},
+		{"last line", "echo starting\necho 40\necho 41\n", "", 41, ""},
+		{"failure", "echo sensor missing >&2\nexit 3\n", "", 0, "exit status 3: sensor missing"},
+		{"not a number", "echo hot\n", "", 0, "hot"},
+		{"sleeping child", "sleep 10\necho 50\n", "", 0, "timed out after 200ms"},
+		{"background child", "sleep 10 &\necho 50\n", "", 50, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewCommandSource(CommandConfig{Path: writeScript(t, tt.script), Timeout: "200ms", Unit: tt.unit})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer source.Close()
+
+			start := time.Now()
+			got, err := source.GetTemperature()
+			// The timeout must hold even if the plugin's children keep stdout open
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("GetTemperature took %s, want at most the timeout", elapsed)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("GetTemperature error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("GetTemperature = %g, %v, want %g", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandSourceStream(t *testing.T) {
+	script := writeScript(t, "echo 45\nwhile true; do sleep 0.05; echo 46; done\n")
+	source, err := NewCommandSource(CommandConfig{Path: script, Timeout: "1s", Stream: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := source.GetTemperature(); err != nil || (got != 45 && got != 46) {
+		t.Fatalf("GetTemperature = %g, %v, want 45 or 46", got, err)
+	}
+
+	// Close kills the script and the sleep it is running
+	done := make(chan error, 1)
+	go func() { done <- source.Close() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close didn't return")
+	}
+}
@@ -0,0 +1,103 @@
+package temperature
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FailoverConfig holds configuration for a FailoverSource.
+type FailoverConfig struct {
+	Primary      Source
+	PrimaryName  string
+	Fallback     Source
+	FallbackName string
+
+	// RetryInterval is how often the primary is retried while on the fallback.
+	RetryInterval time.Duration
+
+	// OnReadError is called for every failed read, with the source name.
+	OnReadError func(source string, err error)
+	// OnSwitch is called when the active source changes.
+	OnSwitch func(from, to string, reason error)
+}
+
+// FailoverSource reads from a primary source and switches to a fallback
+// while the primary fails. The primary is retried every RetryInterval and
+// used again as soon as it recovers.
+type FailoverSource struct {
+	config FailoverConfig
+
+	mu         sync.Mutex
+	onFallback bool
+	lastRetry  time.Time
+}
+
+// NewFailoverSource creates a new failover source.
+func NewFailoverSource(config FailoverConfig) *FailoverSource {
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = 30 * time.Second
+	}
+	return &FailoverSource{config: config}
+}
+
+// GetTemperature reads from the active source, failing over when needed.
+func (f *FailoverSource) GetTemperature() (float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// While on the fallback, only retry the primary every RetryInterval so a
+	// dead remote source doesn't add its timeout to every loop iteration.
+	if !f.onFallback || time.Since(f.lastRetry) >= f.config.RetryInterval {
+		temp, err := f.config.Primary.GetTemperature()
+		if err == nil {
+			if f.onFallback {
+				f.onFallback = false
+				f.notifySwitch(f.config.FallbackName, f.config.PrimaryName, nil)
+			}
+			return temp, nil
+		}
+
+		f.notifyReadError(f.config.PrimaryName, err)
+		f.lastRetry = time.Now()
+		if !f.onFallback {
+			f.onFallback = true
+			f.notifySwitch(f.config.PrimaryName, f.config.FallbackName, err)
+		}
+	}
+
+	temp, err := f.config.Fallback.GetTemperature()
+	if err != nil {
+		f.notifyReadError(f.config.FallbackName, err)
+		return 0, fmt.Errorf("%s: %w", f.config.FallbackName, err)
+	}
+	return temp, nil
+}
+
+// Active returns the name of the source currently in use.
+func (f *FailoverSource) Active() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.onFallback {
+		return f.config.FallbackName
+	}
+	return f.config.PrimaryName
+}
+
+func (f *FailoverSource) notifyReadError(source string, err error) {
+	if f.config.OnReadError != nil {
+		f.config.OnReadError(source, err)
+	}
+}
+
+func (f *FailoverSource) notifySwitch(from, to string, reason error) {
+	if f.config.OnSwitch != nil {
+		f.config.OnSwitch(from, to, reason)
+	}
+}
+
+// Close implements the Source interface and closes both sources.
+func (f *FailoverSource) Close() error {
+	return errors.Join(f.config.Primary.Close(), f.config.Fallback.Close())
+}
@@ -0,0 +1,122 @@
+package temperature
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// stubSource returns value, or err if set.
+type stubSource struct {
+	value float64
+	err   error
+	reads int
+}
+
+func (s *stubSource) GetTemperature() (float64, error) {
+	s.reads++
+	return s.value, s.err
+}
+
+func (s *stubSource) Close() error { return nil }
+
+func TestFailoverSource(t *testing.T) {
+	errDown := errors.New("down")
+	tests := []struct {
+		name         string
+		primaryErrs  []error // Per read of the failover source
+		fallbackErr  error
+		retry        time.Duration
+		wantTemps    []float64 // 0 for an error
+		wantActive   string    // After the last read
+		wantSwitches []string
+	}{
+		{
+			name:        "primary",
+			primaryErrs: []error{nil, nil},
+			wantTemps:   []float64{50, 50},
+			wantActive:  "prometheus",
+		},
+		{
+			name:         "failover",
+			primaryErrs:  []error{nil, errDown, errDown},
+			retry:        time.Hour,
+			wantTemps:    []float64{50, 45, 45},
+			wantActive:   "file",
+			wantSwitches: []string{"prometheus->file"},
+		},
+		{
+			name:         "primary recovers",
+			primaryErrs:  []error{errDown, nil},
+			retry:        time.Nanosecond,
+			wantTemps:    []float64{45, 50},
+			wantActive:   "prometheus",
+			wantSwitches: []string{"prometheus->file", "file->prometheus"},
+		},
+		{
+			name:         "both fail",
+			primaryErrs:  []error{errDown},
+			fallbackErr:  errDown,
+			wantTemps:    []float64{0},
+			wantActive:   "file",
+			wantSwitches: []string{"prometheus->file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &stubSource{value: 50}
+			fallback := &stubSource{value: 45, err: tt.fallbackErr}
+			var switches []string
+			source := NewFailoverSource(FailoverConfig{
+				Primary:       primary,
+				PrimaryName:   "prometheus",
+				Fallback:      fallback,
+				FallbackName:  "file",
+				RetryInterval: tt.retry,
+				OnSwitch:      func(from, to string, _ error) { switches = append(switches, from+"->"+to) },
+			})
+
+			for i, want := range tt.wantTemps {
+				primary.err = tt.primaryErrs[i]
+				got, err := source.GetTemperature()
+				if want == 0 {
+					if err == nil {
+						t.Errorf("read %d = %g, want an error", i+1, got)
+					}
+					continue
+				}
+				if err != nil || got != want {
+					t.Errorf("read %d = %g, %v, want %g", i+1, got, err, want)
+				}
+			}
+			if active := source.Active(); active != tt.wantActive {
+				t.Errorf("Active = %q, want %q", active, tt.wantActive)
+			}
+			if !slices.Equal(switches, tt.wantSwitches) {
+				t.Errorf("switches = %q, want %q", switches, tt.wantSwitches)
+			}
+		})
+	}
+}
+
+func TestFailoverSourceRetryInterval(t *testing.T) {
+	primary := &stubSource{err: errors.New("down")}
+	source := NewFailoverSource(FailoverConfig{
+		Primary:       primary,
+		PrimaryName:   "prometheus",
+		Fallback:      &stubSource{value: 45},
+		FallbackName:  "file",
+		RetryInterval: time.Hour,
+	})
+
+	// A failing primary isn't read again until the retry interval passes
+	for range 5 {
+		if got, err := source.GetTemperature(); err != nil || got != 45 {
+			t.Fatalf("GetTemperature = %g, %v, want 45 from the fallback", got, err)
+		}
+	}
+	if primary.reads != 1 {
+		t.Errorf("primary read %d times, want 1", primary.reads)
+	}
+}
@@ -0,0 +1,123 @@
+package temperature
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// maxConsecutiveRejects is how many readings in a row the rate clamp may
+// reject before it accepts the new level as real.
+const maxConsecutiveRejects = 3
+
+// FilterConfig holds the settings of the filter stage. A zero value for a
+// field disables that stage.
+type FilterConfig struct {
+	MaxRate      float64 // Maximum plausible change in °C per second
+	MedianWindow int     // Number of readings for the median filter
+	EMAAlpha     float64 // Exponential moving average smoothing factor (0 < alpha <= 1)
+}
+
+// Filter smooths temperature readings before they reach the controller.
+// Stages are applied in order: rate-of-change clamp, median-of-N, EMA.
+type Filter struct {
+	config FilterConfig
+
+	// rate clamp state
+	lastValue float64
+	lastTime  time.Time
+	rejects   int
+
+	// median state
+	window []float64
+
+	// EMA state
+	ema    float64
+	hasEMA bool
+}
+
+// NewFilter creates a new filter stage.
+func NewFilter(config FilterConfig) (*Filter, error) {
+	if config.MaxRate < 0 {
+		return nil, fmt.Errorf("max rate must be >= 0, got %.2f", config.MaxRate)
+	}
+	if config.MedianWindow < 0 {
+		return nil, fmt.Errorf("median window must be >= 0, got %d", config.MedianWindow)
+	}
+	if config.EMAAlpha < 0 || config.EMAAlpha > 1 {
+		return nil, fmt.Errorf("ema alpha must be between 0 and 1, got %.2f", config.EMAAlpha)
+	}
+	return &Filter{config: config}, nil
+}
+
+// Apply feeds a raw reading taken at the given time through the filter and
+// returns the filtered value. An error is returned when the reading is
+// rejected as an implausible spike; the caller should skip it.
+func (f *Filter) Apply(value float64, at time.Time) (float64, error) {
+	if err := f.checkRate(value, at); err != nil {
+		return 0, err
+	}
+
+	value = f.median(value)
+	return f.smooth(value), nil
+}
+
+// checkRate rejects readings that change faster than MaxRate. After
+// maxConsecutiveRejects rejections the new level is accepted so a real step
+// change cannot lock the filter out.
+func (f *Filter) checkRate(value float64, at time.Time) error {
+	if f.config.MaxRate == 0 {
+		return nil
+	}
+
+	if !f.lastTime.IsZero() {
+		dt := at.Sub(f.lastTime).Seconds()
+		if dt > 0 {
+			rate := math.Abs(value-f.lastValue) / dt
+			if rate > f.config.MaxRate && f.rejects < maxConsecutiveRejects {
+				f.rejects++
+				return fmt.Errorf("rejected reading %.2f°C: changed %.2f°C/s (max %.2f°C/s)", value, rate, f.config.MaxRate)
+			}
+		}
+	}
+
+	f.rejects = 0
+	f.lastValue = value
+	f.lastTime = at
+	return nil
+}
+
+// median returns the median of the last MedianWindow readings.
+func (f *Filter) median(value float64) float64 {
+	if f.config.MedianWindow <= 1 {
+		return value
+	}
+
+	f.window = append(f.window, value)
+	if len(f.window) > f.config.MedianWindow {
+		f.window = f.window[1:]
+	}
+
+	sorted := append([]float64(nil), f.window...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// smooth applies the exponential moving average.
+func (f *Filter) smooth(value float64) float64 {
+	if f.config.EMAAlpha == 0 {
+		return value
+	}
+	if !f.hasEMA {
+		f.ema = value
+		f.hasEMA = true
+		return value
+	}
+	f.ema = f.config.EMAAlpha*value + (1-f.config.EMAAlpha)*f.ema
+	return f.ema
+}
@@ -0,0 +1,54 @@
+package temperature
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFilter(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name     string
+		config   FilterConfig
+		readings []float64 // One per second
+		want     []float64 // NaN if the reading is rejected
+	}{
+		{"disabled", FilterConfig{}, []float64{50, 80, 40}, []float64{50, 80, 40}},
+		{"spike", FilterConfig{MaxRate: 2}, []float64{50, 51, 90, 52}, []float64{50, 51, nan, 52}},
+		{"step change", FilterConfig{MaxRate: 2}, []float64{50, 70, 70, 70, 70, 70}, []float64{50, nan, nan, nan, 70, 70}},
+		{"rate limit", FilterConfig{MaxRate: 2}, []float64{50, 52, 54, 53}, []float64{50, 52, 54, 53}},
+		{"median", FilterConfig{MedianWindow: 3}, []float64{50, 80, 52, 54, 20}, []float64{50, 65, 52, 54, 52}},
+		{"ema", FilterConfig{EMAAlpha: 0.5}, []float64{40, 60, 60}, []float64{40, 50, 55}},
+		{"clamp before ema", FilterConfig{MaxRate: 2, EMAAlpha: 0.5}, []float64{40, 90, 42}, []float64{40, nan, 41}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewFilter(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := time.Unix(1_700_000_000, 0)
+			for i, reading := range tt.readings {
+				got, err := filter.Apply(reading, start.Add(time.Duration(i)*time.Second))
+				want := tt.want[i]
+				switch {
+				case math.IsNaN(want) && err == nil:
+					t.Errorf("reading %d (%g): got %g, want it rejected", i+1, reading, got)
+				case !math.IsNaN(want) && err != nil:
+					t.Errorf("reading %d (%g): rejected: %v", i+1, reading, err)
+				case !math.IsNaN(want) && got != want:
+					t.Errorf("reading %d (%g): got %g, want %g", i+1, reading, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewFilterRejectsInvalidConfig(t *testing.T) {
+	for _, config := range []FilterConfig{{MaxRate: -1}, {MedianWindow: -1}, {EMAAlpha: -0.1}, {EMAAlpha: 1.5}} {
+		if _, err := NewFilter(config); err == nil {
+			t.Errorf("NewFilter(%+v) succeeded, want an error", config)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package temperature
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxHTTPBodySize bounds how much of a response is read.
+const maxHTTPBodySize = 1 << 20
+
+// HTTPSource implements the Source interface for generic HTTP/JSON endpoints
+// such as ESPHome or small custom agents.
+type HTTPSource struct {
+	client   *http.Client
+	url      string
+	method   string
+	headers  map[string]string
+	body     string
+	selector []selectorStep
+	unit     Unit
+	timeout  time.Duration
+}
+
+// NewHTTPSource creates a new HTTP-based temperature source.
+func NewHTTPSource(config HTTPConfig) (*HTTPSource, error) {
+	// Validate required fields
+	if config.URL == "" {
+		return nil, fmt.Errorf("http url is required")
+	}
+
+	// Apply defaults
+	if config.Method == "" {
+		config.Method = http.MethodGet
+	}
+	if config.Timeout == "" {
+		config.Timeout = "5s"
+	}
+
+	timeout, err := time.ParseDuration(config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	unit, err := ParseUnit(config.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	selector, err := parseSelector(config.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := newTLSTransport(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HTTPSource{
+		client: &http.Client{
+			Transport: newAuthTransport(config.Auth, transport),
+		},
+		url:      config.URL,
+		method:   strings.ToUpper(config.Method),
+		headers:  config.Headers,
+		body:     config.Body,
+		selector: selector,
+		unit:     unit,
+		timeout:  timeout,
+	}, nil
+}
+
+// GetTemperature requests the endpoint and extracts the temperature.
+func (h *HTTPSource) GetTemperature() (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	var reqBody io.Reader
+	if h.body != "" {
+		reqBody = strings.NewReader(h.body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, h.method, h.url, reqBody)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range h.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("http request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBodySize))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("unexpected status %s from %s", resp.Status, h.url)
+	}
+
+	value, err := h.extract(data)
+	if err != nil {
+		return 0, err
+	}
+	return h.unit.ToCelsius(value), nil
+}
+
+// extract decodes the response and applies the selector. Plain-text bodies
+// containing just a number are accepted when no selector is configured.
+func (h *HTTPSource) extract(data []byte) (float64, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		if len(h.selector) == 0 {
+			return numericValue(strings.TrimSpace(string(data)))
+		}
+		return 0, fmt.Errorf("failed to decode JSON response: %w", err)
+	}
+
+	value, err := selectValue(doc, h.selector)
+	if err != nil {
+		return 0, fmt.Errorf("selector did not match: %w", err)
+	}
+
+	temp, err := numericValue(value)
+	if err != nil {
+		return 0, fmt.Errorf("selector did not match a number: %w", err)
+	}
+	return temp, nil
+}
+
+// Close implements the Source interface.
+func (h *HTTPSource) Close() error {
+	h.client.CloseIdleConnections()
+	return nil
+}
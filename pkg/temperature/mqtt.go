@@ -0,0 +1,145 @@
+package temperature
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Subscriber is the minimal message-bus interface the MQTT source needs.
+// It is implemented by mqtt.Client.
+type Subscriber interface {
+	Subscribe(topic string, handler func(payload []byte)) error
+	Unsubscribe(topic string) error
+}
+
+// MQTTSource implements the Source interface by subscribing to a topic.
+// The latest message received is used as the current temperature.
+type MQTTSource struct {
+	subscriber Subscriber
+	topic      string
+	selector   []selectorStep
+	unit       Unit
+	maxAge     time.Duration
+
+	mu       sync.Mutex
+	last     float64
+	lastTime time.Time
+	lastErr  error
+}
+
+// NewMQTTSource creates a new MQTT-based temperature source and subscribes to the topic.
+func NewMQTTSource(subscriber Subscriber, config MQTTConfig) (*MQTTSource, error) {
+	// Validate required fields
+	if config.Topic == "" {
+		return nil, fmt.Errorf("mqtt topic is required")
+	}
+
+	// Apply defaults
+	if config.MaxAge == "" {
+		config.MaxAge = "1m"
+	}
+
+	maxAge, err := time.ParseDuration(config.MaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max_age: %w", err)
+	}
+
+	unit, err := ParseUnit(config.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	selector, err := parseSelector(config.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MQTTSource{
+		subscriber: subscriber,
+		topic:      config.Topic,
+		selector:   selector,
+		unit:       unit,
+		maxAge:     maxAge,
+	}
+
+	if err := subscriber.Subscribe(config.Topic, m.handle); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", config.Topic, err)
+	}
+
+	return m, nil
+}
+
+func (m *MQTTSource) handle(payload []byte) {
+	temp, err := m.parse(payload)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.lastErr = err
+		return
+	}
+	m.last = temp
+	m.lastTime = time.Now()
+	m.lastErr = nil
+}
+
+// parse accepts either a bare number or a JSON document when a selector is configured.
+func (m *MQTTSource) parse(payload []byte) (float64, error) {
+	if len(m.selector) == 0 {
+		return parseReading(strings.Trim(string(payload), "\""), m.unit)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return 0, fmt.Errorf("failed to decode JSON payload: %w", err)
+	}
+	value, err := selectValue(doc, m.selector)
+	if err != nil {
+		return 0, fmt.Errorf("selector did not match: %w", err)
+	}
+	temp, err := numericValue(value)
+	if err != nil {
+		return 0, fmt.Errorf("selector did not match a number: %w", err)
+	}
+	return m.unit.ToCelsius(temp), nil
+}
+
+// GetTemperature returns the most recent reading received on the topic.
+func (m *MQTTSource) GetTemperature() (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lastTime.IsZero() {
+		if m.lastErr != nil {
+			return 0, fmt.Errorf("invalid message on %s: %w", m.topic, m.lastErr)
+		}
+		return 0, fmt.Errorf("no message received on %s yet", m.topic)
+	}
+	if age := time.Since(m.lastTime); age > m.maxAge {
+		return 0, fmt.Errorf("last reading on %s is stale (%s old)", m.topic, age.Round(time.Second))
+	}
+	return m.last, nil
+}
+
+// WaitForReading blocks until a first reading arrives or timeout elapses.
+func (m *MQTTSource) WaitForReading(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := m.GetTemperature(); err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Close implements the Source interface.
+func (m *MQTTSource) Close() error {
+	return m.subscriber.Unsubscribe(m.topic)
+}
@@ -3,10 +3,11 @@ package temperature
 import (
 	"context"
 	"fmt"
-	"net/http"
-	"os"
+	"log/slog"
+	"sync"
 	"time"
 
+	"github.com/AlejandroPerez92/nanoctl/pkg/logging"
 	"github.com/prometheus/client_golang/api"
 	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
@@ -18,6 +19,19 @@ type PrometheusSource struct {
 	api     promv1.API
 	query   string
 	timeout time.Duration
+	maxAge  time.Duration // 0 disables the staleness check
+	logger  *slog.Logger
+
+	mu         sync.Mutex
+	lastSeries int
+}
+
+// PrometheusReading is the detailed result of a temperature query.
+type PrometheusReading struct {
+	Value     float64   // Temperature in degrees Celsius
+	Timestamp time.Time // Timestamp of the selected sample
+	Series    int       // Number of series returned by the query
+	Stale     int       // Number of series dropped because they were older than max_age
 }
 
 // NewPrometheusSource creates a new Prometheus-based temperature source.
@@ -40,18 +54,22 @@ func NewPrometheusSource(config PrometheusConfig) (*PrometheusSource, error) {
 		return nil, fmt.Errorf("invalid timeout: %w", err)
 	}
 
+	var maxAge time.Duration
+	if config.MaxAge != "" {
+		maxAge, err = time.ParseDuration(config.MaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_age: %w", err)
+		}
+	}
+
 	// Build client config
 	clientConfig := api.Config{
 		Address: config.Host,
 	}
 
-	// Add basic auth if provided
-	if config.Auth.Username != "" {
-		clientConfig.RoundTripper = &basicAuthTransport{
-			Username:  config.Auth.Username,
-			Password:  config.Auth.Password,
-			Transport: api.DefaultRoundTripper,
-		}
+	// Add basic or bearer auth if provided
+	if config.Auth.Username != "" || config.Auth.Token != "" {
+		clientConfig.RoundTripper = newAuthTransport(config.Auth, api.DefaultRoundTripper)
 	}
 
 	client, err := api.NewClient(clientConfig)
@@ -64,46 +82,119 @@ func NewPrometheusSource(config PrometheusConfig) (*PrometheusSource, error) {
 		api:     promv1.NewAPI(client),
 		query:   config.Query,
 		timeout: timeout,
+		maxAge:  maxAge,
+		logger:  logging.OrDefault(config.Logger),
 	}, nil
 }
 
 // GetTemperature executes the PromQL query to fetch the temperature.
+// When the query returns several series the hottest one is used.
 func (p *PrometheusSource) GetTemperature() (float64, error) {
+	reading, err := p.Query()
+	if err != nil {
+		return 0, err
+	}
+
+	// Surface a change in the number of series instead of silently picking one
+	p.mu.Lock()
+	if reading.Series > 1 && reading.Series != p.lastSeries {
+		p.logger.Warn("Prometheus query returned several series, using the highest value; aggregate the query (e.g. max(...)) to silence this warning", "series", reading.Series)
+	}
+	p.lastSeries = reading.Series
+	p.mu.Unlock()
+
+	return reading.Value, nil
+}
+
+// Query executes the PromQL query and returns the selected sample together
+// with details about the result.
+//
+// If max_age is set, it is also sent as the query lookback delta so that
+// Prometheus does not fill in samples older than max_age for instant
+// selectors, and every returned sample (including the last sample of
+// range-vector results) older than max_age is treated as stale. Instant
+// vectors carry the evaluation time rather than the time of the data, so
+// the lookback delta is what catches them; functions over a range such as
+// avg_over_time(x[10m]) ignore it and return a value while their range
+// holds a sample.
+func (p *PrometheusSource) Query() (PrometheusReading, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
 	defer cancel()
 
-	result, warnings, err := p.api.Query(ctx, p.query, time.Now())
+	var opts []promv1.Option
+	if p.maxAge > 0 {
+		opts = append(opts, promv1.WithLookbackDelta(p.maxAge))
+	}
+
+	now := time.Now()
+	result, warnings, err := p.api.Query(ctx, p.query, now, opts...)
 	if err != nil {
-		return 0, fmt.Errorf("prometheus query failed: %w", err)
+		return PrometheusReading{}, fmt.Errorf("prometheus query failed: %w", err)
 	}
 
 	if len(warnings) > 0 {
-		// Log warnings but don't fail, use stderr to avoid polluting stdout if used for CLI output
+		// Log warnings but don't fail
 		for _, w := range warnings {
-			fmt.Fprintf(os.Stderr, "Prometheus warning: %s\n", w)
+			p.logger.Warn("Prometheus warning", "warning", w)
 		}
 	}
 
 	// Early return if no result
 	if result == nil {
-		return 0, fmt.Errorf("no result from prometheus query")
+		return PrometheusReading{}, fmt.Errorf("no result from prometheus query")
 	}
 
-	// Parse result based on type. We expect a Vector (instant query) or Scalar.
-	// Usually Query returns a Vector or Scalar.
+	// Collect one sample per series. Range vectors contribute their latest sample.
+	var samples []model.SamplePair
 	switch v := result.(type) {
 	case model.Vector:
-		if len(v) == 0 {
-			return 0, fmt.Errorf("no temperature data returned from query")
+		for _, s := range v {
+			samples = append(samples, model.SamplePair{Timestamp: s.Timestamp, Value: s.Value})
+		}
+	case model.Matrix:
+		for _, s := range v {
+			if len(s.Values) > 0 {
+				samples = append(samples, s.Values[len(s.Values)-1])
+			}
 		}
-		// We take the first element. The query should ideally return a single scalar or vector.
-		// If it returns multiple, taking the first is a reasonable default if aggregation was expected but not fully done.
-		return float64(v[0].Value), nil
 	case *model.Scalar:
-		return float64(v.Value), nil
+		samples = append(samples, model.SamplePair{Timestamp: v.Timestamp, Value: v.Value})
 	default:
-		return 0, fmt.Errorf("unexpected result type: %T", result)
+		return PrometheusReading{}, fmt.Errorf("unexpected result type: %T", result)
 	}
+
+	if len(samples) == 0 {
+		if p.maxAge > 0 {
+			return PrometheusReading{}, fmt.Errorf("no temperature data newer than %s returned from query", p.maxAge)
+		}
+		return PrometheusReading{}, fmt.Errorf("no temperature data returned from query")
+	}
+
+	reading := PrometheusReading{Series: len(samples)}
+	found := false
+	var newest time.Time
+	for _, s := range samples {
+		ts := s.Timestamp.Time()
+		if ts.After(newest) {
+			newest = ts
+		}
+		if p.maxAge > 0 && now.Sub(ts) > p.maxAge {
+			reading.Stale++
+			continue
+		}
+		if !found || float64(s.Value) > reading.Value {
+			reading.Value = float64(s.Value)
+			reading.Timestamp = ts
+			found = true
+		}
+	}
+
+	if !found {
+		return reading, fmt.Errorf("all %d series are stale: newest sample is %s old (max_age %s)",
+			reading.Series, now.Sub(newest).Round(time.Second), p.maxAge)
+	}
+
+	return reading, nil
 }
 
 // Close implements the Source interface.
@@ -111,21 +202,3 @@ func (p *PrometheusSource) Close() error {
 	// Prometheus client doesn't need explicit cleanup
 	return nil
 }
-
-// basicAuthTransport implements HTTP basic authentication
-type basicAuthTransport struct {
-	Username  string
-	Password  string
-	Transport http.RoundTripper
-}
-
-func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	if t.Username != "" {
-		req.SetBasicAuth(t.Username, t.Password)
-	}
-	// Use default transport if t.Transport is nil
-	if t.Transport == nil {
-		return http.DefaultTransport.RoundTrip(req)
-	}
-	return t.Transport.RoundTrip(req)
-}
@@ -0,0 +1,134 @@
+package temperature
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// prometheusServer answers /api/v1/query with the data returned by result
+// for the evaluation time of the query, in seconds, and records the
+// lookback_delta of the last query.
+func prometheusServer(t *testing.T, result func(eval float64) string, lookback *string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		eval, err := strconv.ParseFloat(r.Form.Get("time"), 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*lookback = r.Form.Get("lookback_delta")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":"success","data":%s}`, result(eval))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// vector returns an instant vector result with a sample of each value at
+// time ts.
+func vector(ts float64, values ...string) string {
+	var samples []string
+	for i, v := range values {
+		samples = append(samples, fmt.Sprintf(`{"metric":{"sensor":"temp%d"},"value":[%f,%q]}`, i, ts, v))
+	}
+	return `{"resultType":"vector","result":[` + strings.Join(samples, ",") + `]}`
+}
+
+func TestPrometheusSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxAge  string
+		result  func(eval float64) string
+		want    float64
+		wantErr string // Empty if a reading is expected
+	}{
+		{
+			name:   "instant vector",
+			maxAge: "1m",
+			result: func(eval float64) string { return vector(eval, "52.3") },
+			want:   52.3,
+		},
+		{
+			name:   "hottest series",
+			result: func(eval float64) string { return vector(eval, "48", "61.5", "50") },
+			want:   61.5,
+		},
+		{
+			// Prometheus returns nothing when no sample is newer than the
+			// lookback delta, which is max_age
+			name:    "no sample within max_age",
+			maxAge:  "1m",
+			result:  func(float64) string { return `{"resultType":"vector","result":[]}` },
+			wantErr: "no temperature data newer than 1m0s returned from query",
+		},
+		{
+			name:    "stale vector sample",
+			maxAge:  "1m",
+			result:  func(eval float64) string { return vector(eval-300, "52.3") },
+			wantErr: "all 1 series are stale",
+		},
+		{
+			name:   "stale vector sample without max_age",
+			result: func(eval float64) string { return vector(eval-300, "52.3") },
+			want:   52.3,
+		},
+		{
+			name:   "range vector",
+			maxAge: "1m",
+			result: func(eval float64) string {
+				return fmt.Sprintf(`{"resultType":"matrix","result":[{"metric":{},"values":[[%f,"40"],[%f,"45"]]}]}`, eval-120, eval-10)
+			},
+			want: 45,
+		},
+		{
+			name:   "stale range vector",
+			maxAge: "1m",
+			result: func(eval float64) string {
+				return fmt.Sprintf(`{"resultType":"matrix","result":[{"metric":{},"values":[[%f,"40"]]}]}`, eval-120)
+			},
+			wantErr: "all 1 series are stale",
+		},
+		{
+			name:   "scalar",
+			result: func(eval float64) string { return fmt.Sprintf(`{"resultType":"scalar","result":[%f,"47"]}`, eval) },
+			want:   47,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookback string
+			host := prometheusServer(t, tt.result, &lookback)
+			source, err := NewPrometheusSource(PrometheusConfig{Host: host, MaxAge: tt.maxAge, Logger: slog.New(slog.DiscardHandler)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := source.GetTemperature()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("GetTemperature error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil || got != tt.want {
+				t.Errorf("GetTemperature = %g, %v, want %g", got, err, tt.want)
+			}
+
+			// max_age limits how far back Prometheus looks for instant selectors
+			wantLookback := ""
+			if tt.maxAge != "" {
+				wantLookback = "1m0s"
+			}
+			if lookback != wantLookback {
+				t.Errorf("lookback_delta = %q, want %q", lookback, wantLookback)
+			}
+		})
+	}
+}
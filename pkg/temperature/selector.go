@@ -0,0 +1,127 @@
+package temperature
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// selectorStep is a single step of a parsed JSON selector: either an object
+// key or an array index.
+type selectorStep struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+// parseSelector parses a JSONPath-style selector such as
+// "$.sensors[0].value", "sensors.0.value" or "$['cpu temp']".
+// An empty selector (or "$") selects the whole document.
+func parseSelector(selector string) ([]selectorStep, error) {
+	s := strings.TrimSpace(selector)
+	s = strings.TrimPrefix(s, "$")
+
+	var steps []selectorStep
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			if name == "" {
+				return nil, fmt.Errorf("invalid selector '%s': empty key", selector)
+			}
+			steps = append(steps, keyOrIndex(name))
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid selector '%s': missing ']'", selector)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, selectorStep{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			idx, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid selector '%s': bad index '%s'", selector, inner)
+			}
+			steps = append(steps, selectorStep{index: idx, isIdx: true})
+		default:
+			// Allow a leading bare key ("sensors.0.value")
+			if len(steps) > 0 {
+				return nil, fmt.Errorf("invalid selector '%s' near '%s'", selector, s)
+			}
+			s = "." + s
+		}
+	}
+	return steps, nil
+}
+
+// keyOrIndex treats purely numeric dotted segments ("sensors.0") as array
+// indexes so both JSONPath and dotted notations work.
+func keyOrIndex(name string) selectorStep {
+	if idx, err := strconv.Atoi(name); err == nil {
+		return selectorStep{key: name, index: idx, isIdx: true}
+	}
+	return selectorStep{key: name}
+}
+
+// selectValue walks the decoded JSON document following steps.
+func selectValue(doc any, steps []selectorStep) (any, error) {
+	cur := doc
+	for _, step := range steps {
+		switch v := cur.(type) {
+		case map[string]any:
+			key := step.key
+			if step.isIdx && key == "" {
+				key = strconv.Itoa(step.index)
+			}
+			next, ok := v[key]
+			if !ok {
+				return nil, fmt.Errorf("key '%s' not found", key)
+			}
+			cur = next
+		case []any:
+			if !step.isIdx {
+				return nil, fmt.Errorf("cannot select key '%s' from an array", step.key)
+			}
+			if step.index < 0 || step.index >= len(v) {
+				return nil, fmt.Errorf("index %d out of range (length %d)", step.index, len(v))
+			}
+			cur = v[step.index]
+		default:
+			return nil, fmt.Errorf("cannot select into %T", cur)
+		}
+	}
+	return cur, nil
+}
+
+// numericValue converts a selected JSON value into a float64. Strings such as
+// "42.5" or "42.5 °C" are accepted as well since many devices report them.
+func numericValue(v any) (float64, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.Float64()
+	case float64:
+		return n, nil
+	case string:
+		field := strings.Fields(n)
+		if len(field) == 0 {
+			return 0, fmt.Errorf("empty string value")
+		}
+		f, err := parseFinite(strings.TrimSuffix(field[0], "°C"))
+		if err != nil {
+			return 0, fmt.Errorf("value '%s' is not numeric", n)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("selected value is %T, not a number", v)
+	}
+}
@@ -1,7 +1,10 @@
 // Package temperature provides temperature reading sources for the fan controller.
-// It supports both local file-based reading and remote Prometheus queries.
+// It supports local file-based reading, remote Prometheus queries, generic
+// HTTP/JSON endpoints, MQTT topics and external commands acting as plugins.
 package temperature
 
+import "log/slog"
+
 // Source defines the interface for fetching temperature data.
 type Source interface {
 	// GetTemperature returns the current temperature in degrees Celsius.
@@ -18,6 +21,12 @@ const (
 	SourceFile SourceType = "file"
 	// SourcePrometheus represents a Prometheus-based temperature source.
 	SourcePrometheus SourceType = "prometheus"
+	// SourceCommand represents an external command (plugin) temperature source.
+	SourceCommand SourceType = "command"
+	// SourceHTTP represents a generic HTTP/JSON temperature source.
+	SourceHTTP SourceType = "http"
+	// SourceMQTT represents an MQTT topic temperature source.
+	SourceMQTT SourceType = "mqtt"
 )
 
 // SourceConfig holds the configuration for creating a new Source.
@@ -26,6 +35,9 @@ type SourceConfig struct {
 	Type       SourceType
 	FilePath   string
 	Prometheus PrometheusConfig
+	Command    CommandConfig
+	HTTP       HTTPConfig
+	MQTT       MQTTConfig
 }
 
 // PrometheusConfig holds configuration specific to the Prometheus source.
@@ -33,11 +45,54 @@ type PrometheusConfig struct {
 	Host    string
 	Query   string
 	Timeout string
+	MaxAge  string
 	Auth    AuthConfig
+	Logger  *slog.Logger // Optional: defaults to slog.Default()
 }
 
 // AuthConfig holds authentication details.
+// Token enables bearer authentication and takes precedence over basic auth.
 type AuthConfig struct {
 	Username string
 	Password string
+	Token    string
+}
+
+// TLSConfig holds TLS settings for HTTP-based sources.
+type TLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// CommandConfig holds configuration specific to the command source.
+type CommandConfig struct {
+	Path    string
+	Args    []string
+	Timeout string
+	Unit    string
+	Stream  bool
+}
+
+// HTTPConfig holds configuration specific to the HTTP source.
+type HTTPConfig struct {
+	URL      string
+	Method   string
+	Headers  map[string]string
+	Body     string
+	Selector string
+	Unit     string
+	Timeout  string
+	Auth     AuthConfig
+	TLS      TLSConfig
+}
+
+// MQTTConfig holds configuration specific to the MQTT source.
+type MQTTConfig struct {
+	Topic    string
+	Selector string
+	Unit     string
+	MaxAge   string
 }
@@ -0,0 +1,101 @@
+package temperature
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// newAuthTransport wraps base with basic or bearer authentication.
+// A token takes precedence over username/password.
+func newAuthTransport(auth AuthConfig, base http.RoundTripper) http.RoundTripper {
+	if auth.Token != "" {
+		return &bearerAuthTransport{
+			Token:     auth.Token,
+			Transport: base,
+		}
+	}
+	if auth.Username != "" {
+		return &basicAuthTransport{
+			Username:  auth.Username,
+			Password:  auth.Password,
+			Transport: base,
+		}
+	}
+	return base
+}
+
+// basicAuthTransport implements HTTP basic authentication
+type basicAuthTransport struct {
+	Username  string
+	Password  string
+	Transport http.RoundTripper
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Username != "" {
+		req.SetBasicAuth(t.Username, t.Password)
+	}
+	// Use default transport if t.Transport is nil
+	if t.Transport == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return t.Transport.RoundTrip(req)
+}
+
+// bearerAuthTransport implements HTTP bearer token authentication
+type bearerAuthTransport struct {
+	Token     string
+	Transport http.RoundTripper
+}
+
+func (t *bearerAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.Token)
+	}
+	// Use default transport if t.Transport is nil
+	if t.Transport == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return t.Transport.RoundTrip(req)
+}
+
+// newTLSTransport returns an HTTP transport using the given TLS settings.
+// Each call returns a new transport, so closing the idle connections of one
+// source doesn't affect the others.
+func newTLSTransport(config TLSConfig) (http.RoundTripper, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config == (TLSConfig{}) {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
@@ -0,0 +1,66 @@
+package temperature
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Unit describes how a raw temperature reading is scaled.
+type Unit string
+
+const (
+	// UnitCelsius means the reading is already in degrees Celsius.
+	UnitCelsius Unit = "celsius"
+	// UnitMillicelsius means the reading is in millidegrees Celsius (sysfs style).
+	UnitMillicelsius Unit = "millicelsius"
+)
+
+// ParseUnit converts a configuration string into a Unit, defaulting to Celsius.
+// It accepts the same values as the configuration validation.
+func ParseUnit(s string) (Unit, error) {
+	switch Unit(s) {
+	case "", UnitCelsius:
+		return UnitCelsius, nil
+	case UnitMillicelsius:
+		return UnitMillicelsius, nil
+	default:
+		return "", fmt.Errorf("unsupported temperature unit '%s' (expected 'celsius' or 'millicelsius')", s)
+	}
+}
+
+// ToCelsius scales a raw value in the given unit to degrees Celsius.
+func (u Unit) ToCelsius(v float64) float64 {
+	if u == UnitMillicelsius {
+		return v / 1000.0
+	}
+	return v
+}
+
+// parseReading parses a textual reading such as "52.3" or "52300\n" and
+// returns it in degrees Celsius.
+func parseReading(s string, unit Unit) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty temperature reading")
+	}
+	v, err := parseFinite(s)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse temperature '%s': %w", s, err)
+	}
+	return unit.ToCelsius(v), nil
+}
+
+// parseFinite parses a number, rejecting NaN and infinities, which
+// strconv.ParseFloat accepts but the PID controller can't use.
+func parseFinite(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("'%s' is not a finite number", s)
+	}
+	return v, nil
+}